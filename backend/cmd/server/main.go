@@ -1,26 +1,155 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
+	"os"
 	"slurm-dashboard/config"
 	"slurm-dashboard/internal/api"
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/secret"
+	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/slurm"
 	"slurm-dashboard/internal/store"
+	"time"
 )
 
 func main() {
+	configPath := flag.String("config", os.Getenv(config.EnvPrefix+"CONFIG"), "path to the YAML configuration file")
+	flag.Parse()
+
 	// 1. 加载配置
-	cfg := config.LoadConfig()
+	cfgProvider, err := config.NewProvider(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	switch cfg := cfgProvider.Get(); {
+	case !cfg.UsesLDAP():
+	case cfg.LDAPTLSMode == config.LDAPTLSNone:
+		log.Println("WARNING: LDAP connections are not encrypted (ldap_tls_mode: none), passwords are sent in cleartext")
+	case cfg.LDAPTLSInsecureSkipVerify:
+		log.Println("WARNING: LDAP server certificate verification is disabled (ldap_tls_insecure_skip_verify)")
+	}
+	if cfgProvider.Get().UsesAuthBackend(config.AuthBackendPAM) && !auth.PAMSupported {
+		log.Fatalf("auth_backends contains %q but this binary was built without PAM support (build with -tags pam)", config.AuthBackendPAM)
+	}
+	// 监听 SIGHUP 和配置文件变更，热加载配置
+	go cfgProvider.Watch(context.Background(), 5*time.Second)
 
 	// 2. 初始化存储
-	tokenStore := store.NewTokenStore()
-	sessionStore := store.NewSessionStore()
+	db, err := store.Open(cfgProvider.Get().StorePath)
+	if err != nil {
+		log.Fatalf("Failed to open store: %v", err)
+	}
+	defer db.Close()
+	// Slurm token 加密保存，启动时将旧密钥加密的 token 迁移到当前密钥
+	keyring, err := secret.NewKeyring(cfgProvider.Get().TokenEncryptionKeys(), "slurm-token")
+	if err != nil {
+		log.Fatalf("Failed to initialize token encryption: %v", err)
+	}
+	tokenStore := store.NewTokenStore(db, keyring)
+	if n, err := tokenStore.Reencrypt(); err != nil {
+		log.Fatalf("Failed to re-encrypt stored Slurm tokens: %v", err)
+	} else if n > 0 {
+		log.Printf("Re-encrypted %d stored Slurm tokens with the current key", n)
+	}
+	sessionStore := store.NewSessionStore(db)
+	revocations := store.NewRevocationStore(db)
+	refreshTokens := store.NewRefreshTokenStore(db, revocations)
+	tickets := store.NewTicketStore(revocations)
+	apiKeys := store.NewAPIKeyStore(db)
+	// TOTP 密钥只用 token_encryption_secrets 加密。由 jwt_secret_key 派生的密钥在轮换 JWT 密钥后无法解密，
+	// 会让所有启用了两步验证的用户无法登录，因此未配置 token_encryption_secrets 时不能绑定 TOTP
+	var totpStore *store.TOTPStore
+	if secrets := cfgProvider.Get().TokenEncryptionSecrets; len(secrets) > 0 {
+		// 旧版本未配置 token_encryption_secrets 时由 jwt_secret_key 派生密钥，启动时将这些记录迁移到当前密钥
+		legacyKeyring, err := secret.NewKeyring(append(append([]string(nil), secrets...), cfgProvider.Get().JWTSecretKey), "totp-secret")
+		if err != nil {
+			log.Fatalf("Failed to initialize TOTP secret encryption: %v", err)
+		}
+		if n, err := store.NewTOTPStore(db, legacyKeyring).Reencrypt(); err != nil {
+			log.Fatalf("Failed to re-encrypt stored TOTP secrets: %v", err)
+		} else if n > 0 {
+			log.Printf("Re-encrypted %d stored TOTP secrets with the current key", n)
+		}
+		totpKeyring, err := secret.NewKeyring(secrets, "totp-secret")
+		if err != nil {
+			log.Fatalf("Failed to initialize TOTP secret encryption: %v", err)
+		}
+		totpStore = store.NewTOTPStore(db, totpKeyring)
+		// 两步验证记录以规范化的用户名保存，迁移以登录时输入的大小写保存的旧记录
+		if n, err := totpStore.Rekey(auth.NormalizeUsername); err != nil {
+			log.Fatalf("Failed to normalize stored TOTP usernames: %v", err)
+		} else if n > 0 {
+			log.Printf("Moved %d TOTP records to normalized usernames", n)
+		}
+	} else {
+		totpStore = store.NewTOTPStore(db, nil)
+		if n, err := totpStore.Count(); err != nil {
+			log.Fatalf("Failed to read stored TOTP secrets: %v", err)
+		} else if n > 0 {
+			log.Fatalf("%d users have enrolled TOTP, token_encryption_secrets must be configured to keep their secrets readable", n)
+		}
+		log.Println("token_encryption_secrets is not configured, two-factor authentication is unavailable")
+	}
+	audit := store.NewAuditLog(db, cfgProvider.Get().AuditRetention)
+
+	// 3. 初始化 Slurm 数据源和路由
+	slurmClient := slurm.NewClient(cfgProvider)
+	negotiateCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	if _, err := slurmClient.Negotiate(negotiateCtx, nil); err != nil {
+		// 探测失败时在第一个带有用户凭据的请求中再次协商
+		log.Printf("Could not negotiate Slurm REST API version at startup: %v", err)
+	}
+	cancel()
+
+	// slurmrestd 不可达时自动降级到 Slurm 命令行
+	executor := services.NewLocalExecutor(cfgProvider)
+	failoverSource := slurm.NewFailoverSource(cfgProvider, slurmClient, slurm.NewCLISource(executor))
+	go failoverSource.RunHealthChecks(context.Background())
+
+	// 在 Slurm token 到期前自动续期，slurmrestd 返回 401 时续期后重试一次
+	renewer := services.NewTokenRenewer(cfgProvider, executor, tokenStore)
+	go renewer.Run(context.Background())
+	slurmSource := slurm.NewRenewingSource(failoverSource, renewer.Renew)
+
+	// LDAP 连接池按 ldap_servers 的顺序故障切换，并定期检查各服务器；
+	// 登录按 auth_backends 的顺序依次尝试 LDAP、htpasswd 文件和 PAM
+	ldapClient := auth.NewLDAPClient(cfgProvider)
+	defer ldapClient.Close()
+	go ldapClient.RunHealthChecks(context.Background())
+	authenticator := auth.NewChain(cfgProvider, map[string]auth.Authenticator{
+		config.AuthBackendLDAP:     ldapClient,
+		config.AuthBackendHtpasswd: auth.NewHtpasswdAuthenticator(cfgProvider),
+		config.AuthBackendPAM:      auth.NewPAMAuthenticator(cfgProvider),
+	})
 
-	// 3. 初始化路由
-	router := api.NewRouter(cfg, tokenStore, sessionStore)
+	router := api.NewRouter(api.Dependencies{
+		Config:        cfgProvider,
+		Slurm:         slurmSource,
+		Executor:      executor,
+		Authenticator: authenticator,
+		Directory:     ldapClient,
+		OIDC:          auth.NewOIDCAuthenticator(cfgProvider),
+		TokenStore:    tokenStore,
+		SessionStore:  sessionStore,
+		Revocations:   revocations,
+		RefreshTokens: refreshTokens,
+		Tickets:       tickets,
+		APIKeys:       apiKeys,
+		Renewer:       renewer,
+		Accounts:      auth.NewAccountChecker(cfgProvider, executor, ldapClient),
+		Limiter:       auth.NewLoginLimiter(cfgProvider),
+		Audit:         audit,
+		TOTP:          totpStore,
+		Challenges:    store.NewMFAChallengeStore(),
+	})
 
 	// 4. 启动服务
-	log.Println("Go backend server is running on :" + cfg.ServerPort)
-	if err := router.Run(":" + cfg.ServerPort); err != nil {
+	serverPort := cfgProvider.Get().ServerPort
+	log.Println("Go backend server is running on :" + serverPort)
+	if err := router.Run(":" + serverPort); err != nil {
 		log.Fatalf("Failed to run server: %v", err)
 	}
 }
@@ -1,44 +1,820 @@
 package config
 
-import "time"
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix 是所有环境变量覆盖项的统一前缀
+const EnvPrefix = "SLURM_DASHBOARD_"
+
+// role_source 和 ldap_group_membership 的可选值
+const (
+	RoleSourceLDAP  = "ldap"
+	RoleSourceLocal = "local"
+
+	GroupMembershipMemberOf   = "memberOf"
+	GroupMembershipPosixGroup = "posixGroup"
+)
+
+// auth_backends 的可选值
+const (
+	AuthBackendLDAP     = "ldap"
+	AuthBackendHtpasswd = "htpasswd"
+	AuthBackendPAM      = "pam"
+)
+
+// ldap_tls_mode 的可选值
+const (
+	LDAPTLSNone     = "none"
+	LDAPTLSLDAPS    = "ldaps"
+	LDAPTLSStartTLS = "starttls"
+)
+
+// cookie_same_site 的可选值
+const (
+	SameSiteStrict = "strict"
+	SameSiteLax    = "lax"
+	SameSiteNone   = "none"
+)
+
+// roleNames 为 role_groups 中允许出现的角色，与 auth 包中的角色常量一致
+var roleNames = map[string]struct{}{
+	"admin":               {},
+	"operator":            {},
+	"account-coordinator": {},
+	"user":                {},
+}
 
 type Config struct {
-	LDAPServerHost        string
-	LDAPServerPort        int
-	LDAPAdminDN           string
-	LDAPAdminPassword     string
-	LDAPSearchBaseDN      string
-	LDAPUserSearchFilter  string
-	SlurmAPIHost          string
-	JWTSecretKey          string
-	JWTIssuer             string
-	JWTDuration           time.Duration
-	SlurmTokenLifespanSec string
-	ServerPort            string
-	JobConnectLogPattern  string
-	JobInfoLogPattern     string
-}
-
-// LoadConfig 加载并返回所有配置
-func LoadConfig() *Config {
+	// AuthBackends 为按顺序尝试的认证方式："ldap"、"htpasswd"（bcrypt 用户文件）和 "pam"。
+	// 密码在某个后端校验失败时继续尝试下一个，直到有一个后端认证成功。
+	AuthBackends []string `yaml:"auth_backends"`
+	// HtpasswdFile 为 htpasswd 后端的用户文件，每行为 "用户名:bcrypt 哈希"，可以用 htpasswd -B 生成，修改后自动重新读取
+	HtpasswdFile string `yaml:"htpasswd_file"`
+	// PAMService 为 pam 后端使用的 PAM 服务名，对应 /etc/pam.d 下的文件
+	PAMService string `yaml:"pam_service"`
+
+	// OIDCIssuerURL 为 OpenID Connect 身份提供者的 issuer，设置后登录页提供单点登录。
+	// 使用带 PKCE 的授权码流程，OIDCRedirectURL 为前端的 /oidc/callback 页面，需要在身份提供者处登记；
+	// 没有 client secret 时作为公开客户端
+	OIDCIssuerURL        string   `yaml:"oidc_issuer_url"`
+	OIDCClientID         string   `yaml:"oidc_client_id"`
+	OIDCClientSecret     string   `yaml:"oidc_client_secret"`
+	OIDCClientSecretFile string   `yaml:"oidc_client_secret_file"`
+	OIDCRedirectURL      string   `yaml:"oidc_redirect_url"`
+	OIDCScopes           []string `yaml:"oidc_scopes"`
+	// OIDCUsernameClaim 为 ID token 中对应集群用户名的声明，默认为用户不能修改的 sub；
+	// OIDCUsernamePattern 为必填的正则表达式，其唯一的捕获组为用户名，例如 ^([^@]+)@example\.edu$，不匹配的用户不能登录。
+	// 映射得到的用户名必须存在于 role_source 对应的用户目录中
+	OIDCUsernameClaim   string `yaml:"oidc_username_claim"`
+	OIDCUsernamePattern string `yaml:"oidc_username_pattern"`
+	// OIDCMinUID 为单点登录允许的最小 uid，本机上 uid 更小的 root 和系统账号不能通过单点登录使用
+	OIDCMinUID int `yaml:"oidc_min_uid"`
+
+	LDAPServerHost        string        `yaml:"ldap_server_host"`
+	LDAPServerPort        int           `yaml:"ldap_server_port"`
+	LDAPAdminDN           string        `yaml:"ldap_admin_dn"`
+	LDAPAdminPassword     string        `yaml:"ldap_admin_password"`
+	LDAPAdminPasswordFile string        `yaml:"ldap_admin_password_file"`
+	LDAPSearchBaseDN      string        `yaml:"ldap_search_base_dn"`
+	LDAPUserSearchFilter  string        `yaml:"ldap_user_search_filter"`
+	SlurmAPIHost          string        `yaml:"slurm_api_host"`
+	JWTSecretKey          string        `yaml:"jwt_secret_key"`
+	JWTSecretKeyFile      string        `yaml:"jwt_secret_key_file"`
+	JWTIssuer             string        `yaml:"jwt_issuer"`
+	JWTDuration           time.Duration `yaml:"jwt_duration"`
+	// RefreshTokenDuration 为一次登录的最长有效期，期间前端凭 refresh token 换取新的短期 access token
+	RefreshTokenDuration  time.Duration `yaml:"refresh_token_duration"`
+	SlurmTokenLifespanSec string        `yaml:"slurm_token_lifespan_sec"`
+	ServerPort            string        `yaml:"server_port"`
+	JobConnectLogPattern  string        `yaml:"job_connect_log_pattern"`
+	JobInfoLogPattern     string        `yaml:"job_info_log_pattern"`
+
+	// RoleSource 为查询用户所属组的方式："ldap" 查询 LDAP 目录，"local" 执行本机的 groups 命令。
+	// 配置文件中未设置时，auth_backends 包含 ldap 则为 "ldap"，否则为 "local"。
+	// RoleGroups 为角色到组名的映射，用户属于多个角色的组时取权限最高的角色，不属于任何组时为 user。
+	// 角色在每次签发 access token 时重新计算。
+	RoleSource string              `yaml:"role_source"`
+	RoleGroups map[string][]string `yaml:"role_groups"`
+
+	// LDAPGroupMembership 为 LDAP 中组成员关系的表示方式："memberOf" 读取用户条目的 memberOf 属性，
+	// "posixGroup" 使用 LDAPGroupSearchFilter 在 LDAPGroupSearchBaseDN（为空时为 ldap_search_base_dn）下搜索组
+	LDAPGroupMembership   string `yaml:"ldap_group_membership"`
+	LDAPGroupSearchBaseDN string `yaml:"ldap_group_search_base_dn"`
+	LDAPGroupSearchFilter string `yaml:"ldap_group_search_filter"`
+
+	// LDAPServers 为按优先级排列的 LDAP 服务器（host 或 host:port，未写端口时使用 ldap_server_port），
+	// 为空时只使用 ldap_server_host。不可达的服务器会被暂时跳过，并在健康检查恢复后重新使用。
+	LDAPServers []string `yaml:"ldap_servers"`
+	// LDAPTimeout 限制建立连接和每次 LDAP 操作的时间；LDAPPoolSize 为每个服务器保留的空闲连接数
+	LDAPTimeout             time.Duration `yaml:"ldap_timeout"`
+	LDAPPoolSize            int           `yaml:"ldap_pool_size"`
+	LDAPHealthCheckInterval time.Duration `yaml:"ldap_health_check_interval"`
+
+	// LDAPTLSMode 为连接 LDAP 的方式："ldaps" 直接建立 TLS 连接，"starttls" 在明文连接上升级为 TLS，
+	// "none" 为明文连接，只应在测试环境中使用。TLS 握手或证书校验失败时认证失败，不会退回明文。
+	LDAPTLSMode string `yaml:"ldap_tls_mode"`
+	// LDAPTLSCAFile 为校验服务器证书的 PEM 格式 CA 证书，为空时使用系统的 CA；
+	// LDAPTLSServerName 为证书中的服务器名，为空时使用 ldap_server_host
+	LDAPTLSCAFile     string `yaml:"ldap_tls_ca_file"`
+	LDAPTLSServerName string `yaml:"ldap_tls_server_name"`
+	// LDAPTLSMinVersion 为允许的最低 TLS 版本，"1.2" 或 "1.3"
+	LDAPTLSMinVersion string `yaml:"ldap_tls_min_version"`
+	// LDAPTLSInsecureSkipVerify 为 true 时不校验服务器证书，仅用于使用自签名证书的实验环境
+	LDAPTLSInsecureSkipVerify bool `yaml:"ldap_tls_insecure_skip_verify"`
+
+	// SlurmCLIFallback 为 true 时，slurmrestd 不可达会自动改用 squeue/scontrol 等命令获取数据
+	SlurmCLIFallback         bool          `yaml:"slurm_cli_fallback"`
+	SlurmHealthCheckInterval time.Duration `yaml:"slurm_health_check_interval"`
+
+	// CommandTimeout 和 CommandMaxOutputBytes 是外部命令（scontrol、sacctmgr 等）的默认执行限制
+	CommandTimeout        time.Duration `yaml:"command_timeout"`
+	CommandMaxOutputBytes int           `yaml:"command_max_output_bytes"`
+	// CommandLoginEnvironment 为 true 时，以用户身份执行的命令使用该用户登录 shell 的完整环境变量，
+	// 每个登录会话只采集一次
+	CommandLoginEnvironment bool `yaml:"command_login_environment"`
+
+	// SlurmTokenRenewBefore 为 Slurm token 到期前多久自动续期；
+	// SlurmTokenIdleTimeout 为用户多久没有访问后不再续期并删除其 token
+	SlurmTokenRenewBefore time.Duration `yaml:"slurm_token_renew_before"`
+	SlurmTokenIdleTimeout time.Duration `yaml:"slurm_token_idle_timeout"`
+
+	// TokenEncryptionSecrets 是加密保存 Slurm token 和 TOTP 密钥的主密钥，第一个用于加密，其余只用于解密，
+	// 轮换时将新密钥放在最前面并重启，启动时会用新密钥重新加密所有 token。
+	// TokenEncryptionSecretsFile 中每行一个密钥，优先于直接配置的值。两者都为空时 Slurm token 的密钥由 jwt_secret_key 派生，
+	// 且不能启用 TOTP 两步验证。
+	TokenEncryptionSecrets     []string `yaml:"token_encryption_secrets"`
+	TokenEncryptionSecretsFile string   `yaml:"token_encryption_secrets_file"`
+
+	// StorePath 为保存 Slurm token 和会话元数据的 bbolt 数据库文件，为空时只保存在内存中
+	StorePath string `yaml:"store_path"`
+
+	// 密码登录失败后，同一用户名或同一 IP 的下一次尝试需要等待 LoginBackoffBase，之后每次失败翻倍；
+	// 用户名连续失败 LoginMaxFailures 次、IP 连续失败 LoginMaxFailuresPerIP 次后锁定 LoginLockoutDuration，
+	// 超过 LoginLockoutDuration 没有失败时计数清零。LoginBackoffBase 为 0 时不退避，只按次数锁定
+	LoginMaxFailures      int           `yaml:"login_max_failures"`
+	LoginMaxFailuresPerIP int           `yaml:"login_max_failures_per_ip"`
+	LoginLockoutDuration  time.Duration `yaml:"login_lockout_duration"`
+	LoginBackoffBase      time.Duration `yaml:"login_backoff_base"`
+	// TrustedProxies 为可信的反向代理地址或网段，只有来自这些地址的请求才采用 X-Forwarded-For 中的客户端 IP。
+	// TrustedProxies 和 AuditRetention 修改后需要重启才能生效
+	TrustedProxies []string `yaml:"trusted_proxies"`
+	// AuditRetention 为审计日志的保留时间
+	AuditRetention time.Duration `yaml:"audit_retention"`
+
+	// 用户可以在 dashboard 中启用 TOTP 两步验证，启用后密码登录和单点登录都需要输入验证码。
+	// TOTPRequiredRoles 中的角色必须启用，未启用的用户在登录时先完成绑定；TOTPIssuer 为验证器应用中显示的名称
+	TOTPIssuer        string   `yaml:"totp_issuer"`
+	TOTPRequiredRoles []string `yaml:"totp_required_roles"`
+
+	// SessionCookies 为 true 时，浏览器登录取得的 access token 和 refresh token 只保存在 HttpOnly cookie 中，
+	// 响应中不再返回；使用 cookie 认证的修改类请求需要在 X-CSRF-Token 头中带上登录时返回的 CSRF token。
+	// Authorization: Bearer 仍然可以使用，供 API key 和脚本调用
+	SessionCookies bool `yaml:"session_cookies"`
+	// CookieSecure 为 cookie 的 Secure 属性，只在通过 HTTP 访问的测试环境中关闭；
+	// CookieSameSite 为 "strict"、"lax" 或 "none"，"none" 要求开启 CookieSecure
+	CookieSecure   bool   `yaml:"cookie_secure"`
+	CookieSameSite string `yaml:"cookie_same_site"`
+	// CORSAllowedOrigins 为允许跨域访问 API 的前端地址，例如 http://localhost:5173，
+	// 为空时只允许与后端同源的页面访问，修改后需要重启才能生效
+	CORSAllowedOrigins []string `yaml:"cors_allowed_origins"`
+}
+
+// Default 返回不包含任何站点相关信息和密钥的默认配置
+func Default() *Config {
 	return &Config{
-		LDAPServerHost:       "10.20.20.20",
+		AuthBackends: []string{AuthBackendLDAP},
+		PAMService:   "login",
+
+		OIDCScopes:        []string{"openid", "profile", "email"},
+		OIDCUsernameClaim: "sub",
+		OIDCMinUID:        1000,
+
 		LDAPServerPort:       389,
-		LDAPAdminDN:          "cn=admin,dc=pushihao,dc=com",
-		LDAPAdminPassword:    "root",
-		LDAPSearchBaseDN:     "dc=pushihao,dc=com",
 		LDAPUserSearchFilter: "(uid=%s)",
 
-		JWTSecretKey: "2f650e45-73e4-4b36-88f7-psh-521-c3b3829d3542",
-		JWTIssuer:    "slurm-dashboard-backend",
-		JWTDuration:  time.Hour * 24,
+		JWTIssuer:            "slurm-dashboard-backend",
+		JWTDuration:          15 * time.Minute,
+		RefreshTokenDuration: 24 * time.Hour,
 
-		SlurmAPIHost:          "http://10.20.20.2:6820",
 		SlurmTokenLifespanSec: "90000", // 25h
 
 		JobConnectLogPattern: ".slurm/connect-%s.log",
 		JobInfoLogPattern:    ".slurm/info-%s.log",
 
+		RoleSource:            RoleSourceLDAP,
+		LDAPGroupMembership:   GroupMembershipPosixGroup,
+		LDAPGroupSearchFilter: "(&(objectClass=posixGroup)(memberUid=%s))",
+
+		LDAPTimeout:             5 * time.Second,
+		LDAPPoolSize:            4,
+		LDAPHealthCheckInterval: 30 * time.Second,
+
+		LDAPTLSMode:       LDAPTLSStartTLS,
+		LDAPTLSMinVersion: "1.2",
+
 		ServerPort: "80",
+
+		SlurmCLIFallback:         true,
+		SlurmHealthCheckInterval: 15 * time.Second,
+
+		CommandTimeout:        30 * time.Second,
+		CommandMaxOutputBytes: 4 << 20,
+
+		SlurmTokenRenewBefore: time.Hour,
+		SlurmTokenIdleTimeout: 24 * time.Hour,
+
+		LoginMaxFailures:      5,
+		LoginMaxFailuresPerIP: 20,
+		LoginLockoutDuration:  15 * time.Minute,
+		LoginBackoffBase:      time.Second,
+		TrustedProxies:        []string{"127.0.0.1", "::1"},
+		AuditRetention:        90 * 24 * time.Hour,
+
+		TOTPIssuer: "Slurm Dashboard",
+
+		CookieSecure:   true,
+		CookieSameSite: SameSiteStrict,
+	}
+}
+
+// LoadConfig 按 默认值 -> 配置文件 -> 环境变量 -> 密钥文件 的顺序加载配置，并在返回前进行校验。
+// path 为空时只使用默认值和环境变量。
+func LoadConfig(path string) (*Config, error) {
+	cfg := Default()
+	// role_source 的默认值取决于 auth_backends，先清空以便判断是否配置过
+	cfg.RoleSource = ""
+
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		// 空配置文件会返回 io.EOF，视为没有任何覆盖项
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+	}
+
+	if err := applyEnv(cfg, os.LookupEnv); err != nil {
+		return nil, err
+	}
+	if cfg.RoleSource == "" {
+		cfg.RoleSource = cfg.defaultRoleSource()
+	}
+
+	if err := cfg.loadSecretFiles(); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// envSetter 描述了一个环境变量到配置字段的映射
+type envSetter struct {
+	name string
+	set  func(cfg *Config, value string) error
+}
+
+func stringVar(field func(*Config) *string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		*field(cfg) = value
+		return nil
+	}
+}
+
+// stringsVar 将逗号分隔的环境变量解析为字符串列表
+func stringsVar(field func(*Config) *[]string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		var values []string
+		for _, v := range strings.Split(value, ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		*field(cfg) = values
+		return nil
+	}
+}
+
+// roleGroupsVar 解析形如 "admin=hpc-admins|wheel;operator=hpc-ops" 的环境变量
+func roleGroupsVar(field func(*Config) *map[string][]string) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		mapping := make(map[string][]string)
+		for _, entry := range strings.Split(value, ";") {
+			if entry = strings.TrimSpace(entry); entry == "" {
+				continue
+			}
+			role, groups, ok := strings.Cut(entry, "=")
+			if !ok {
+				return fmt.Errorf("invalid role mapping %q, expected role=group1|group2", entry)
+			}
+			for _, group := range strings.Split(groups, "|") {
+				if group = strings.TrimSpace(group); group != "" {
+					mapping[strings.TrimSpace(role)] = append(mapping[strings.TrimSpace(role)], group)
+				}
+			}
+		}
+		*field(cfg) = mapping
+		return nil
+	}
+}
+
+func intVar(field func(*Config) *int) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q: %w", value, err)
+		}
+		*field(cfg) = n
+		return nil
+	}
+}
+
+func boolVar(field func(*Config) *bool) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q: %w", value, err)
+		}
+		*field(cfg) = b
+		return nil
+	}
+}
+
+func durationVar(field func(*Config) *time.Duration) func(*Config, string) error {
+	return func(cfg *Config, value string) error {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", value, err)
+		}
+		*field(cfg) = d
+		return nil
+	}
+}
+
+var envSetters = []envSetter{
+	{"AUTH_BACKENDS", stringsVar(func(c *Config) *[]string { return &c.AuthBackends })},
+	{"HTPASSWD_FILE", stringVar(func(c *Config) *string { return &c.HtpasswdFile })},
+	{"PAM_SERVICE", stringVar(func(c *Config) *string { return &c.PAMService })},
+	{"OIDC_ISSUER_URL", stringVar(func(c *Config) *string { return &c.OIDCIssuerURL })},
+	{"OIDC_CLIENT_ID", stringVar(func(c *Config) *string { return &c.OIDCClientID })},
+	{"OIDC_CLIENT_SECRET", stringVar(func(c *Config) *string { return &c.OIDCClientSecret })},
+	{"OIDC_CLIENT_SECRET_FILE", stringVar(func(c *Config) *string { return &c.OIDCClientSecretFile })},
+	{"OIDC_REDIRECT_URL", stringVar(func(c *Config) *string { return &c.OIDCRedirectURL })},
+	{"OIDC_SCOPES", stringsVar(func(c *Config) *[]string { return &c.OIDCScopes })},
+	{"OIDC_USERNAME_CLAIM", stringVar(func(c *Config) *string { return &c.OIDCUsernameClaim })},
+	{"OIDC_USERNAME_PATTERN", stringVar(func(c *Config) *string { return &c.OIDCUsernamePattern })},
+	{"OIDC_MIN_UID", intVar(func(c *Config) *int { return &c.OIDCMinUID })},
+	{"LDAP_SERVER_HOST", stringVar(func(c *Config) *string { return &c.LDAPServerHost })},
+	{"LDAP_SERVER_PORT", intVar(func(c *Config) *int { return &c.LDAPServerPort })},
+	{"LDAP_ADMIN_DN", stringVar(func(c *Config) *string { return &c.LDAPAdminDN })},
+	{"LDAP_ADMIN_PASSWORD", stringVar(func(c *Config) *string { return &c.LDAPAdminPassword })},
+	{"LDAP_ADMIN_PASSWORD_FILE", stringVar(func(c *Config) *string { return &c.LDAPAdminPasswordFile })},
+	{"LDAP_SEARCH_BASE_DN", stringVar(func(c *Config) *string { return &c.LDAPSearchBaseDN })},
+	{"LDAP_USER_SEARCH_FILTER", stringVar(func(c *Config) *string { return &c.LDAPUserSearchFilter })},
+	{"SLURM_API_HOST", stringVar(func(c *Config) *string { return &c.SlurmAPIHost })},
+	{"JWT_SECRET_KEY", stringVar(func(c *Config) *string { return &c.JWTSecretKey })},
+	{"JWT_SECRET_KEY_FILE", stringVar(func(c *Config) *string { return &c.JWTSecretKeyFile })},
+	{"JWT_ISSUER", stringVar(func(c *Config) *string { return &c.JWTIssuer })},
+	{"JWT_DURATION", durationVar(func(c *Config) *time.Duration { return &c.JWTDuration })},
+	{"REFRESH_TOKEN_DURATION", durationVar(func(c *Config) *time.Duration { return &c.RefreshTokenDuration })},
+	{"SLURM_TOKEN_LIFESPAN_SEC", stringVar(func(c *Config) *string { return &c.SlurmTokenLifespanSec })},
+	{"SERVER_PORT", stringVar(func(c *Config) *string { return &c.ServerPort })},
+	{"JOB_CONNECT_LOG_PATTERN", stringVar(func(c *Config) *string { return &c.JobConnectLogPattern })},
+	{"JOB_INFO_LOG_PATTERN", stringVar(func(c *Config) *string { return &c.JobInfoLogPattern })},
+	{"ROLE_SOURCE", stringVar(func(c *Config) *string { return &c.RoleSource })},
+	{"ROLE_GROUPS", roleGroupsVar(func(c *Config) *map[string][]string { return &c.RoleGroups })},
+	{"LDAP_GROUP_MEMBERSHIP", stringVar(func(c *Config) *string { return &c.LDAPGroupMembership })},
+	{"LDAP_GROUP_SEARCH_BASE_DN", stringVar(func(c *Config) *string { return &c.LDAPGroupSearchBaseDN })},
+	{"LDAP_GROUP_SEARCH_FILTER", stringVar(func(c *Config) *string { return &c.LDAPGroupSearchFilter })},
+	{"LDAP_SERVERS", stringsVar(func(c *Config) *[]string { return &c.LDAPServers })},
+	{"LDAP_TIMEOUT", durationVar(func(c *Config) *time.Duration { return &c.LDAPTimeout })},
+	{"LDAP_POOL_SIZE", intVar(func(c *Config) *int { return &c.LDAPPoolSize })},
+	{"LDAP_HEALTH_CHECK_INTERVAL", durationVar(func(c *Config) *time.Duration { return &c.LDAPHealthCheckInterval })},
+	{"LDAP_TLS_MODE", stringVar(func(c *Config) *string { return &c.LDAPTLSMode })},
+	{"LDAP_TLS_CA_FILE", stringVar(func(c *Config) *string { return &c.LDAPTLSCAFile })},
+	{"LDAP_TLS_SERVER_NAME", stringVar(func(c *Config) *string { return &c.LDAPTLSServerName })},
+	{"LDAP_TLS_MIN_VERSION", stringVar(func(c *Config) *string { return &c.LDAPTLSMinVersion })},
+	{"LDAP_TLS_INSECURE_SKIP_VERIFY", boolVar(func(c *Config) *bool { return &c.LDAPTLSInsecureSkipVerify })},
+	{"SLURM_CLI_FALLBACK", boolVar(func(c *Config) *bool { return &c.SlurmCLIFallback })},
+	{"SLURM_HEALTH_CHECK_INTERVAL", durationVar(func(c *Config) *time.Duration { return &c.SlurmHealthCheckInterval })},
+	{"COMMAND_TIMEOUT", durationVar(func(c *Config) *time.Duration { return &c.CommandTimeout })},
+	{"COMMAND_MAX_OUTPUT_BYTES", intVar(func(c *Config) *int { return &c.CommandMaxOutputBytes })},
+	{"COMMAND_LOGIN_ENVIRONMENT", boolVar(func(c *Config) *bool { return &c.CommandLoginEnvironment })},
+	{"SLURM_TOKEN_RENEW_BEFORE", durationVar(func(c *Config) *time.Duration { return &c.SlurmTokenRenewBefore })},
+	{"SLURM_TOKEN_IDLE_TIMEOUT", durationVar(func(c *Config) *time.Duration { return &c.SlurmTokenIdleTimeout })},
+	{"TOKEN_ENCRYPTION_SECRETS", stringsVar(func(c *Config) *[]string { return &c.TokenEncryptionSecrets })},
+	{"TOKEN_ENCRYPTION_SECRETS_FILE", stringVar(func(c *Config) *string { return &c.TokenEncryptionSecretsFile })},
+	{"STORE_PATH", stringVar(func(c *Config) *string { return &c.StorePath })},
+	{"LOGIN_MAX_FAILURES", intVar(func(c *Config) *int { return &c.LoginMaxFailures })},
+	{"LOGIN_MAX_FAILURES_PER_IP", intVar(func(c *Config) *int { return &c.LoginMaxFailuresPerIP })},
+	{"LOGIN_LOCKOUT_DURATION", durationVar(func(c *Config) *time.Duration { return &c.LoginLockoutDuration })},
+	{"LOGIN_BACKOFF_BASE", durationVar(func(c *Config) *time.Duration { return &c.LoginBackoffBase })},
+	{"TRUSTED_PROXIES", stringsVar(func(c *Config) *[]string { return &c.TrustedProxies })},
+	{"AUDIT_RETENTION", durationVar(func(c *Config) *time.Duration { return &c.AuditRetention })},
+	{"TOTP_ISSUER", stringVar(func(c *Config) *string { return &c.TOTPIssuer })},
+	{"TOTP_REQUIRED_ROLES", stringsVar(func(c *Config) *[]string { return &c.TOTPRequiredRoles })},
+	{"SESSION_COOKIES", boolVar(func(c *Config) *bool { return &c.SessionCookies })},
+	{"COOKIE_SECURE", boolVar(func(c *Config) *bool { return &c.CookieSecure })},
+	{"COOKIE_SAME_SITE", stringVar(func(c *Config) *string { return &c.CookieSameSite })},
+	{"CORS_ALLOWED_ORIGINS", stringsVar(func(c *Config) *[]string { return &c.CORSAllowedOrigins })},
+}
+
+// applyEnv 使用 SLURM_DASHBOARD_* 环境变量覆盖配置
+func applyEnv(cfg *Config, lookup func(string) (string, bool)) error {
+	for _, s := range envSetters {
+		value, ok := lookup(EnvPrefix + s.name)
+		if !ok {
+			continue
+		}
+		if err := s.set(cfg, value); err != nil {
+			return fmt.Errorf("environment variable %s%s: %w", EnvPrefix, s.name, err)
+		}
+	}
+	return nil
+}
+
+// loadSecretFiles 从文件读取密钥，文件中的值优先于直接配置的值
+func (c *Config) loadSecretFiles() error {
+	secrets := []struct {
+		path   string
+		target *string
+		name   string
+	}{
+		{c.LDAPAdminPasswordFile, &c.LDAPAdminPassword, "ldap_admin_password_file"},
+		{c.JWTSecretKeyFile, &c.JWTSecretKey, "jwt_secret_key_file"},
+		{c.OIDCClientSecretFile, &c.OIDCClientSecret, "oidc_client_secret_file"},
+	}
+	for _, s := range secrets {
+		if s.path == "" {
+			continue
+		}
+		data, err := os.ReadFile(s.path)
+		if err != nil {
+			return fmt.Errorf("failed to read %s %s: %w", s.name, s.path, err)
+		}
+		*s.target = strings.TrimRight(string(data), "\r\n")
+	}
+
+	if c.TokenEncryptionSecretsFile != "" {
+		data, err := os.ReadFile(c.TokenEncryptionSecretsFile)
+		if err != nil {
+			return fmt.Errorf("failed to read token_encryption_secrets_file %s: %w", c.TokenEncryptionSecretsFile, err)
+		}
+		c.TokenEncryptionSecrets = nil
+		for _, line := range strings.Split(string(data), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				c.TokenEncryptionSecrets = append(c.TokenEncryptionSecrets, line)
+			}
+		}
+	}
+	return nil
+}
+
+// TokenEncryptionKeys 返回用于派生 Slurm token 加密密钥的主密钥，未配置时使用 jwt_secret_key。
+// TOTP 密钥不使用该回退，只用 TokenEncryptionSecrets 加密
+func (c *Config) TokenEncryptionKeys() []string {
+	if len(c.TokenEncryptionSecrets) > 0 {
+		return c.TokenEncryptionSecrets
+	}
+	return []string{c.JWTSecretKey}
+}
+
+// SlurmTokenLifespan 返回 slurm_token_lifespan_sec 对应的时长，格式错误时返回 0
+func (c *Config) SlurmTokenLifespan() time.Duration {
+	sec, err := strconv.Atoi(c.SlurmTokenLifespanSec)
+	if err != nil {
+		return 0
+	}
+	return time.Duration(sec) * time.Second
+}
+
+// UsesAuthBackend 判断 auth_backends 中是否启用了 backend
+func (c *Config) UsesAuthBackend(backend string) bool {
+	for _, b := range c.AuthBackends {
+		if b == backend {
+			return true
+		}
+	}
+	return false
+}
+
+// defaultRoleSource 返回未配置 role_source 时使用的值：用 LDAP 认证时从 LDAP 查询组，
+// 只使用 htpasswd 或 pam 的部署不应被要求配置 LDAP
+func (c *Config) defaultRoleSource() string {
+	if c.UsesAuthBackend(AuthBackendLDAP) {
+		return RoleSourceLDAP
+	}
+	return RoleSourceLocal
+}
+
+// UsesLDAP 判断是否需要连接 LDAP：启用了 ldap 认证后端，或者从 LDAP 查询用户所属的组
+func (c *Config) UsesLDAP() bool {
+	return c.UsesAuthBackend(AuthBackendLDAP) || c.RoleSource == RoleSourceLDAP
+}
+
+// TOTPRequired 判断 role 是否必须启用 TOTP 两步验证
+func (c *Config) TOTPRequired(role string) bool {
+	for _, r := range c.TOTPRequiredRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// OIDCEnabled 判断是否配置了 OpenID Connect 单点登录
+func (c *Config) OIDCEnabled() bool {
+	return c.OIDCIssuerURL != ""
+}
+
+// LDAPServerAddresses 按优先级返回所有 LDAP 服务器的 host:port
+func (c *Config) LDAPServerAddresses() []string {
+	if len(c.LDAPServers) == 0 {
+		return []string{net.JoinHostPort(c.LDAPServerHost, strconv.Itoa(c.LDAPServerPort))}
+	}
+	addrs := make([]string, 0, len(c.LDAPServers))
+	for _, server := range c.LDAPServers {
+		if host, port, err := splitLDAPServer(server, c.LDAPServerPort); err == nil {
+			addrs = append(addrs, net.JoinHostPort(host, strconv.Itoa(port)))
+		}
+	}
+	return addrs
+}
+
+// splitLDAPServer 解析 ldap_servers 中的一项，没有端口时使用 defaultPort
+func splitLDAPServer(server string, defaultPort int) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(server)
+	if err != nil {
+		// 没有端口（包括不带方括号的 IPv6 地址）
+		return strings.Trim(server, "[]"), defaultPort, nil
+	}
+	port, err := strconv.Atoi(portStr)
+	return host, port, err
+}
+
+// Validate 检查配置是否完整且格式正确，启动时任何一项不满足都会拒绝运行
+func (c *Config) Validate() error {
+	var errs []error
+
+	required := []struct {
+		name  string
+		value string
+	}{
+		{"slurm_api_host", c.SlurmAPIHost},
+		{"jwt_secret_key", c.JWTSecretKey},
+		{"jwt_issuer", c.JWTIssuer},
+		{"server_port", c.ServerPort},
+	}
+	for _, r := range required {
+		if strings.TrimSpace(r.value) == "" {
+			errs = append(errs, fmt.Errorf("%s is required", r.name))
+		}
+	}
+
+	if len(c.AuthBackends) == 0 {
+		errs = append(errs, errors.New("auth_backends must not be empty"))
+	}
+	seen := make(map[string]bool)
+	for _, backend := range c.AuthBackends {
+		switch backend {
+		case AuthBackendLDAP, AuthBackendPAM:
+		case AuthBackendHtpasswd:
+			if c.HtpasswdFile == "" {
+				errs = append(errs, errors.New("htpasswd_file is required by the htpasswd auth backend"))
+			} else if _, err := os.Stat(c.HtpasswdFile); err != nil {
+				errs = append(errs, fmt.Errorf("htpasswd_file: %w", err))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("auth_backends contains unknown backend %q, expected %q, %q or %q", backend, AuthBackendLDAP, AuthBackendHtpasswd, AuthBackendPAM))
+		}
+		if seen[backend] {
+			errs = append(errs, fmt.Errorf("auth_backends contains %q more than once", backend))
+		}
+		seen[backend] = true
+	}
+	if c.UsesAuthBackend(AuthBackendPAM) && strings.TrimSpace(c.PAMService) == "" {
+		errs = append(errs, errors.New("pam_service is required by the pam auth backend"))
+	}
+
+	if c.OIDCEnabled() {
+		for _, u := range []struct{ name, value string }{
+			{"oidc_issuer_url", c.OIDCIssuerURL},
+			{"oidc_redirect_url", c.OIDCRedirectURL},
+		} {
+			if parsed, err := url.Parse(u.value); err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				errs = append(errs, fmt.Errorf("%s %q must be an http(s) URL", u.name, u.value))
+			}
+		}
+		if c.OIDCClientID == "" {
+			errs = append(errs, errors.New("oidc_client_id is required when oidc_issuer_url is set"))
+		}
+		if c.OIDCUsernameClaim == "" {
+			errs = append(errs, errors.New("oidc_username_claim is required when oidc_issuer_url is set"))
+		}
+		hasOpenID := false
+		for _, scope := range c.OIDCScopes {
+			hasOpenID = hasOpenID || scope == "openid"
+		}
+		if !hasOpenID {
+			errs = append(errs, errors.New("oidc_scopes must include \"openid\""))
+		}
+		if c.OIDCUsernamePattern == "" {
+			errs = append(errs, errors.New("oidc_username_pattern is required when oidc_issuer_url is set"))
+		} else if re, err := regexp.Compile(c.OIDCUsernamePattern); err != nil {
+			errs = append(errs, fmt.Errorf("oidc_username_pattern: %w", err))
+		} else if re.NumSubexp() != 1 {
+			errs = append(errs, errors.New("oidc_username_pattern must have exactly one capture group"))
+		}
+		if c.OIDCMinUID < 1 {
+			errs = append(errs, errors.New("oidc_min_uid must be at least 1"))
+		}
+	}
+
+	// 只使用 htpasswd 或 PAM 认证、并从本机查询组时不需要任何 LDAP 配置
+	if c.UsesLDAP() {
+		ldapRequired := []struct {
+			name  string
+			value string
+		}{
+			{"ldap_admin_dn", c.LDAPAdminDN},
+			{"ldap_admin_password", c.LDAPAdminPassword},
+			{"ldap_search_base_dn", c.LDAPSearchBaseDN},
+		}
+		for _, r := range ldapRequired {
+			if strings.TrimSpace(r.value) == "" {
+				errs = append(errs, fmt.Errorf("%s is required", r.name))
+			}
+		}
+		if strings.TrimSpace(c.LDAPServerHost) == "" && len(c.LDAPServers) == 0 {
+			errs = append(errs, errors.New("ldap_server_host or ldap_servers is required"))
+		}
+	}
+	for _, server := range c.LDAPServers {
+		if host, port, err := splitLDAPServer(server, c.LDAPServerPort); err != nil || host == "" || port <= 0 || port > 65535 {
+			errs = append(errs, fmt.Errorf("ldap_servers entry %q must be host or host:port", server))
+		}
+	}
+	if c.LDAPTimeout <= 0 {
+		errs = append(errs, errors.New("ldap_timeout must be positive"))
+	}
+	if c.LDAPPoolSize < 0 {
+		errs = append(errs, errors.New("ldap_pool_size must not be negative"))
+	}
+	if c.LDAPHealthCheckInterval <= 0 {
+		errs = append(errs, errors.New("ldap_health_check_interval must be positive"))
+	}
+	if c.LDAPServerPort <= 0 || c.LDAPServerPort > 65535 {
+		errs = append(errs, fmt.Errorf("ldap_server_port %d is out of range", c.LDAPServerPort))
+	}
+	if c.JWTSecretKey != "" && len(c.JWTSecretKey) < 32 {
+		errs = append(errs, errors.New("jwt_secret_key must be at least 32 characters"))
+	}
+	if c.JWTDuration <= 0 {
+		errs = append(errs, errors.New("jwt_duration must be positive"))
+	} else if c.RefreshTokenDuration < c.JWTDuration {
+		errs = append(errs, errors.New("refresh_token_duration must not be shorter than jwt_duration"))
+	}
+	if c.SlurmHealthCheckInterval <= 0 {
+		errs = append(errs, errors.New("slurm_health_check_interval must be positive"))
+	}
+	if c.CommandTimeout <= 0 {
+		errs = append(errs, errors.New("command_timeout must be positive"))
+	}
+	if c.CommandMaxOutputBytes <= 0 {
+		errs = append(errs, errors.New("command_max_output_bytes must be positive"))
+	}
+	if port, err := strconv.Atoi(c.ServerPort); c.ServerPort != "" && (err != nil || port <= 0 || port > 65535) {
+		errs = append(errs, fmt.Errorf("server_port %q is not a valid port", c.ServerPort))
+	}
+	if sec, err := strconv.Atoi(c.SlurmTokenLifespanSec); err != nil || sec <= 0 {
+		errs = append(errs, fmt.Errorf("slurm_token_lifespan_sec %q must be a positive integer", c.SlurmTokenLifespanSec))
+	} else if c.SlurmTokenRenewBefore <= 0 || c.SlurmTokenRenewBefore >= c.SlurmTokenLifespan() {
+		errs = append(errs, errors.New("slurm_token_renew_before must be positive and shorter than slurm_token_lifespan_sec"))
+	}
+	for i, secret := range c.TokenEncryptionSecrets {
+		if len(secret) < 32 {
+			errs = append(errs, fmt.Errorf("token_encryption_secrets[%d] must be at least 32 characters", i))
+		}
+	}
+	if c.SlurmTokenIdleTimeout <= 0 {
+		errs = append(errs, errors.New("slurm_token_idle_timeout must be positive"))
+	}
+	if c.SlurmAPIHost != "" {
+		u, err := url.Parse(c.SlurmAPIHost)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, fmt.Errorf("slurm_api_host %q must be an http(s) URL", c.SlurmAPIHost))
+		}
+	}
+	if strings.Count(c.LDAPUserSearchFilter, "%s") != 1 {
+		errs = append(errs, errors.New("ldap_user_search_filter must contain exactly one %s"))
+	}
+	switch c.LDAPTLSMode {
+	case LDAPTLSNone, LDAPTLSLDAPS, LDAPTLSStartTLS:
+	default:
+		errs = append(errs, fmt.Errorf("ldap_tls_mode %q must be %q, %q or %q", c.LDAPTLSMode, LDAPTLSLDAPS, LDAPTLSStartTLS, LDAPTLSNone))
+	}
+	if c.LDAPTLSMinVersion != "1.2" && c.LDAPTLSMinVersion != "1.3" {
+		errs = append(errs, fmt.Errorf("ldap_tls_min_version %q must be \"1.2\" or \"1.3\"", c.LDAPTLSMinVersion))
+	}
+	if c.LDAPTLSCAFile != "" {
+		if _, err := os.Stat(c.LDAPTLSCAFile); err != nil {
+			errs = append(errs, fmt.Errorf("ldap_tls_ca_file: %w", err))
+		}
+	}
+	if c.RoleSource != RoleSourceLDAP && c.RoleSource != RoleSourceLocal {
+		errs = append(errs, fmt.Errorf("role_source %q must be %q or %q", c.RoleSource, RoleSourceLDAP, RoleSourceLocal))
+	}
+	for role := range c.RoleGroups {
+		if _, ok := roleNames[role]; !ok {
+			errs = append(errs, fmt.Errorf("role_groups contains unknown role %q", role))
+		}
+	}
+	if c.RoleSource == RoleSourceLDAP {
+		switch c.LDAPGroupMembership {
+		case GroupMembershipMemberOf:
+		case GroupMembershipPosixGroup:
+			if strings.Count(c.LDAPGroupSearchFilter, "%s") != 1 {
+				errs = append(errs, errors.New("ldap_group_search_filter must contain exactly one %s"))
+			}
+		default:
+			errs = append(errs, fmt.Errorf("ldap_group_membership %q must be %q or %q", c.LDAPGroupMembership, GroupMembershipMemberOf, GroupMembershipPosixGroup))
+		}
+	}
+	if c.LoginMaxFailures <= 0 || c.LoginMaxFailuresPerIP <= 0 {
+		errs = append(errs, errors.New("login_max_failures and login_max_failures_per_ip must be positive"))
+	}
+	if c.LoginLockoutDuration <= 0 {
+		errs = append(errs, errors.New("login_lockout_duration must be positive"))
+	}
+	if c.LoginBackoffBase < 0 || c.LoginBackoffBase > c.LoginLockoutDuration {
+		errs = append(errs, errors.New("login_backoff_base must not be negative or longer than login_lockout_duration"))
+	}
+	for _, proxy := range c.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				errs = append(errs, fmt.Errorf("trusted_proxies entry %q is not an IP address or CIDR", proxy))
+			}
+		}
+	}
+	if c.AuditRetention <= 0 {
+		errs = append(errs, errors.New("audit_retention must be positive"))
+	}
+	if c.TOTPIssuer == "" || strings.Contains(c.TOTPIssuer, ":") {
+		errs = append(errs, errors.New("totp_issuer must not be empty or contain ':'"))
+	}
+	for _, role := range c.TOTPRequiredRoles {
+		if _, ok := roleNames[role]; !ok {
+			errs = append(errs, fmt.Errorf("totp_required_roles contains unknown role %q", role))
+		}
+	}
+	if len(c.TOTPRequiredRoles) > 0 && len(c.TokenEncryptionSecrets) == 0 {
+		errs = append(errs, errors.New("totp_required_roles requires token_encryption_secrets, TOTP secrets are not encrypted with a key derived from jwt_secret_key"))
+	}
+	switch c.CookieSameSite {
+	case SameSiteStrict, SameSiteLax:
+	case SameSiteNone:
+		if !c.CookieSecure {
+			errs = append(errs, errors.New("cookie_same_site \"none\" requires cookie_secure"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("cookie_same_site %q must be %q, %q or %q", c.CookieSameSite, SameSiteStrict, SameSiteLax, SameSiteNone))
+	}
+	for _, origin := range c.CORSAllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			errs = append(errs, fmt.Errorf("cors_allowed_origins entry %q must be an http(s) origin such as https://dashboard.example.com", origin))
+		}
+	}
+	if strings.Count(c.JobConnectLogPattern, "%s") != 1 {
+		errs = append(errs, errors.New("job_connect_log_pattern must contain exactly one %s"))
+	}
+	if strings.Count(c.JobInfoLogPattern, "%s") != 1 {
+		errs = append(errs, errors.New("job_info_log_pattern must contain exactly one %s"))
+	} else if !strings.Contains(filepath.Base(c.JobInfoLogPattern), "%s") {
+		errs = append(errs, errors.New("job_info_log_pattern must have %s in the file name, not the directory"))
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("invalid configuration: %w", errors.Join(errs...))
 	}
+	return nil
 }
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const validConfig = `
+ldap_server_host: ldap.example.com
+ldap_admin_dn: cn=admin,dc=example,dc=com
+ldap_search_base_dn: dc=example,dc=com
+slurm_api_host: http://slurm.example.com:6820
+jwt_duration: 12h
+`
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoadConfigLayers(t *testing.T) {
+	dir := t.TempDir()
+	secret := writeFile(t, dir, "jwt_secret", "a-secret-that-is-definitely-long-enough\n")
+	path := writeFile(t, dir, "config.yaml", validConfig+"jwt_secret_key_file: "+secret+"\n")
+
+	t.Setenv(EnvPrefix+"LDAP_ADMIN_PASSWORD", "from-env")
+	t.Setenv(EnvPrefix+"SERVER_PORT", "8080")
+	t.Setenv(EnvPrefix+"ROLE_GROUPS", "admin=hpc-admins|wheel; operator=hpc-ops")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if cfg.JWTDuration != 12*time.Hour {
+		t.Errorf("file value not applied, jwt_duration=%v", cfg.JWTDuration)
+	}
+	if cfg.LDAPAdminPassword != "from-env" || cfg.ServerPort != "8080" {
+		t.Errorf("environment overrides not applied: %+v", cfg)
+	}
+	if cfg.JWTSecretKey != "a-secret-that-is-definitely-long-enough" {
+		t.Errorf("secret file not applied or not trimmed: %q", cfg.JWTSecretKey)
+	}
+	if admins := cfg.RoleGroups["admin"]; len(admins) != 2 || admins[1] != "wheel" || len(cfg.RoleGroups["operator"]) != 1 {
+		t.Errorf("role_groups environment override not parsed: %v", cfg.RoleGroups)
+	}
+	if cfg.JobInfoLogPattern != ".slurm/info-%s.log" || cfg.RoleSource != RoleSourceLDAP {
+		t.Errorf("default not kept: %q, role_source=%q", cfg.JobInfoLogPattern, cfg.RoleSource)
+	}
+}
+
+func TestLoadConfigRejectsInvalidValues(t *testing.T) {
+	dir := t.TempDir()
+	tests := map[string]string{
+		"job_info_log_pattern":              "job_info_log_pattern: .slurm/info.log\n",
+		"ldap_admin_password":               "",
+		"slurm_api_host":                    "slurm_api_host: slurm.example.com\n",
+		"field not found":                   "unknown_field: 1\n",
+		"job_connect_log_pattern":           "job_connect_log_pattern: '%s/%s.log'\n",
+		"unknown role":                      "role_groups: {superuser: [wheel]}\n",
+		"ldap_group_membership":             "ldap_group_membership: nested\n",
+		"ldap_tls_mode":                     "ldap_tls_mode: ssl\n",
+		"ldap_tls_min_version":              "ldap_tls_min_version: \"1.0\"\n",
+		"unknown backend":                   "auth_backends: [ldap, kerberos]\n",
+		"htpasswd_file":                     "auth_backends: [htpasswd]\n",
+		"oidc_client_id":                    "oidc_issuer_url: https://idp.example.com\noidc_redirect_url: https://dashboard.example.com/oidc/callback\n",
+		"capture group":                     "oidc_issuer_url: https://idp.example.com\noidc_client_id: dashboard\noidc_redirect_url: https://dashboard.example.com/oidc/callback\noidc_username_pattern: '.*@example.edu'\n",
+		"oidc_username_pattern":             "oidc_issuer_url: https://idp.example.com\noidc_client_id: dashboard\noidc_redirect_url: https://dashboard.example.com/oidc/callback\n",
+		"oidc_min_uid":                      "oidc_issuer_url: https://idp.example.com\noidc_client_id: dashboard\noidc_redirect_url: https://dashboard.example.com/oidc/callback\noidc_username_pattern: '^([^@]+)@example.edu$'\noidc_min_uid: 0\n",
+		"login_backoff_base":                "login_backoff_base: 1h\n",
+		"trusted_proxies":                   "trusted_proxies: [proxy.example.com]\n",
+		"totp_required_roles":               "totp_required_roles: [root]\n",
+		"requires token_encryption_secrets": "totp_required_roles: [admin]\n",
+		"requires cookie_secure":            "cookie_same_site: none\ncookie_secure: false\n",
+		"cors_allowed_origins":              "cors_allowed_origins: ['*']\n",
+	}
+	for want, extra := range tests {
+		t.Run(want, func(t *testing.T) {
+			if want != "ldap_admin_password" {
+				t.Setenv(EnvPrefix+"LDAP_ADMIN_PASSWORD", "pw")
+			}
+			t.Setenv(EnvPrefix+"JWT_SECRET_KEY", "a-secret-that-is-definitely-long-enough")
+			path := writeFile(t, dir, "config.yaml", validConfig+extra)
+			_, err := LoadConfig(path)
+			if err == nil || !strings.Contains(err.Error(), want) {
+				t.Fatalf("expected error mentioning %q, got %v", want, err)
+			}
+		})
+	}
+}
+
+func TestLoadConfigWithoutLDAP(t *testing.T) {
+	dir := t.TempDir()
+	users := writeFile(t, dir, "users.htpasswd", "")
+	path := writeFile(t, dir, "config.yaml", `
+auth_backends: [htpasswd, pam]
+htpasswd_file: `+users+`
+slurm_api_host: http://slurm.example.com:6820
+jwt_secret_key: a-secret-that-is-definitely-long-enough
+`)
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("expected LDAP settings to be optional without LDAP: %v", err)
+	}
+	if cfg.UsesLDAP() || !cfg.UsesAuthBackend(AuthBackendPAM) || cfg.PAMService != "login" {
+		t.Errorf("unexpected auth backends: %v, pam_service=%q", cfg.AuthBackends, cfg.PAMService)
+	}
+	// 未配置 role_source 时不使用 LDAP 查询组
+	if cfg.RoleSource != RoleSourceLocal {
+		t.Errorf("expected role_source to default to local without the ldap backend, got %q", cfg.RoleSource)
+	}
+}
+
+func TestProviderRejectsInvalidReload(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv(EnvPrefix+"LDAP_ADMIN_PASSWORD", "pw")
+	t.Setenv(EnvPrefix+"JWT_SECRET_KEY", "a-secret-that-is-definitely-long-enough")
+	path := writeFile(t, dir, "config.yaml", validConfig)
+
+	p, err := NewProvider(path)
+	if err != nil {
+		t.Fatalf("NewProvider failed: %v", err)
+	}
+
+	writeFile(t, dir, "config.yaml", strings.Replace(validConfig, "12h", "-1h", 1))
+	if err := p.Reload(); err == nil {
+		t.Fatal("expected reload with negative jwt_duration to fail")
+	}
+	if p.Get().JWTDuration != 12*time.Hour {
+		t.Fatalf("previous configuration should be kept, got %v", p.Get().JWTDuration)
+	}
+
+	writeFile(t, dir, "config.yaml", strings.Replace(validConfig, "12h", "6h", 1))
+	if err := p.Reload(); err != nil {
+		t.Fatalf("valid reload failed: %v", err)
+	}
+	if p.Get().JWTDuration != 6*time.Hour {
+		t.Fatalf("reload not applied, got %v", p.Get().JWTDuration)
+	}
+	if changed := Diff(Default(), p.Get()); len(changed) == 0 {
+		t.Fatal("Diff should report changed fields")
+	}
+}
+
+func TestDescribeChangesRedactsSecrets(t *testing.T) {
+	prev, next := Default(), Default()
+	prev.OIDCClientSecret, next.OIDCClientSecret = "old-client-secret", "new-client-secret"
+	prev.JWTSecretKey, next.JWTSecretKey = "old-jwt-secret", "new-jwt-secret"
+
+	descriptions := strings.Join(describeChanges(prev, next, Diff(prev, next)), " ")
+	for _, secret := range []string{"old-client-secret", "new-client-secret", "old-jwt-secret", "new-jwt-secret"} {
+		if strings.Contains(descriptions, secret) {
+			t.Errorf("change log must not contain %q: %s", secret, descriptions)
+		}
+	}
+	if !strings.Contains(descriptions, "OIDCClientSecret (redacted)") {
+		t.Errorf("expected the OIDC client secret change to be reported, got %s", descriptions)
+	}
+}
@@ -0,0 +1,187 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// secretFields 中的字段在变更日志中只记录“已变更”，不输出具体值
+var secretFields = map[string]struct{}{
+	"LDAPAdminPassword":      {},
+	"JWTSecretKey":           {},
+	"TokenEncryptionSecrets": {},
+	"OIDCClientSecret":       {},
+}
+
+// restartFields 中的字段变更后需要重启服务才能生效
+var restartFields = map[string]struct{}{
+	"ServerPort":                 {},
+	"StorePath":                  {},
+	"TokenEncryptionSecrets":     {},
+	"TokenEncryptionSecretsFile": {},
+	// 新签发和校验的 token 立即使用新的 jwt_secret_key，但未配置 token_encryption_secrets 时
+	// Slurm token 的加密密钥在启动时由它派生，重启后才会更换
+	"JWTSecretKey": {},
+	// 路由、CORS 中间件和审计日志清理在启动时创建
+	"TrustedProxies":     {},
+	"CORSAllowedOrigins": {},
+	"AuditRetention":     {},
+}
+
+// Provider 持有当前生效的配置，所有处理器在每次请求时通过 Get 读取，
+// 重新加载时整体原子替换，保证单次读取看到的是一份完整且校验过的配置
+type Provider struct {
+	path    string
+	current atomic.Pointer[Config]
+
+	reloadMu sync.Mutex
+	modTimes map[string]time.Time
+}
+
+// NewProvider 从 path 加载初始配置，加载失败时返回错误
+func NewProvider(path string) (*Provider, error) {
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		return nil, err
+	}
+	p := &Provider{path: path}
+	p.current.Store(cfg)
+	p.modTimes = p.sourceModTimes(cfg)
+	return p, nil
+}
+
+// NewStaticProvider 返回一个始终提供 cfg 的 Provider，不关联任何配置文件，主要用于测试
+func NewStaticProvider(cfg *Config) *Provider {
+	p := &Provider{modTimes: make(map[string]time.Time)}
+	p.current.Store(cfg)
+	return p
+}
+
+// Get 返回当前生效的配置，返回值不可修改
+func (p *Provider) Get() *Config {
+	return p.current.Load()
+}
+
+// Reload 重新加载配置，只有通过校验的新配置才会替换当前配置
+func (p *Provider) Reload() error {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	next, err := LoadConfig(p.path)
+	if err != nil {
+		// 记录当前文件状态，避免同一份无效配置被反复加载
+		p.modTimes = p.sourceModTimes(p.current.Load())
+		return err
+	}
+	p.modTimes = p.sourceModTimes(next)
+
+	prev := p.current.Load()
+	changes := Diff(prev, next)
+	if len(changes) == 0 {
+		log.Println("Configuration reloaded, no fields changed")
+		return nil
+	}
+
+	for _, field := range changes {
+		if _, ok := restartFields[field]; ok {
+			log.Printf("Configuration field %s changed but only takes effect after a restart", field)
+		}
+	}
+	p.current.Store(next)
+	log.Printf("Configuration reloaded, changed fields: %v", describeChanges(prev, next, changes))
+	return nil
+}
+
+// Watch 在收到 SIGHUP 或检测到配置文件（包括密钥文件）修改时重新加载配置，直到 ctx 结束
+func (p *Provider) Watch(ctx context.Context, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			log.Println("Received SIGHUP, reloading configuration")
+			p.reload()
+		case <-ticker.C:
+			if p.sourcesChanged() {
+				log.Println("Configuration source changed on disk, reloading configuration")
+				p.reload()
+			}
+		}
+	}
+}
+
+func (p *Provider) reload() {
+	if err := p.Reload(); err != nil {
+		log.Printf("Rejected configuration reload, keeping previous configuration: %v", err)
+	}
+}
+
+// sourcesChanged 检查配置文件和密钥文件的修改时间是否发生变化
+func (p *Provider) sourcesChanged() bool {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	current := p.sourceModTimes(p.current.Load())
+	if len(current) != len(p.modTimes) {
+		return true
+	}
+	for path, mt := range current {
+		if prev, ok := p.modTimes[path]; !ok || !prev.Equal(mt) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *Provider) sourceModTimes(cfg *Config) map[string]time.Time {
+	modTimes := make(map[string]time.Time)
+	for _, path := range []string{p.path, cfg.LDAPAdminPasswordFile, cfg.JWTSecretKeyFile, cfg.OIDCClientSecretFile, cfg.TokenEncryptionSecretsFile} {
+		if path == "" {
+			continue
+		}
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+// Diff 返回两份配置之间值不同的字段名
+func Diff(prev, next *Config) []string {
+	var changed []string
+	pv, nv := reflect.ValueOf(prev).Elem(), reflect.ValueOf(next).Elem()
+	for i := 0; i < pv.NumField(); i++ {
+		if !reflect.DeepEqual(pv.Field(i).Interface(), nv.Field(i).Interface()) {
+			changed = append(changed, pv.Type().Field(i).Name)
+		}
+	}
+	return changed
+}
+
+func describeChanges(prev, next *Config, fields []string) []string {
+	pv, nv := reflect.ValueOf(prev).Elem(), reflect.ValueOf(next).Elem()
+	descriptions := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if _, secret := secretFields[field]; secret {
+			descriptions = append(descriptions, field+" (redacted)")
+			continue
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s: %v -> %v", field, pv.FieldByName(field).Interface(), nv.FieldByName(field).Interface()))
+	}
+	return descriptions
+}
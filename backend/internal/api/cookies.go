@@ -0,0 +1,109 @@
+package api
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"time"
+
+	"slurm-dashboard/config"
+
+	"github.com/gin-gonic/gin"
+)
+
+// session_cookies 开启时使用的 cookie 和请求头
+const (
+	// accessCookieName 保存 access token，只发送给 /api 下的请求
+	accessCookieName = "dashboard_token"
+	// refreshCookieName 保存 refresh token，只发送给刷新接口
+	refreshCookieName = "dashboard_refresh"
+	// csrfCookieName 保存 CSRF token，修改类请求需要在 csrfHeaderName 头中带上相同的值
+	csrfCookieName = "dashboard_csrf"
+	csrfHeaderName = "X-CSRF-Token"
+)
+
+// respondWithTokens 返回 issueTokens 签发的 token。开启 session_cookies 时 token 只写入 HttpOnly cookie，
+// 响应中改为返回 CSRF token。csrfToken 为空时生成新的 CSRF token；刷新时传入请求中已有的值，
+// 避免刷新期间发出的其他请求被拒绝
+func respondWithTokens(c *gin.Context, conf *config.Config, tokens gin.H, csrfToken string) {
+	if !conf.SessionCookies {
+		c.JSON(http.StatusOK, tokens)
+		return
+	}
+
+	if csrfToken == "" {
+		var err error
+		if csrfToken, err = newCSRFToken(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate CSRF token"})
+			return
+		}
+	}
+	setSessionCookie(c, conf, accessCookieName, tokens["token"].(string), "/api", conf.JWTDuration, true)
+	setSessionCookie(c, conf, refreshCookieName, tokens["refresh_token"].(string), "/api/refresh", conf.RefreshTokenDuration, true)
+	setSessionCookie(c, conf, csrfCookieName, csrfToken, "/api", conf.RefreshTokenDuration, false)
+
+	delete(tokens, "token")
+	delete(tokens, "refresh_token")
+	tokens["csrf_token"] = csrfToken
+	c.JSON(http.StatusOK, tokens)
+}
+
+// clearSessionCookies 在登出或刷新失败时删除浏览器中的登录 cookie
+func clearSessionCookies(c *gin.Context, conf *config.Config) {
+	if !conf.SessionCookies {
+		return
+	}
+	setSessionCookie(c, conf, accessCookieName, "", "/api", -1, true)
+	setSessionCookie(c, conf, refreshCookieName, "", "/api/refresh", -1, true)
+	setSessionCookie(c, conf, csrfCookieName, "", "/api", -1, false)
+}
+
+// setSessionCookie 按 cookie_secure 和 cookie_same_site 写入 cookie，maxAge 为负数时删除 cookie
+func setSessionCookie(c *gin.Context, conf *config.Config, name, value, path string, maxAge time.Duration, httpOnly bool) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     path,
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   conf.CookieSecure,
+		HttpOnly: httpOnly,
+		SameSite: cookieSameSite(conf.CookieSameSite),
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+func cookieSameSite(mode string) http.SameSite {
+	switch mode {
+	case config.SameSiteLax:
+		return http.SameSiteLaxMode
+	case config.SameSiteNone:
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
+// validCSRF 校验 X-CSRF-Token 头与 CSRF cookie 一致（double-submit）。
+// 其他站点的页面可以让浏览器带上 cookie，但无法读取 cookie 的值来设置请求头
+func validCSRF(c *gin.Context) bool {
+	header := c.GetHeader(csrfHeaderName)
+	cookie, err := c.Cookie(csrfCookieName)
+	return err == nil && header != "" && subtle.ConstantTimeCompare([]byte(header), []byte(cookie)) == 1
+}
+
+// isSafeMethod 判断请求是否为不修改状态的方法，这些请求不需要 CSRF token
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+func newCSRFToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
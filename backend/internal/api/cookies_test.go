@@ -0,0 +1,135 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"slurm-dashboard/config"
+)
+
+// doWithCookies 与 do 相同，但使用 cookie 认证，csrfToken 不为空时放在 X-CSRF-Token 头中
+func (e *testEnv) doWithCookies(method, path string, cookies []*http.Cookie, csrfToken string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	for _, cookie := range cookies {
+		req.AddCookie(cookie)
+	}
+	if csrfToken != "" {
+		req.Header.Set(csrfHeaderName, csrfToken)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+// responseCookies 按名称返回响应设置的 cookie
+func responseCookies(w *httptest.ResponseRecorder) map[string]*http.Cookie {
+	cookies := make(map[string]*http.Cookie)
+	for _, cookie := range w.Result().Cookies() {
+		cookies[cookie.Name] = cookie
+	}
+	return cookies
+}
+
+func TestCookieSession(t *testing.T) {
+	env := newTestEnv(t, func(cfg *config.Config) {
+		cfg.SessionCookies = true
+	})
+	env.directory.addUser("alice", "correct")
+	env.cli.Output("scontrol", "SLURM_JWT=slurm-token-alice\n")
+
+	w := env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"alice","password":"correct"}`))
+	expectStatus(t, w, http.StatusOK)
+	var resp map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if _, ok := resp["token"]; ok {
+		t.Error("the access token must not be readable by JavaScript in cookie mode")
+	}
+	if _, ok := resp["refresh_token"]; ok {
+		t.Error("the refresh token must not be readable by JavaScript in cookie mode")
+	}
+	csrfToken, _ := resp["csrf_token"].(string)
+	cookies := responseCookies(w)
+	access, refresh, csrf := cookies[accessCookieName], cookies[refreshCookieName], cookies[csrfCookieName]
+	if access == nil || refresh == nil || csrf == nil || csrf.Value != csrfToken || csrfToken == "" {
+		t.Fatalf("expected session and CSRF cookies, got %v", w.Result().Cookies())
+	}
+	for _, cookie := range []*http.Cookie{access, refresh} {
+		if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteStrictMode {
+			t.Errorf("cookie %s must be HttpOnly, Secure and SameSite=Strict: %+v", cookie.Name, cookie)
+		}
+	}
+	session := []*http.Cookie{access, csrf}
+
+	expectStatus(t, env.doWithCookies(http.MethodGet, "/api/v1/mfa", session, "", nil), http.StatusOK)
+	// 修改类请求必须带上与 cookie 一致的 CSRF token
+	expectStatus(t, env.doWithCookies(http.MethodDelete, "/api/v1/job/42", session, "", nil), http.StatusForbidden)
+	expectStatus(t, env.doWithCookies(http.MethodPost, "/api/v1/sbatch", session, "forged", strings.NewReader(`{}`)), http.StatusForbidden)
+	expectStatus(t, env.doWithCookies(http.MethodPost, "/api/v1/mfa/enroll", session, csrfToken, nil), http.StatusOK)
+
+	// 刷新使用 refresh token cookie，CSRF token 保持不变
+	expectStatus(t, env.doWithCookies(http.MethodPost, "/api/refresh", []*http.Cookie{refresh, csrf}, "", nil), http.StatusForbidden)
+	w = env.doWithCookies(http.MethodPost, "/api/refresh", []*http.Cookie{refresh, csrf}, csrfToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	cookies = responseCookies(w)
+	if cookies[accessCookieName] == nil || cookies[refreshCookieName] == nil || cookies[refreshCookieName].Value == refresh.Value {
+		t.Fatalf("expected rotated session cookies, got %v", w.Result().Cookies())
+	}
+	if cookies[csrfCookieName] == nil || cookies[csrfCookieName].Value != csrfToken {
+		t.Error("the CSRF token should survive a refresh")
+	}
+	session = []*http.Cookie{cookies[accessCookieName], csrf}
+
+	w = env.doWithCookies(http.MethodPost, "/api/logout", session, csrfToken, nil)
+	expectStatus(t, w, http.StatusOK)
+	if cleared := responseCookies(w)[accessCookieName]; cleared == nil || cleared.MaxAge >= 0 {
+		t.Errorf("expected logout to clear the access token cookie, got %v", w.Result().Cookies())
+	}
+	expectStatus(t, env.doWithCookies(http.MethodGet, "/api/v1/mfa", session, "", nil), http.StatusUnauthorized)
+}
+
+func TestSessionCookiesIgnoredWhenDisabled(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct")
+	pair := env.loginWithRefresh(t, "alice", "correct")
+
+	cookie := &http.Cookie{Name: accessCookieName, Value: pair.Token}
+	expectStatus(t, env.doWithCookies(http.MethodGet, "/api/v1/mfa", []*http.Cookie{cookie}, "", nil), http.StatusUnauthorized)
+}
+
+func TestCORSAllowedOrigins(t *testing.T) {
+	preflight := func(env *testEnv, origin string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodOptions, "/api/v1/jobs", nil)
+		req.Header.Set("Origin", origin)
+		req.Header.Set("Access-Control-Request-Method", http.MethodGet)
+		req.Header.Set("Access-Control-Request-Headers", "authorization")
+		w := httptest.NewRecorder()
+		env.router.ServeHTTP(w, req)
+		return w
+	}
+
+	// 默认不允许任何其他来源
+	env := newTestEnv(t)
+	if origin := preflight(env, "https://evil.example.com").Header().Get("Access-Control-Allow-Origin"); origin != "" {
+		t.Errorf("expected no CORS headers by default, got %q", origin)
+	}
+
+	env = newTestEnv(t, func(cfg *config.Config) {
+		cfg.CORSAllowedOrigins = []string{"http://localhost:5173"}
+	})
+	w := preflight(env, "http://localhost:5173")
+	if w.Header().Get("Access-Control-Allow-Origin") != "http://localhost:5173" || w.Header().Get("Access-Control-Allow-Credentials") != "true" {
+		t.Errorf("expected the configured origin to be allowed with credentials, got %v", w.Header())
+	}
+	if w := preflight(env, "https://evil.example.com"); w.Code != http.StatusForbidden {
+		t.Errorf("expected other origins to be rejected, got %d", w.Code)
+	}
+}
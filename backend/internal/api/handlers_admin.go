@@ -0,0 +1,111 @@
+package api
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ListActiveUsersHandler 列出当前持有 Slurm token 的用户及其 token 状态，不返回 token 本身
+func ListActiveUsersHandler(tokenStore *store.TokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		infos, err := tokenStore.List()
+		if err != nil {
+			log.Printf("Failed to list Slurm tokens: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list users"})
+			return
+		}
+		sort.Slice(infos, func(i, j int) bool { return infos[i].Username < infos[j].Username })
+
+		users := make([]gin.H, 0, len(infos))
+		for _, info := range infos {
+			users = append(users, gin.H{
+				"username":         info.Username,
+				"token_expires_at": info.ExpiresAt,
+				"last_active_at":   info.LastUsedAt,
+			})
+		}
+		c.JSON(http.StatusOK, gin.H{"users": users})
+	}
+}
+
+// directoryStats 由能够提供连接指标的用户目录实现，例如 auth.LDAPClient
+type directoryStats interface {
+	Stats() []auth.LDAPServerStats
+}
+
+// LDAPStatsHandler 返回各 LDAP 服务器的健康状态和绑定延迟
+func LDAPStatsHandler(directory directoryStats) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"servers": directory.Stats()})
+	}
+}
+
+// recordAudit 写入一条审计记录，写入失败只记录日志，不影响请求本身
+func recordAudit(audit *store.AuditLog, entry store.AuditEntry) {
+	if err := audit.Record(entry); err != nil {
+		log.Printf("Failed to record audit event %s for user %s: %v", entry.Event, entry.Username, err)
+	}
+}
+
+// ListLoginLockoutsHandler 返回仍在计数期内的登录失败记录，已锁定的排在前面
+func ListLoginLockoutsHandler(limiter *auth.LoginLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"lockouts": limiter.List()})
+	}
+}
+
+// ClearLoginLockoutHandler 清除一个用户名或 IP 的失败记录并解除锁定
+func ClearLoginLockoutHandler(limiter *auth.LoginLimiter, audit *store.AuditLog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		kind, key := c.Param("kind"), c.Param("key")
+		if kind != auth.LockoutUser && kind != auth.LockoutIP {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Lockout kind must be user or ip"})
+			return
+		}
+		if !limiter.Clear(kind, key) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No failed login attempts recorded"})
+			return
+		}
+
+		admin := c.GetString("username")
+		log.Printf("Admin %s cleared failed login attempts of %s %s", admin, kind, key)
+		entry := store.AuditEntry{Event: store.AuditLockoutCleared, Actor: admin, Detail: kind + " " + key}
+		if kind == auth.LockoutUser {
+			entry.Username = auth.NormalizeUsername(key)
+		} else {
+			entry.ClientIP = key
+		}
+		recordAudit(audit, entry)
+		c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared"})
+	}
+}
+
+// ListAuditLogHandler 按时间倒序返回审计日志，可以按 username 和 event 过滤，limit 默认为 100、最大为 1000
+func ListAuditLogHandler(audit *store.AuditLog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		limit := 100
+		if value := c.Query("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+				return
+			}
+			limit = min(n, 1000)
+		}
+
+		entries, err := audit.List(store.AuditFilter{Username: c.Query("username"), Event: c.Query("event"), Limit: limit})
+		if err != nil {
+			log.Printf("Failed to list audit log: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list audit log"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"entries": entries})
+	}
+}
@@ -0,0 +1,59 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"slurm-dashboard/internal/auth"
+)
+
+func TestAdminRoutesRequireAdminRole(t *testing.T) {
+	env := newTestEnv(t)
+	user := env.login(t, "alice")
+	admin := env.loginAs(t, "root", auth.RoleAdmin)
+
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/admin/users", user, nil), http.StatusForbidden)
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/admin/cluster/status", user, nil), http.StatusForbidden)
+
+	w := env.do(http.MethodGet, "/api/v1/admin/users", admin, nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Users []struct {
+			Username string `json:"username"`
+		} `json:"users"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(resp.Users) != 2 || resp.Users[0].Username != "alice" || resp.Users[1].Username != "root" {
+		t.Errorf("unexpected users %+v", resp.Users)
+	}
+}
+
+func TestRefreshRecomputesRole(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct")
+	pair := env.loginWithRefresh(t, "alice", "correct")
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/admin/users", pair.Token, nil), http.StatusForbidden)
+
+	// 用户被加入管理员组后，下一次刷新得到的 token 带有 admin 角色
+	env.directory.setGroups("alice", "hpc-admins")
+	status, body := env.refresh(pair.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d: %s", status, body)
+	}
+	var resp struct {
+		Token string `json:"token"`
+		User  struct {
+			Role string `json:"role"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.User.Role != auth.RoleAdmin {
+		t.Errorf("expected role to be recomputed as admin, got %q", resp.User.Role)
+	}
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/admin/users", resp.Token, nil), http.StatusOK)
+}
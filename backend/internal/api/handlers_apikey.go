@@ -0,0 +1,104 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type CreateAPIKeyPayload struct {
+	Name   string   `json:"name" binding:"required,max=64"`
+	Scopes []string `json:"scopes" binding:"required,min=1"`
+	// ExpiresInDays 为 0 时使用 store.DefaultAPIKeyLifetime，最长为 store.MaxAPIKeyLifetime
+	ExpiresInDays int `json:"expires_in_days" binding:"min=0,max=365"`
+}
+
+// ListAPIKeysHandler 返回当前用户的 API key，不包含 key 本身
+func ListAPIKeysHandler(apiKeys *store.APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString("username")
+		keys, err := apiKeys.List(username)
+		if err != nil {
+			log.Printf("Failed to list API keys of user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list API keys"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"keys": keys})
+	}
+}
+
+// CreateAPIKeyHandler 为当前用户创建 API key，完整的 key 只在响应中出现这一次
+func CreateAPIKeyHandler(apiKeys *store.APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString("username")
+
+		var payload CreateAPIKeyPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		name := strings.TrimSpace(payload.Name)
+		if name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "API key name is required"})
+			return
+		}
+		seen := make(map[string]bool)
+		var scopes []string
+		for _, scope := range payload.Scopes {
+			if !auth.ValidScope(scope) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown scope: " + scope, "valid_scopes": auth.Scopes})
+				return
+			}
+			if !seen[scope] {
+				seen[scope] = true
+				scopes = append(scopes, scope)
+			}
+		}
+		lifetime := store.DefaultAPIKeyLifetime
+		if payload.ExpiresInDays > 0 {
+			lifetime = time.Duration(payload.ExpiresInDays) * 24 * time.Hour
+		}
+		expiresAt := time.Now().Add(min(lifetime, store.MaxAPIKeyLifetime))
+
+		key, record, err := apiKeys.Create(username, name, scopes, expiresAt)
+		if errors.Is(err, store.ErrAPIKeyLimit) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Too many API keys, revoke an unused key first"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to create API key for user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create API key"})
+			return
+		}
+		log.Printf("User %s created API key %s (%s) with scopes %v", username, record.ID, name, scopes)
+		c.JSON(http.StatusCreated, gin.H{"key": key, "api_key": record})
+	}
+}
+
+// RevokeAPIKeyHandler 吊销当前用户的一个 API key
+func RevokeAPIKeyHandler(apiKeys *store.APIKeyStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := c.GetString("username")
+		id := c.Param("key_id")
+
+		err := apiKeys.Revoke(username, id)
+		if errors.Is(err, store.ErrAPIKeyNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to revoke API key %s of user %s: %v", id, username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke API key"})
+			return
+		}
+		log.Printf("User %s revoked API key %s", username, id)
+		c.JSON(http.StatusOK, gin.H{"message": "API key revoked"})
+	}
+}
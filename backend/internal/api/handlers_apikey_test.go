@@ -0,0 +1,169 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/models"
+	"slurm-dashboard/internal/store"
+)
+
+// createAPIKey 以 token 对应的登录用户身份创建 API key，返回完整的 key 和 ID
+func (e *testEnv) createAPIKey(t *testing.T, token, body string) (string, string) {
+	t.Helper()
+	w := e.do(http.MethodPost, "/api/v1/keys", token, strings.NewReader(body))
+	expectStatus(t, w, http.StatusCreated)
+	var resp struct {
+		Key    string `json:"key"`
+		APIKey struct {
+			ID string `json:"id"`
+		} `json:"api_key"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Key == "" {
+		t.Fatalf("invalid response: %s", w.Body.String())
+	}
+	return resp.Key, resp.APIKey.ID
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+	env.slurm.AddJob(models.SlurmJobInfo{JobID: 7, UserName: "alice", JobState: models.SlurmStringList{"RUNNING"}})
+
+	key, _ := env.createAPIKey(t, token, `{"name":"ci","scopes":["read"]}`)
+
+	w := env.do(http.MethodGet, "/api/v1/jobs", key, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	// 没有 cancel 范围
+	w = env.do(http.MethodDelete, "/api/v1/job/7", key, nil)
+	expectStatus(t, w, http.StatusForbidden)
+	if state := env.slurm.Jobs()[0].JobState[0]; state != "RUNNING" {
+		t.Fatalf("job must not be cancelled without the cancel scope, state is %s", state)
+	}
+
+	// API key 不能创建新的 key，也不能访问管理员接口
+	w = env.do(http.MethodPost, "/api/v1/keys", key, strings.NewReader(`{"name":"more","scopes":["cancel"]}`))
+	expectStatus(t, w, http.StatusForbidden)
+	admin := env.loginAs(t, "root", "admin")
+	adminKey, _ := env.createAPIKey(t, admin, `{"name":"admin","scopes":["read","submit","cancel"]}`)
+	w = env.do(http.MethodGet, "/api/v1/admin/users", adminKey, nil)
+	expectStatus(t, w, http.StatusForbidden)
+
+	w = env.do(http.MethodPost, "/api/v1/keys", token, strings.NewReader(`{"name":"bad","scopes":["admin"]}`))
+	expectStatus(t, w, http.StatusBadRequest)
+
+	// 交互式分配无法通过 API key 连接和释放，即使有 submit 范围也不能创建
+	submitKey, _ := env.createAPIKey(t, token, `{"name":"submit","scopes":["submit"]}`)
+	w = env.do(http.MethodPost, "/api/v1/salloc/interactive", submitKey, strings.NewReader(`{}`))
+	expectStatus(t, w, http.StatusForbidden)
+	if calls := env.cli.Calls(); len(calls) != 0 {
+		t.Errorf("no allocation should be requested with an API key, got %+v", calls)
+	}
+}
+
+func TestAPIKeyRevocationAndListing(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+	key, id := env.createAPIKey(t, token, `{"name":"notebook","scopes":["read","submit"],"expires_in_days":30}`)
+
+	w := env.do(http.MethodGet, "/api/v1/jobs", key, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	w = env.do(http.MethodGet, "/api/v1/keys", token, nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Keys []map[string]any `json:"keys"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Keys) != 1 {
+		t.Fatalf("expected one key, got %s", w.Body.String())
+	}
+	if resp.Keys[0]["name"] != "notebook" || resp.Keys[0]["last_used_at"] == nil || resp.Keys[0]["expires_at"] == nil {
+		t.Errorf("unexpected key metadata: %v", resp.Keys[0])
+	}
+	if strings.Contains(w.Body.String(), key) {
+		t.Error("the full key must not be listed")
+	}
+
+	// 其他用户不能吊销
+	bob := env.login(t, "bob")
+	w = env.do(http.MethodDelete, "/api/v1/keys/"+id, bob, nil)
+	expectStatus(t, w, http.StatusNotFound)
+
+	w = env.do(http.MethodDelete, "/api/v1/keys/"+id, token, nil)
+	expectStatus(t, w, http.StatusOK)
+	w = env.do(http.MethodGet, "/api/v1/jobs", key, nil)
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestAPIKeyIssuesSlurmTokenWhenMissing(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+	key, _ := env.createAPIKey(t, token, `{"name":"cron","scopes":["read"]}`)
+
+	// 用户长期没有登录，Slurm token 已被清理
+	if err := env.tokenStore.Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+	env.slurm.SetToken("alice", "reissued-token")
+	env.cli.Handle("scontrol", func(username string, args []string) (string, error) {
+		return "SLURM_JWT=reissued-token\n", nil
+	})
+
+	w := env.do(http.MethodGet, "/api/v1/jobs", key, nil)
+	expectStatus(t, w, http.StatusOK)
+	if stored, ok := env.tokenStore.Get("alice"); !ok || stored != "reissued-token" {
+		t.Fatalf("expected a new slurm token to be stored, got %q", stored)
+	}
+}
+
+func TestAPIKeyExpiryIsBounded(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+
+	// 未指定有效期的 key 使用默认有效期，不会永不过期
+	w := env.do(http.MethodPost, "/api/v1/keys", token, strings.NewReader(`{"name":"ci","scopes":["read"]}`))
+	expectStatus(t, w, http.StatusCreated)
+	var resp struct {
+		APIKey struct {
+			ExpiresAt *time.Time `json:"expires_at"`
+		} `json:"api_key"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.APIKey.ExpiresAt == nil {
+		t.Fatalf("expected an expiry, got %s", w.Body.String())
+	}
+	if lifetime := time.Until(*resp.APIKey.ExpiresAt); lifetime <= 0 || lifetime > store.DefaultAPIKeyLifetime {
+		t.Errorf("expected the default lifetime, got %v", lifetime)
+	}
+
+	w = env.do(http.MethodPost, "/api/v1/keys", token, strings.NewReader(`{"name":"forever","scopes":["read"],"expires_in_days":3650}`))
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestAPIKeyRejectedAfterOwnerRemoved(t *testing.T) {
+	for _, roleSource := range []string{config.RoleSourceLDAP, config.RoleSourceLocal} {
+		t.Run(roleSource, func(t *testing.T) {
+			env := newTestEnv(t, func(c *config.Config) { c.RoleSource = roleSource })
+			env.directory.addUser("alice", "correct")
+			removed := false
+			env.cli.Handle("groups", func(username string, args []string) (string, error) {
+				if removed {
+					return "groups: '" + args[0] + "': no such user", errors.New("exit status 1")
+				}
+				return args[0] + " : " + args[0], nil
+			})
+			token := env.login(t, "alice")
+			key, _ := env.createAPIKey(t, token, `{"name":"cron","scopes":["read"]}`)
+
+			// 账户从目录中删除后，已创建的 key 不能继续使用
+			env.directory.removeUser("alice")
+			removed = true
+			expectStatus(t, env.do(http.MethodGet, "/api/v1/jobs", key, nil), http.StatusUnauthorized)
+		})
+	}
+}
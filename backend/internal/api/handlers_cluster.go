@@ -1,18 +1,15 @@
 package api
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"log"
 	"net/http"
 	"strconv"
 	"strings"
-	"time"
 
-	"slurm-dashboard/config"
 	"slurm-dashboard/internal/models"
 	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/slurm"
 	"slurm-dashboard/internal/store"
 
 	"github.com/gin-gonic/gin"
@@ -46,7 +43,7 @@ type GPUInfo struct {
 	Available int    `json:"available"`
 }
 
-func GetClusterStatusHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+func GetClusterStatusHandler(client slurm.Source, tokenStore *store.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		username, _ := c.Get("username")
 		slurmToken, ok := tokenStore.Get(username.(string))
@@ -55,15 +52,14 @@ func GetClusterStatusHandler(cfg *config.Config, tokenStore *store.TokenStore) g
 			return
 		}
 
-		client := &http.Client{Timeout: 30 * time.Second}
-
-		nodesData, err := fetchNodesData(client, cfg.SlurmAPIHost+"/slurm/v0.0.42/nodes", username.(string), slurmToken)
+		creds := slurm.Credentials{Username: username.(string), Token: slurmToken}
+		nodesData, err := client.ListNodes(c.Request.Context(), creds)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch nodes data", "details": err.Error()})
+			respondSlurmError(c, err, "Failed to fetch nodes data")
 			return
 		}
 
-		response := processClusterDataFromNodes(nodesData)
+		response := processClusterDataFromNodes(*nodesData)
 
 		c.JSON(http.StatusOK, response)
 	}
@@ -71,7 +67,7 @@ func GetClusterStatusHandler(cfg *config.Config, tokenStore *store.TokenStore) g
 
 // GetClusterStatusByUserHandler 是一个新的 Handler，用于获取特定用户可见的集群状态。
 // 它会过滤掉用户无权访问的分区以及所有以 'debug' 开头的分区。
-func GetClusterStatusByUserHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+func GetClusterStatusByUserHandler(client slurm.Source, executor services.Executor, tokenStore *store.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		username, _ := c.Get("username")
 		usernameStr := username.(string)
@@ -82,11 +78,11 @@ func GetClusterStatusByUserHandler(cfg *config.Config, tokenStore *store.TokenSt
 			return
 		}
 
-		client := &http.Client{Timeout: 30 * time.Second}
+		creds := slurm.Credentials{Username: usernameStr, Token: slurmToken}
 
 		// --- 并行获取节点数据和用户允许的分区 ---
 		type nodesResult struct {
-			data models.SlurmNodeResponse
+			data *models.SlurmNodeResponse
 			err  error
 		}
 		type partitionsResult struct {
@@ -98,12 +94,12 @@ func GetClusterStatusByUserHandler(cfg *config.Config, tokenStore *store.TokenSt
 		partitionsChan := make(chan partitionsResult, 1)
 
 		go func() {
-			data, err := fetchNodesData(client, cfg.SlurmAPIHost+"/slurm/v0.0.42/nodes", usernameStr, slurmToken)
+			data, err := client.ListNodes(c.Request.Context(), creds)
 			nodesChan <- nodesResult{data: data, err: err}
 		}()
 
 		go func() {
-			data, err := getUserAllowedPartition(usernameStr)
+			data, err := getUserAllowedPartition(c.Request.Context(), executor, usernameStr)
 			partitionsChan <- partitionsResult{data: data, err: err}
 		}()
 
@@ -112,7 +108,7 @@ func GetClusterStatusByUserHandler(cfg *config.Config, tokenStore *store.TokenSt
 		// --- 数据获取结束 ---
 
 		if nodesRes.err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch nodes data", "details": nodesRes.err.Error()})
+			respondSlurmError(c, nodesRes.err, "Failed to fetch nodes data")
 			return
 		}
 		if partitionsRes.err != nil {
@@ -121,16 +117,16 @@ func GetClusterStatusByUserHandler(cfg *config.Config, tokenStore *store.TokenSt
 		}
 
 		// 使用新的处理函数来整合和过滤数据
-		response := processAndFilterClusterData(nodesRes.data, partitionsRes.data)
+		response := processAndFilterClusterData(*nodesRes.data, partitionsRes.data)
 
 		c.JSON(http.StatusOK, response)
 	}
 }
 
-func GetPartitionsHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+func GetPartitionsHandler(executor services.Executor) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		username, _ := c.Get("username")
-		response, err := getUserAllowedPartition(username.(string))
+		response, err := getUserAllowedPartition(c.Request.Context(), executor, username.(string))
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch partitions data", "details": err.Error()})
 		}
@@ -196,38 +192,6 @@ func processAndFilterClusterData(nodesData models.SlurmNodeResponse, allowedPart
 	return response
 }
 
-func fetchNodesData(client *http.Client, url, username, token string) (models.SlurmNodeResponse, error) {
-	var result models.SlurmNodeResponse
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return result, fmt.Errorf("failed to create request: %w", err)
-	}
-	req.Header.Set("X-SLURM-USER-NAME", username)
-	req.Header.Set("X-SLURM-USER-TOKEN", token)
-	req.Header.Set("Accept", "application/json")
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return result, fmt.Errorf("request to slurm failed: %w", err)
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return result, fmt.Errorf("failed to read response body: %w", err)
-	}
-
-	if resp.StatusCode != http.StatusOK {
-		return result, fmt.Errorf("slurm api returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		log.Printf("Failed to unmarshal JSON for %s. Raw data: %s. Error: %v", url, string(body), err)
-		return result, fmt.Errorf("failed to unmarshal json: %w", err)
-	}
-	return result, nil
-}
-
 func processClusterDataFromNodes(nodesData models.SlurmNodeResponse) ClusterStatusResponse {
 	var response ClusterStatusResponse
 
@@ -353,22 +317,25 @@ func parseGres(gres, gresUsed string) []GPUInfo {
 }
 
 // 获取用户允许的分区
-func getUserAllowedPartition(username string) ([]string, error) {
-	output1, err := services.ExecuteCommandAsUser("root", "scontrol show partition | grep -E 'PartitionName|AllowAccounts'")
+func getUserAllowedPartition(ctx context.Context, executor services.Executor, username string) ([]string, error) {
+	result1, err := executor.Run(ctx, services.Request{Username: "root", Program: "scontrol", Args: []string{"show", "partition"}})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get partition info: %w", err)
 	}
-	partitionAllowdInfo, err := parsePartitionAllowedOutput(output1)
+	partitionAllowdInfo, err := parsePartitionAllowedOutput(filterLines(result1.Stdout, "PartitionName", "AllowAccounts"))
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse partition info: %w", err)
 	}
 
-	sacctmgrCmd := fmt.Sprintf("sacctmgr -nP show associations where user=%s format=Account", username)
-	output2, err := services.ExecuteCommandAsUser(username, sacctmgrCmd)
+	result2, err := executor.Run(ctx, services.Request{
+		Username: username,
+		Program:  "sacctmgr",
+		Args:     []string{"-nP", "show", "associations", "where", "user=" + username, "format=Account"},
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to get user account info for %s: %w", username, err)
 	}
-	userAccountsSlice := parseToSlice(output2)
+	userAccountsSlice := parseToSlice(result2.Stdout)
 
 	userAccountSet := make(map[string]struct{})
 	for _, acc := range userAccountsSlice {
@@ -403,7 +370,21 @@ type PartitionAllow struct {
 	AllowQos      []string
 }
 
-// 解析 scontrol show partition | grep -E 'PartitionName|AllowAccounts' 的输出
+// filterLines 只保留包含任一关键字的行，相当于 grep -E 'a|b'
+func filterLines(output string, keywords ...string) string {
+	var kept []string
+	for _, line := range strings.Split(output, "\n") {
+		for _, keyword := range keywords {
+			if strings.Contains(line, keyword) {
+				kept = append(kept, line)
+				break
+			}
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// 解析 scontrol show partition 中 PartitionName 与 AllowAccounts 所在行的内容
 // 返回值 map，key 为 PartitionName，value 为 PartitionAllow 结构体
 func parsePartitionAllowedOutput(info string) (map[string]PartitionAllow, error) {
 	// 初始化最终结果的 map
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"testing"
+
+	"slurm-dashboard/internal/models"
+)
+
+func TestParseGres(t *testing.T) {
+	gpus := parseGres("gpu:a100:4,gres/gpu:v100:2,shard:8", "gpu:a100:1(IDX:0),gpu:v100:0")
+	sort.Slice(gpus, func(i, j int) bool { return gpus[i].Type < gpus[j].Type })
+
+	want := []GPUInfo{
+		{Type: "a100", Total: 4, Allocated: 1, Available: 3},
+		{Type: "v100", Total: 2, Allocated: 0, Available: 2},
+	}
+	if len(gpus) != len(want) {
+		t.Fatalf("expected %d gpu types, got %+v", len(want), gpus)
+	}
+	for i := range want {
+		if gpus[i] != want[i] {
+			t.Errorf("gpu %d: expected %+v, got %+v", i, want[i], gpus[i])
+		}
+	}
+
+	if gpus := parseGres("gpu:2", ""); len(gpus) != 1 || gpus[0].Type != "gpu" || gpus[0].Total != 2 {
+		t.Errorf("untyped gres not parsed: %+v", gpus)
+	}
+	if gpus := parseGres("(null)", ""); len(gpus) != 0 {
+		t.Errorf("expected no gpus, got %+v", gpus)
+	}
+}
+
+const scontrolPartitionOutput = `PartitionName=compute
+   AllowGroups=ALL AllowAccounts=ALL AllowQos=ALL
+PartitionName=restricted
+   AllowGroups=ALL AllowAccounts=proj1,proj2 AllowQos=normal
+PartitionName=debug-gpu
+   AllowGroups=ALL AllowAccounts=ALL AllowQos=ALL
+`
+
+func TestParsePartitionAllowedOutput(t *testing.T) {
+	partitions, err := parsePartitionAllowedOutput(scontrolPartitionOutput)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(partitions) != 3 {
+		t.Fatalf("expected 3 partitions, got %d", len(partitions))
+	}
+	restricted := partitions["restricted"]
+	if len(restricted.AllowAccounts) != 2 || restricted.AllowAccounts[1] != "proj2" {
+		t.Errorf("unexpected AllowAccounts: %v", restricted.AllowAccounts)
+	}
+	if len(restricted.AllowQos) != 1 || restricted.AllowQos[0] != "normal" {
+		t.Errorf("unexpected AllowQos: %v", restricted.AllowQos)
+	}
+}
+
+func TestGetClusterStatusByUserHandler(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+
+	env.slurm.AddNode(models.SlurmNodeInfo{
+		Name: "gpu01", State: models.SlurmStringList{"MIXED"}, Partitions: []string{"compute", "restricted", "debug-gpu"},
+		TotalCPUs: 64, AllocatedCPUs: 16, Gres: "gpu:a100:8", GresUsed: "gpu:a100:2(IDX:0-1)",
+	})
+	env.cli.Output("scontrol", scontrolPartitionOutput)
+	env.cli.Output("sacctmgr", "proj2\n")
+
+	w := env.do(http.MethodGet, "/api/v1/cluster/status_limit", token, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	var resp ClusterStatusResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(resp.Nodes) != 1 {
+		t.Fatalf("expected 1 node, got %d", len(resp.Nodes))
+	}
+	node := resp.Nodes[0]
+	sort.Strings(node.Partitions)
+	if len(node.Partitions) != 2 || node.Partitions[0] != "compute" || node.Partitions[1] != "restricted" {
+		t.Errorf("expected debug partition to be filtered out, got %v", node.Partitions)
+	}
+	if node.AvailableCPUs != 48 {
+		t.Errorf("expected 48 available cpus, got %d", node.AvailableCPUs)
+	}
+	if len(node.GPUs) != 1 || node.GPUs[0].Available != 6 {
+		t.Errorf("unexpected gpus: %+v", node.GPUs)
+	}
+
+	for _, call := range env.cli.Calls() {
+		if call.Program == "sacctmgr" && call.Username != "alice" {
+			t.Errorf("sacctmgr should run as alice, ran as %q", call.Username)
+		}
+	}
+}
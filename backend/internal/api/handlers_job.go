@@ -1,7 +1,7 @@
 package api
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -11,17 +11,17 @@ import (
 	"os/user"
 	"path/filepath"
 	"strings"
-	"time"
 
 	"slurm-dashboard/config"
 	"slurm-dashboard/internal/models"
+	"slurm-dashboard/internal/slurm"
 	"slurm-dashboard/internal/store"
 
 	"github.com/gin-gonic/gin"
 )
 
 // GetJobsHandler 负责处理获取作业列表的请求，并支持按用户名和状态筛选
-func GetJobsHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+func GetJobsHandler(client slurm.Source, tokenStore *store.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1. 从 context 获取认证信息
 		username, _ := c.Get("username")
@@ -37,46 +37,14 @@ func GetJobsHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.Handle
 		log.Printf("Fetching jobs with filters: username=%s, state=%s", filterUsername, filterState)
 
 		// 3. 从 Slurm 获取所有作业数据
-		client := &http.Client{Timeout: 30 * time.Second}
-		targetURL := cfg.SlurmAPIHost + "/slurm/v0.0.42/jobs"
-
-		req, err := http.NewRequest("GET", targetURL, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create request"})
-			return
-		}
-		req.Header.Set("X-SLURM-USER-NAME", username.(string))
-		req.Header.Set("X-SLURM-USER-TOKEN", slurmToken)
-		req.Header.Set("Accept", "application/json")
-
-		resp, err := client.Do(req)
-		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach Slurm API"})
-			return
-		}
-		defer resp.Body.Close()
-
-		if resp.StatusCode != http.StatusOK {
-			bodyBytes, _ := io.ReadAll(resp.Body)
-			c.Data(resp.StatusCode, "application/json", bodyBytes)
-			return
-		}
-
-		body, err := io.ReadAll(resp.Body)
+		creds := slurm.Credentials{Username: username.(string), Token: slurmToken}
+		jobResponse, err := client.ListJobs(c.Request.Context(), creds)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response from Slurm API"})
+			respondSlurmError(c, err, "Failed to fetch jobs from Slurm API")
 			return
 		}
 
-		// 4. 将JSON数据解析到我们的struct中
-		var jobResponse models.SlurmJobResponse
-		if err := json.Unmarshal(body, &jobResponse); err != nil {
-			log.Printf("Failed to parse jobs JSON: %v", err) // 打印详细的解析错误
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse jobs JSON from Slurm API"})
-			return
-		}
-
-		// 5. 在Go代码中执行过滤 (已更新过滤逻辑)
+		// 4. 在Go代码中执行过滤 (已更新过滤逻辑)
 		var filteredJobs []models.SlurmJobInfo
 		for _, job := range jobResponse.Jobs {
 			matchUser := (filterUsername == "" || job.UserName == filterUsername)
@@ -98,7 +66,7 @@ func GetJobsHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.Handle
 		}
 		log.Printf("Total jobs fetched: %d, jobs after filtering: %d", len(jobResponse.Jobs), len(filteredJobs))
 
-		// 6. 将过滤后的结果返回给前端
+		// 5. 将过滤后的结果返回给前端
 		finalResponse := gin.H{
 			"jobs": filteredJobs,
 		}
@@ -107,7 +75,7 @@ func GetJobsHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.Handle
 }
 
 // HandleGetJobByID 负责根据作业ID获取单个作业的详细信息
-func HandleGetJobByID(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+func HandleGetJobByID(client slurm.Source, tokenStore *store.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1. 身份验证和Token获取
 		username, _ := c.Get("username")
@@ -125,43 +93,20 @@ func HandleGetJobByID(cfg *config.Config, tokenStore *store.TokenStore) gin.Hand
 		}
 		log.Printf("Received get job request for job %s by user %s", jobId, username)
 
-		// 3. 将请求代理到真正的Slurm API
-		client := &http.Client{Timeout: 30 * time.Second}
-		targetURL := fmt.Sprintf("%s/slurm/v0.0.42/job/%s", cfg.SlurmAPIHost, jobId)
-
-		// 创建一个新的GET请求
-		req, err := http.NewRequest("GET", targetURL, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create get job request"})
-			return
-		}
-
-		// 4. 设置必要的Header
-		req.Header.Set("X-SLURM-USER-NAME", username.(string))
-		req.Header.Set("X-SLURM-USER-TOKEN", slurmToken)
-		req.Header.Set("Accept", "application/json")
-
-		// 5. 发送请求并代理响应
-		resp, err := client.Do(req)
+		// 3. 从 Slurm 获取作业信息
+		creds := slurm.Credentials{Username: username.(string), Token: slurmToken}
+		jobResponse, err := client.GetJob(c.Request.Context(), creds, jobId)
 		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach Slurm API for getting job"})
+			respondSlurmError(c, err, "Failed to get job from Slurm API")
 			return
 		}
-		defer resp.Body.Close()
 
-		responseBody, err := io.ReadAll(resp.Body)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response from Slurm API"})
-			return
-		}
-
-		// 将Slurm API的响应状态码和响应体原样返回给前端
-		c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), responseBody)
+		c.JSON(http.StatusOK, jobResponse)
 	}
 }
 
 // HandleDeleteJob 负责处理取消作业的DELETE请求
-func HandleDeleteJob(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+func HandleDeleteJob(client slurm.Source, tokenStore *store.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1. 身份验证和Token获取
 		username, _ := c.Get("username")
@@ -179,44 +124,20 @@ func HandleDeleteJob(cfg *config.Config, tokenStore *store.TokenStore) gin.Handl
 		}
 		log.Printf("Received job cancellation request for job %s by user %s", jobId, username)
 
-		// 3. 将请求代理到真正的Slurm API
-		client := &http.Client{Timeout: 30 * time.Second}
-		// 构造包含 job_id 的目标URL
-		targetURL := fmt.Sprintf("%s/slurm/v0.0.42/job/%s", cfg.SlurmAPIHost, jobId)
-
-		// 创建一个新的DELETE请求
-		req, err := http.NewRequest("DELETE", targetURL, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create cancellation request"})
-			return
-		}
-
-		// 4. 设置必要的Header
-		req.Header.Set("X-SLURM-USER-NAME", username.(string))
-		req.Header.Set("X-SLURM-USER-TOKEN", slurmToken)
-		req.Header.Set("Accept", "application/json")
-
-		// 5. 发送请求并代理响应
-		resp, err := client.Do(req)
-		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach Slurm API for job cancellation"})
-			return
-		}
-		defer resp.Body.Close()
-
-		responseBody, err := io.ReadAll(resp.Body)
+		// 3. 通过 Slurm API 取消作业
+		creds := slurm.Credentials{Username: username.(string), Token: slurmToken}
+		cancelResponse, err := client.CancelJob(c.Request.Context(), creds, jobId)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response from Slurm API"})
+			respondSlurmError(c, err, "Failed to cancel job")
 			return
 		}
 
-		// 将Slurm API的响应状态码和响应体原样返回给前端
-		c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), responseBody)
+		c.JSON(http.StatusOK, cancelResponse)
 	}
 }
 
 // HandleGetJobConnectLog 读取并返回用户特定作业的连接方式日志
-func HandleGetJobConnectLog(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+func HandleGetJobConnectLog(cfg *config.Provider, tokenStore *store.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1. 从 context 获取已认证的用户名
 		username, exists := c.Get("username")
@@ -242,7 +163,7 @@ func HandleGetJobConnectLog(cfg *config.Config, tokenStore *store.TokenStore) gi
 		homeDir := osUser.HomeDir
 
 		// 4. 构造完整的文件路径
-		fileName := fmt.Sprintf(cfg.JobConnectLogPattern, jobId)
+		fileName := fmt.Sprintf(cfg.Get().JobConnectLogPattern, jobId)
 		filePath := filepath.Join(homeDir, fileName)
 		log.Printf("Attempting to read log file for user %s: %s", username, filePath)
 
@@ -266,7 +187,7 @@ func HandleGetJobConnectLog(cfg *config.Config, tokenStore *store.TokenStore) gi
 }
 
 // HandleGetJobInfoLog 读取并返回用户特定作业的断开原因日志，作为前端的消息
-func HandleGetAllJobInfoLogs(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+func HandleGetAllJobInfoLogs(cfg *config.Provider, tokenStore *store.TokenStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		// 1. 从 context 获取已认证的用户名
 		username, exists := c.Get("username")
@@ -286,7 +207,8 @@ func HandleGetAllJobInfoLogs(cfg *config.Config, tokenStore *store.TokenStore) g
 
 		// 3. 构造日志文件所在的目录路径
 		// 假设 JobInfoLogPattern 是 ".slurm/info-%s.log"，我们从中提取目录部分 ".slurm"
-		logDir := filepath.Dir(cfg.JobInfoLogPattern)
+		infoLogPattern := cfg.Get().JobInfoLogPattern
+		logDir := filepath.Dir(infoLogPattern)
 		fullLogDirPath := filepath.Join(homeDir, logDir)
 
 		// 4. 读取目录中的所有文件
@@ -304,7 +226,7 @@ func HandleGetAllJobInfoLogs(cfg *config.Config, tokenStore *store.TokenStore) g
 		}
 
 		// 5. 准备从文件名中解析 Job ID 所需的前缀和后缀
-		basePattern := filepath.Base(cfg.JobInfoLogPattern) // "info-%s.log"
+		basePattern := filepath.Base(infoLogPattern) // "info-%s.log"
 		parts := strings.Split(basePattern, "%s")
 		prefix := parts[0] // "info-"
 		suffix := parts[1] // ".log"
@@ -345,7 +267,10 @@ func HandleGetAllJobInfoLogs(cfg *config.Config, tokenStore *store.TokenStore) g
 	}
 }
 
-func genericPostProxyHandler(cfg *config.Config, tokenStore *store.TokenStore, slurmEndpoint string) gin.HandlerFunc {
+// submitFunc 是 slurm.Client 中提交类方法的统一签名
+type submitFunc func(ctx context.Context, creds slurm.Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error)
+
+func genericSubmitHandler(tokenStore *store.TokenStore, action string, submit submitFunc) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		username, _ := c.Get("username")
 		slurmToken, ok := tokenStore.Get(username.(string))
@@ -355,47 +280,27 @@ func genericPostProxyHandler(cfg *config.Config, tokenStore *store.TokenStore, s
 		}
 
 		requestBody, err := io.ReadAll(c.Request.Body)
-		if err != nil {
+		if err != nil || !json.Valid(requestBody) {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read request body"})
 			return
 		}
-		log.Printf("Received POST request for user %s, proxying to %s", username, slurmEndpoint)
-
-		client := &http.Client{Timeout: 30 * time.Second}
-		targetURL := cfg.SlurmAPIHost + slurmEndpoint
-
-		req, err := http.NewRequest("POST", targetURL, bytes.NewBuffer(requestBody))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create proxy request"})
-			return
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-SLURM-USER-NAME", username.(string))
-		req.Header.Set("X-SLURM-USER-TOKEN", slurmToken)
-		req.Header.Set("Accept", "application/json")
-
-		resp, err := client.Do(req)
-		if err != nil {
-			c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach Slurm API endpoint"})
-			return
-		}
-		defer resp.Body.Close()
+		log.Printf("Received %s request for user %s", action, username)
 
-		responseBody, err := io.ReadAll(resp.Body)
+		creds := slurm.Credentials{Username: username.(string), Token: slurmToken}
+		submitResponse, err := submit(c.Request.Context(), creds, requestBody)
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read response from Slurm API"})
+			respondSlurmError(c, err, fmt.Sprintf("Failed to %s job", action))
 			return
 		}
 
-		c.Data(resp.StatusCode, resp.Header.Get("Content-Type"), responseBody)
+		c.JSON(http.StatusOK, submitResponse)
 	}
 }
 
-func SubmitJobHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
-	return genericPostProxyHandler(cfg, tokenStore, "/slurm/v0.0.42/job/submit")
+func SubmitJobHandler(client slurm.Source, tokenStore *store.TokenStore) gin.HandlerFunc {
+	return genericSubmitHandler(tokenStore, "submit", client.SubmitJob)
 }
 
-func AllocateJobHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
-	return genericPostProxyHandler(cfg, tokenStore, "/slurm/v0.0.42/job/allocate")
+func AllocateJobHandler(client slurm.Source, tokenStore *store.TokenStore) gin.HandlerFunc {
+	return genericSubmitHandler(tokenStore, "allocate", client.AllocateJob)
 }
@@ -0,0 +1,99 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/models"
+)
+
+func TestGetJobsHandlerFilters(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+	env.slurm.AddJob(models.SlurmJobInfo{JobID: 1, UserName: "alice", JobState: models.SlurmStringList{"RUNNING"}})
+	env.slurm.AddJob(models.SlurmJobInfo{JobID: 2, UserName: "alice", JobState: models.SlurmStringList{"PENDING"}})
+	env.slurm.AddJob(models.SlurmJobInfo{JobID: 3, UserName: "bob", JobState: models.SlurmStringList{"RUNNING"}})
+
+	w := env.do(http.MethodGet, "/api/v1/jobs?username=alice&state=RUNNING", token, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	var resp struct {
+		Jobs []models.SlurmJobInfo `json:"jobs"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(resp.Jobs) != 1 || resp.Jobs[0].JobID != 1 {
+		t.Fatalf("expected only job 1, got %+v", resp.Jobs)
+	}
+}
+
+func TestGetJobsHandlerWithoutSlurmSession(t *testing.T) {
+	env := newTestEnv(t)
+	// 持有有效 JWT 但没有 Slurm token 的用户，例如后端重启之后
+	token, _, err := auth.GenerateCustomToken(env.cfg.Get(), "carol", auth.RoleUser, "")
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+
+	w := env.do(http.MethodGet, "/api/v1/jobs", token, nil)
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestHandleGetJobByIDNotFound(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+
+	w := env.do(http.MethodGet, "/api/v1/job/42", token, nil)
+	expectStatus(t, w, http.StatusNotFound)
+
+	var resp struct {
+		Errors []models.SlurmError `json:"errors"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if len(resp.Errors) != 1 || resp.Errors[0].ErrorNumber != 2017 {
+		t.Fatalf("expected slurm error to be passed through, got %+v", resp.Errors)
+	}
+}
+
+func TestHandleDeleteJob(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+	env.slurm.AddJob(models.SlurmJobInfo{JobID: 7, UserName: "alice", JobState: models.SlurmStringList{"RUNNING"}})
+
+	w := env.do(http.MethodDelete, "/api/v1/job/7", token, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	if state := env.slurm.Jobs()[0].JobState[0]; state != "CANCELLED" {
+		t.Fatalf("expected job to be cancelled, state is %s", state)
+	}
+}
+
+func TestProtectedRoutesRequireToken(t *testing.T) {
+	env := newTestEnv(t)
+	w := env.do(http.MethodGet, "/api/v1/jobs", "", nil)
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestSlurmUnauthorizedRenewsTokenOnce(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+	// slurmrestd 已不再接受登录时签发的 token
+	env.slurm.SetToken("alice", "renewed-token")
+	env.cli.Output("scontrol", "SLURM_JWT=renewed-token\n")
+	env.slurm.AddJob(models.SlurmJobInfo{JobID: 7, UserName: "alice", JobState: models.SlurmStringList{"RUNNING"}})
+
+	w := env.do(http.MethodGet, "/api/v1/jobs", token, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	if stored, _ := env.tokenStore.Get("alice"); stored != "renewed-token" {
+		t.Errorf("expected renewed token to be stored, got %q", stored)
+	}
+	if calls := env.cli.Calls(); len(calls) != 1 || calls[0].Program != "scontrol" {
+		t.Errorf("expected exactly one scontrol token call, got %+v", calls)
+	}
+}
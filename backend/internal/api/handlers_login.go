@@ -1,8 +1,14 @@
 package api
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
 
 	"slurm-dashboard/config"
 	"slurm-dashboard/internal/auth"
@@ -17,40 +23,210 @@ type LoginPayload struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// LoginHandler 负责处理登录逻辑
-func LoginHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+// loginOutcome 为 completeLogin 和 startSession 已经写出的响应类型
+type loginOutcome int
+
+const (
+	// loginFailed 表示因内部错误返回了错误响应，登录没有完成
+	loginFailed loginOutcome = iota
+	// loginChallenged 表示返回了两步验证挑战，由 MFAVerifyHandler 完成登录
+	loginChallenged
+	// loginIssued 表示已签发 token，登录完成
+	loginIssued
+)
+
+// resolveRole 按 role_source 查询用户所属的组并计算角色，查询失败时降级为 user
+func resolveRole(ctx context.Context, conf *config.Config, executor services.Executor, directory auth.Directory, username string) string {
+	groups, err := auth.LookupGroups(ctx, conf, executor, directory, username)
+	if err != nil {
+		log.Printf("Could not look up groups for user %s: %v. Defaulting to 'user' role.", username, err)
+		return auth.RoleUser
+	}
+	return auth.RoleForGroups(conf, groups)
+}
+
+// LoginHandler 负责处理登录逻辑。用户名或客户端 IP 处于退避或锁定期间时直接拒绝，不校验密码
+func LoginHandler(cfg *config.Provider, executor services.Executor, authenticator auth.Authenticator, directory auth.Directory, tokenStore *store.TokenStore, refreshTokens *store.RefreshTokenStore, totpStore *store.TOTPStore, challenges *store.MFAChallengeStore, limiter *auth.LoginLimiter, audit *store.AuditLog) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		conf := cfg.Get()
+
 		var payload LoginPayload
 		if err := c.ShouldBindJSON(&payload); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
 			return
 		}
+		username, clientIP := auth.NormalizeUsername(payload.Username), c.ClientIP()
 
-		isAuthenticated, err := auth.AuthenticateLDAP(cfg, payload.Username, payload.Password)
-		if err != nil || !isAuthenticated {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
+		if rejectThrottledLogin(c, limiter, audit, username, clientIP) {
 			return
 		}
 
-		role := auth.CheckAdminStatus(payload.Username)
-		log.Printf("User %s logged in with role: %s", payload.Username, role)
-
-		slurmToken, err := services.GetSlurmToken(payload.Username, cfg.SlurmTokenLifespanSec)
+		identity, err := authenticator.Authenticate(payload.Username, payload.Password)
 		if err != nil {
-			log.Printf("Slurm token generation error for user %s: %v", payload.Username, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate Slurm token"})
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				// 只有密码错误计入失败次数，认证后端不可用不是用户的问题
+				recordLoginFailure(conf, limiter, audit, store.AuditLoginFailed, username, clientIP, "invalid credentials")
+			} else {
+				log.Printf("Authentication error for user %s: %v", payload.Username, err)
+				recordAudit(audit, store.AuditEntry{Event: store.AuditLoginFailed, Username: username, ClientIP: clientIP, Detail: "authentication backend error"})
+			}
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid username or password"})
 			return
 		}
 
-		tokenStore.Set(payload.Username, slurmToken)
-		log.Printf("Stored Slurm token for user: %s", payload.Username)
+		// 只有签发了 token 才清除失败计数，需要两步验证时在验证码校验通过并签发 token 后清除
+		if completeLogin(c, conf, executor, directory, tokenStore, refreshTokens, totpStore, challenges, identity) == loginIssued {
+			limiter.RecordSuccess(username)
+		}
+	}
+}
+
+// rejectThrottledLogin 在用户名或客户端 IP 处于退避或锁定期间时返回 429 并记录审计日志
+func rejectThrottledLogin(c *gin.Context, limiter *auth.LoginLimiter, audit *store.AuditLog, username, clientIP string) bool {
+	wait := limiter.Check(username, clientIP)
+	if wait <= 0 {
+		return false
+	}
+	recordAudit(audit, store.AuditEntry{Event: store.AuditLoginBlocked, Username: username, ClientIP: clientIP})
+	retryAfter := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(retryAfter))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": "Too many failed login attempts, please try again later", "retry_after": retryAfter})
+	return true
+}
+
+// recordLoginFailure 将一次错误的密码或验证码计入失败次数，并记录失败和因此产生的锁定
+func recordLoginFailure(conf *config.Config, limiter *auth.LoginLimiter, audit *store.AuditLog, event, username, clientIP, detail string) {
+	for _, locked := range limiter.RecordFailure(username, clientIP) {
+		log.Printf("Locked %s %s for %s after %d failed login attempts", locked.Kind, locked.Key, conf.LoginLockoutDuration, locked.Failures)
+		recordAudit(audit, store.AuditEntry{
+			Event:    store.AuditLockout,
+			Username: username,
+			ClientIP: clientIP,
+			Detail:   fmt.Sprintf("%s %s locked after %d failed attempts", locked.Kind, locked.Key, locked.Failures),
+		})
+	}
+	recordAudit(audit, store.AuditEntry{Event: event, Username: username, ClientIP: clientIP, Detail: detail})
+}
+
+// completeLogin 在第一因素认证成功后确定用户的角色，密码登录和 OIDC 登录共用。
+// 用户启用了两步验证或其角色要求两步验证时返回挑战，由 MFAVerifyHandler 完成登录；否则直接签发 token。
+func completeLogin(c *gin.Context, conf *config.Config, executor services.Executor, directory auth.Directory, tokenStore *store.TokenStore, refreshTokens *store.RefreshTokenStore, totpStore *store.TOTPStore, challenges *store.MFAChallengeStore, identity *auth.Identity) loginOutcome {
+	username := identity.Username
+
+	var role string
+	if conf.RoleSource == config.RoleSourceLDAP && identity.Backend == config.AuthBackendLDAP {
+		// 组已经在认证的同一个 LDAP 连接上取得
+		role = auth.RoleForGroups(conf, identity.Groups)
+	} else {
+		role = resolveRole(c.Request.Context(), conf, executor, directory, username)
+	}
 
-		customToken, err := auth.GenerateCustomToken(cfg, payload.Username)
+	// 两步验证记录以规范化的用户名保存，LDAP 等后端不区分大小写，改变大小写不能绕过两步验证
+	status, err := totpStore.Status(auth.NormalizeUsername(username))
+	if err != nil {
+		log.Printf("Failed to read TOTP status of user %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check two-factor authentication"})
+		return loginFailed
+	}
+	if status.Enabled || conf.TOTPRequired(role) {
+		token, err := challenges.Issue(store.MFAChallenge{
+			Username: username,
+			Role:     role,
+			Backend:  identity.Backend,
+			ClientIP: c.ClientIP(),
+			Enroll:   !status.Enabled,
+		})
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate custom token"})
+			log.Printf("Failed to issue MFA challenge for user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start two-factor authentication"})
+			return loginFailed
+		}
+		log.Printf("User %s passed %s authentication, waiting for the second factor", username, identity.Backend)
+		c.JSON(http.StatusOK, gin.H{"mfa_required": true, "mfa_token": token, "enrollment_required": !status.Enabled})
+		return loginChallenged
+	}
+
+	log.Printf("User %s logged in via %s with role: %s", username, identity.Backend, role)
+	return startSession(c, conf, executor, tokenStore, refreshTokens, username, role, false, nil)
+}
+
+// startSession 为完成认证的用户签发 Slurm token 和 dashboard token。mfa 表示本次登录是否通过了两步验证，
+// extra 中的字段会合并到响应中。返回 loginIssued 或 loginFailed
+func startSession(c *gin.Context, conf *config.Config, executor services.Executor, tokenStore *store.TokenStore, refreshTokens *store.RefreshTokenStore, username, role string, mfa bool, extra gin.H) loginOutcome {
+	// 新的登录会话重新采集用户的登录环境，使 profile 的修改在重新登录后生效
+	executor.BeginSession(username)
+
+	slurmToken, err := services.GetSlurmToken(c.Request.Context(), executor, username, conf.SlurmTokenLifespanSec)
+	if err != nil {
+		log.Printf("Slurm token generation error for user %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate Slurm token"})
+		return loginFailed
+	}
+
+	expiresAt := time.Now().Add(conf.SlurmTokenLifespan())
+	if err := tokenStore.Set(username, slurmToken, expiresAt); err != nil {
+		log.Printf("Failed to store Slurm token for user %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store Slurm token"})
+		return loginFailed
+	}
+	log.Printf("Stored Slurm token for user: %s", username)
+
+	response, err := issueTokens(conf, refreshTokens, username, role, "", time.Time{}, mfa)
+	if err != nil {
+		log.Printf("Failed to issue tokens for user %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate custom token"})
+		return loginFailed
+	}
+	for key, value := range extra {
+		response[key] = value
+	}
+	respondWithTokens(c, conf, response, "")
+	return loginIssued
+}
+
+// LogoutPayload 定义了登出的可选参数
+type LogoutPayload struct {
+	// TerminateSessions 为 true 时同时结束该用户所有运行中的 salloc 交互式会话
+	TerminateSessions bool `json:"terminate_sessions"`
+}
+
+// LogoutHandler 吊销当前的 dashboard token 及其所属登录的 refresh token，并删除用户的 Slurm token 和登录 cookie
+func LogoutHandler(cfg *config.Provider, tokenStore *store.TokenStore, sessionStore *store.SessionStore, revocations *store.RevocationStore, refreshTokens *store.RefreshTokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet("claims").(*auth.CustomClaims)
+		username := claims.Username
+
+		var payload LogoutPayload
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&payload); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+				return
+			}
+		}
+
+		if err := revocations.Revoke(claims.ID, claims.ExpiresAt.Time); err != nil {
+			log.Printf("Failed to revoke token of user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
 			return
 		}
+		if claims.FamilyID != "" {
+			if err := refreshTokens.RevokeFamily(claims.FamilyID); err != nil {
+				log.Printf("Failed to revoke refresh tokens of user %s: %v", username, err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to revoke token"})
+				return
+			}
+		}
+		if err := tokenStore.Delete(username); err != nil {
+			log.Printf("Failed to delete Slurm token of user %s: %v", username, err)
+		}
+
+		terminated := 0
+		if payload.TerminateSessions {
+			terminated = sessionStore.TerminateUser(username)
+		}
+		log.Printf("User %s logged out, terminated %d interactive sessions", username, terminated)
+		clearSessionCookies(c, cfg.Get())
 
-		c.JSON(http.StatusOK, gin.H{"token": customToken, "user": gin.H{"username": payload.Username, "role": role}})
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out", "terminated_sessions": terminated})
 	}
 }
@@ -0,0 +1,207 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/store"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestLoginHandler(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct", "alice", "hpc-admins")
+	env.cli.Handle("scontrol", func(username string, args []string) (string, error) {
+		if username != "" {
+			t.Errorf("scontrol token should run as the service user, ran as %q", username)
+		}
+		if len(args) != 3 || args[0] != "token" || args[1] != "username=alice" {
+			t.Errorf("unexpected scontrol arguments %q", args)
+		}
+		return "SLURM_JWT=minted-token\n", nil
+	})
+
+	w := env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"alice","password":"correct"}`))
+	expectStatus(t, w, http.StatusOK)
+
+	var resp struct {
+		Token string `json:"token"`
+		User  struct {
+			Username string `json:"username"`
+			Role     string `json:"role"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if resp.User.Role != "admin" {
+		t.Errorf("expected admin role for hpc-admins member, got %q", resp.User.Role)
+	}
+	if sessions := env.cli.Sessions(); len(sessions) != 1 || sessions[0] != "alice" {
+		t.Errorf("expected a new executor session for alice, got %v", sessions)
+	}
+	if token, ok := env.tokenStore.Get("alice"); !ok || token != "minted-token" {
+		t.Errorf("expected slurm token to be stored, got %q", token)
+	}
+
+	claims := &auth.CustomClaims{}
+	if _, err := jwt.ParseWithClaims(resp.Token, claims, func(*jwt.Token) (interface{}, error) {
+		return []byte(env.cfg.Get().JWTSecretKey), nil
+	}); err != nil || claims.Username != "alice" {
+		t.Fatalf("returned token is invalid: %v (%+v)", err, claims)
+	}
+	if claims.Role != auth.RoleAdmin {
+		t.Errorf("expected the role to be embedded in the token, got %q", claims.Role)
+	}
+}
+
+func TestLoginHandlerRejectsBadPassword(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct")
+
+	w := env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"alice","password":"wrong"}`))
+	expectStatus(t, w, http.StatusUnauthorized)
+
+	if _, ok := env.tokenStore.Get("alice"); ok {
+		t.Error("no slurm token should be stored after a failed login")
+	}
+	if len(env.cli.Calls()) != 0 {
+		t.Errorf("no commands should run after a failed login, got %+v", env.cli.Calls())
+	}
+}
+
+func TestLogoutRevokesToken(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+
+	w := env.do(http.MethodPost, "/api/logout", token, nil)
+	expectStatus(t, w, http.StatusOK)
+
+	if _, ok := env.tokenStore.Get("alice"); ok {
+		t.Error("slurm token should be deleted on logout")
+	}
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/jobs", token, nil), http.StatusUnauthorized)
+	expectStatus(t, env.do(http.MethodPost, "/api/v1/ws/ticket", token, strings.NewReader(`{"target":"shell"}`)), http.StatusUnauthorized)
+	expectStatus(t, env.do(http.MethodPost, "/api/logout", token, nil), http.StatusUnauthorized)
+
+	// 其他会话签发的 token 不受影响
+	other := env.login(t, "alice")
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/jobs", other, nil), http.StatusOK)
+}
+
+func TestTokenWithoutIDIsRejected(t *testing.T) {
+	env := newTestEnv(t)
+	env.login(t, "alice")
+
+	claims := auth.CustomClaims{Username: "alice", RegisteredClaims: jwt.RegisteredClaims{
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}}
+	legacy, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(env.cfg.Get().JWTSecretKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/jobs", legacy, nil), http.StatusUnauthorized)
+}
+
+func TestLoginLockout(t *testing.T) {
+	env := newTestEnv(t)
+	conf := env.cfg.Get()
+	conf.LoginMaxFailures = 3
+	conf.LoginBackoffBase = 0
+	env.directory.addUser("alice", "correct")
+	env.cli.Handle("scontrol", func(username string, args []string) (string, error) {
+		return "SLURM_JWT=minted-token\n", nil
+	})
+	login := func(username, password string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"username":%q,"password":%q}`, username, password)
+		return env.do(http.MethodPost, "/api/login", "", strings.NewReader(body))
+	}
+
+	for i := 0; i < 3; i++ {
+		expectStatus(t, login("alice", "wrong"), http.StatusUnauthorized)
+	}
+	// 锁定期间即使密码正确也不能登录，大小写不同的用户名同样被锁定
+	w := login("ALICE", "correct")
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected a Retry-After header")
+	}
+	if _, ok := env.tokenStore.Get("alice"); ok {
+		t.Error("no slurm token should be stored for a locked account")
+	}
+
+	failed, _ := env.audit.List(store.AuditFilter{Username: "alice", Event: store.AuditLoginFailed})
+	blocked, _ := env.audit.List(store.AuditFilter{Username: "alice", Event: store.AuditLoginBlocked})
+	lockouts, _ := env.audit.List(store.AuditFilter{Username: "alice", Event: store.AuditLockout})
+	if len(failed) != 3 || len(blocked) != 1 || len(lockouts) != 1 || failed[0].ClientIP == "" {
+		t.Fatalf("unexpected audit entries: %d failed, %d blocked, %d lockouts", len(failed), len(blocked), len(lockouts))
+	}
+
+	// 管理员查看并解除锁定
+	admin := env.loginAs(t, "root", auth.RoleAdmin)
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/admin/lockouts", env.login(t, "bob"), nil), http.StatusForbidden)
+	w = env.do(http.MethodGet, "/api/v1/admin/lockouts", admin, nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Lockouts []auth.LoginAttempt `json:"lockouts"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || len(resp.Lockouts) == 0 || !resp.Lockouts[0].Locked || resp.Lockouts[0].Key != "alice" {
+		t.Fatalf("expected alice to be listed as locked, got %s", w.Body.String())
+	}
+	expectStatus(t, env.do(http.MethodDelete, "/api/v1/admin/lockouts/user/alice", admin, nil), http.StatusOK)
+	expectStatus(t, env.do(http.MethodDelete, "/api/v1/admin/lockouts/user/alice", admin, nil), http.StatusNotFound)
+	expectStatus(t, env.do(http.MethodDelete, "/api/v1/admin/lockouts/host/alice", admin, nil), http.StatusBadRequest)
+
+	expectStatus(t, login("alice", "correct"), http.StatusOK)
+
+	w = env.do(http.MethodGet, "/api/v1/admin/audit?event=lockout_cleared", admin, nil)
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"actor":"root"`) {
+		t.Errorf("expected the admin to be recorded in the audit log: %s", w.Body.String())
+	}
+}
+
+func TestLoginFailureKeepsFailureCount(t *testing.T) {
+	env := newTestEnv(t)
+	conf := env.cfg.Get()
+	conf.LoginMaxFailures = 3
+	conf.LoginBackoffBase = 0
+	env.directory.addUser("alice", "correct")
+	env.cli.Handle("scontrol", func(username string, args []string) (string, error) {
+		return "", errors.New("slurmctld is down")
+	})
+
+	for i := 0; i < 2; i++ {
+		expectStatus(t, env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"alice","password":"wrong"}`)), http.StatusUnauthorized)
+	}
+	// 密码正确但没有签发 token，不能清除之前的失败计数
+	expectStatus(t, env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"alice","password":"correct"}`)), http.StatusInternalServerError)
+	for _, attempt := range env.limiter.List() {
+		if attempt.Kind == auth.LockoutUser && attempt.Key == "alice" && attempt.Failures == 2 {
+			return
+		}
+	}
+	t.Fatalf("expected alice to keep 2 failed attempts, got %+v", env.limiter.List())
+}
+
+func TestLoginBackoff(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct")
+
+	w := env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"alice","password":"wrong"}`))
+	expectStatus(t, w, http.StatusUnauthorized)
+	// 默认配置下下一次尝试需要等待 1s
+	w = env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"alice","password":"correct"}`))
+	expectStatus(t, w, http.StatusTooManyRequests)
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+}
@@ -0,0 +1,371 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errMFACodeInvalid 表示验证码或恢复码错误，或验证码已经使用过
+var errMFACodeInvalid = errors.New("invalid verification code")
+
+type MFAChallengePayload struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+}
+
+type MFAVerifyPayload struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	// Code 为验证器应用中的 6 位验证码，或者一个恢复码
+	Code string `json:"code" binding:"required"`
+}
+
+type MFACodePayload struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// verifySecondFactor 校验 6 位 TOTP 验证码或恢复码，返回使用的方式 "totp" 或 "recovery_code"
+func verifySecondFactor(totpStore *store.TOTPStore, username, code string) (string, error) {
+	code = strings.TrimSpace(code)
+	if len(code) != 6 {
+		remaining, err := totpStore.UseRecoveryCode(username, code)
+		if errors.Is(err, store.ErrRecoveryCodeInvalid) {
+			return "", errMFACodeInvalid
+		}
+		if err != nil {
+			return "", err
+		}
+		log.Printf("User %s used a recovery code, %d remaining", username, remaining)
+		return "recovery_code", nil
+	}
+
+	secret, enabled, err := totpStore.Secret(username)
+	if err != nil {
+		return "", err
+	}
+	if !enabled {
+		return "", store.ErrTOTPNotEnrolled
+	}
+	step, ok := auth.VerifyTOTP(secret, code, time.Now())
+	if !ok {
+		return "", errMFACodeInvalid
+	}
+	if err := totpStore.UseStep(username, step); errors.Is(err, store.ErrTOTPReplay) {
+		return "", errMFACodeInvalid
+	} else if err != nil {
+		return "", err
+	}
+	return "totp", nil
+}
+
+// activateTOTP 用验证码确认用户正在绑定的密钥并启用两步验证，返回新生成的恢复码
+func activateTOTP(totpStore *store.TOTPStore, username, code string) ([]string, error) {
+	secret, enabled, err := totpStore.Secret(username)
+	if err != nil {
+		return nil, err
+	}
+	if enabled {
+		return nil, store.ErrTOTPAlreadyEnabled
+	}
+	step, ok := auth.VerifyTOTP(secret, code, time.Now())
+	if !ok {
+		return nil, errMFACodeInvalid
+	}
+	codes, err := auth.NewRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := totpStore.Activate(username, step, codes); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// MFAEnrollHandler 为角色要求两步验证但尚未绑定的用户在登录过程中生成 TOTP 密钥
+func MFAEnrollHandler(cfg *config.Provider, totpStore *store.TOTPStore, challenges *store.MFAChallengeStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload MFAChallengePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		challenge, err := challenges.Get(payload.MFAToken, c.ClientIP())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Login request expired, please login again"})
+			return
+		}
+		if !challenge.Enroll {
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		}
+		beginTOTPEnrollment(c, cfg.Get(), totpStore, auth.NormalizeUsername(challenge.Username))
+	}
+}
+
+// MFAVerifyHandler 校验登录过程中的两步验证码并完成登录。
+// 绑定中的用户用验证码确认新密钥，响应中同时返回只显示一次的恢复码。
+func MFAVerifyHandler(cfg *config.Provider, executor services.Executor, tokenStore *store.TokenStore, refreshTokens *store.RefreshTokenStore, totpStore *store.TOTPStore, challenges *store.MFAChallengeStore, limiter *auth.LoginLimiter, audit *store.AuditLog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conf := cfg.Get()
+
+		var payload MFAVerifyPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		clientIP := c.ClientIP()
+		challenge, err := challenges.Get(payload.MFAToken, clientIP)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Login request expired, please login again"})
+			return
+		}
+		// 两步验证记录以规范化的用户名保存，以不同大小写登录同一账户时必须找到同一条记录
+		username, account := challenge.Username, auth.NormalizeUsername(challenge.Username)
+		if rejectThrottledLogin(c, limiter, audit, account, clientIP) {
+			return
+		}
+
+		var (
+			method   string
+			recovery []string
+		)
+		if challenge.Enroll {
+			method = "totp"
+			recovery, err = activateTOTP(totpStore, account, payload.Code)
+		} else {
+			method, err = verifySecondFactor(totpStore, account, payload.Code)
+		}
+		switch {
+		case errors.Is(err, errMFACodeInvalid):
+			recordLoginFailure(conf, limiter, audit, store.AuditMFAFailed, account, clientIP, "invalid verification code")
+			remaining := challenges.Fail(payload.MFAToken)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid verification code", "attempts_remaining": remaining})
+			return
+		case challenge.Enroll && errors.Is(err, store.ErrTOTPNotEnrolled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Start the enrollment first"})
+			return
+		case errors.Is(err, store.ErrTOTPUnreadable):
+			log.Printf("Rejecting two-factor login of user %s: %v", username, err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is unavailable for this account, please contact an administrator"})
+			return
+		case errors.Is(err, store.ErrTOTPNotEnrolled), errors.Is(err, store.ErrTOTPAlreadyEnabled):
+			// 绑定在其他会话中被重置或完成了
+			challenges.Redeem(payload.MFAToken)
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication changed, please login again"})
+			return
+		case err != nil:
+			log.Printf("Failed to verify second factor of user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify the code"})
+			return
+		}
+		if err := challenges.Redeem(payload.MFAToken); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Login request expired, please login again"})
+			return
+		}
+
+		var extra gin.H
+		if recovery != nil {
+			recordAudit(audit, store.AuditEntry{Event: store.AuditMFAEnabled, Username: account, ClientIP: clientIP})
+			extra = gin.H{"recovery_codes": recovery}
+		}
+		if method == "recovery_code" {
+			recordAudit(audit, store.AuditEntry{Event: store.AuditRecoveryCodeUsed, Username: account, ClientIP: clientIP})
+		}
+		log.Printf("User %s logged in via %s and %s with role: %s", username, challenge.Backend, method, challenge.Role)
+		if startSession(c, conf, executor, tokenStore, refreshTokens, username, challenge.Role, true, extra) == loginIssued {
+			limiter.RecordSuccess(account)
+		}
+	}
+}
+
+// beginTOTPEnrollment 生成新的 TOTP 密钥并保存为未启用状态，返回绑定验证器应用所需的信息
+func beginTOTPEnrollment(c *gin.Context, conf *config.Config, totpStore *store.TOTPStore, username string) {
+	enrollment, err := auth.NewTOTPEnrollment(conf.TOTPIssuer, username)
+	if err != nil {
+		log.Printf("Failed to generate TOTP secret for user %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate TOTP secret"})
+		return
+	}
+	err = totpStore.Begin(username, enrollment.Secret)
+	if errors.Is(err, store.ErrTOTPAlreadyEnabled) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+		return
+	}
+	if errors.Is(err, store.ErrTOTPUnavailable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Two-factor authentication is not available on this server"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to store TOTP secret for user %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store TOTP secret"})
+		return
+	}
+	c.JSON(http.StatusOK, enrollment)
+}
+
+// TOTPStatusHandler 返回当前用户的两步验证状态、其角色是否要求两步验证以及服务器是否允许绑定
+func TOTPStatusHandler(cfg *config.Provider, totpStore *store.TOTPStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := auth.NormalizeUsername(c.GetString("username"))
+		status, err := totpStore.Status(username)
+		if err != nil {
+			log.Printf("Failed to read TOTP status of user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read two-factor authentication status"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":                  status.Enabled,
+			"enabled_at":               status.EnabledAt,
+			"recovery_codes_remaining": status.RecoveryCodesRemaining,
+			"required":                 cfg.Get().TOTPRequired(c.GetString("role")),
+			"available":                totpStore.Available(),
+		})
+	}
+}
+
+// TOTPEnrollHandler 为当前用户生成新的 TOTP 密钥，用户用验证码确认后才会启用
+func TOTPEnrollHandler(cfg *config.Provider, totpStore *store.TOTPStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		beginTOTPEnrollment(c, cfg.Get(), totpStore, auth.NormalizeUsername(c.GetString("username")))
+	}
+}
+
+// TOTPActivateHandler 用验证码确认当前用户正在绑定的密钥并启用两步验证，返回只显示一次的恢复码
+func TOTPActivateHandler(totpStore *store.TOTPStore, audit *store.AuditLog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := auth.NormalizeUsername(c.GetString("username"))
+		var payload MFACodePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		codes, err := activateTOTP(totpStore, username, payload.Code)
+		switch {
+		case errors.Is(err, errMFACodeInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+			return
+		case errors.Is(err, store.ErrTOTPNotEnrolled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Start the enrollment first"})
+			return
+		case errors.Is(err, store.ErrTOTPAlreadyEnabled):
+			c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is already enabled"})
+			return
+		case err != nil:
+			log.Printf("Failed to enable TOTP for user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enable two-factor authentication"})
+			return
+		}
+		log.Printf("User %s enabled two-factor authentication", username)
+		recordAudit(audit, store.AuditEntry{Event: store.AuditMFAEnabled, Username: username, ClientIP: c.ClientIP()})
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// RegenerateRecoveryCodesHandler 在校验验证码后为当前用户生成新的恢复码，原有的恢复码全部作废
+func RegenerateRecoveryCodesHandler(totpStore *store.TOTPStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := auth.NormalizeUsername(c.GetString("username"))
+		var payload MFACodePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if !checkCurrentTOTP(c, totpStore, username, payload.Code) {
+			return
+		}
+
+		codes, err := auth.NewRecoveryCodes()
+		if err == nil {
+			err = totpStore.ReplaceRecoveryCodes(username, codes)
+		}
+		if err != nil {
+			log.Printf("Failed to regenerate recovery codes for user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to regenerate recovery codes"})
+			return
+		}
+		log.Printf("User %s regenerated recovery codes", username)
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// DisableTOTPHandler 在校验验证码后停用当前用户的两步验证，角色要求两步验证时不允许停用
+func DisableTOTPHandler(cfg *config.Provider, totpStore *store.TOTPStore, audit *store.AuditLog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := auth.NormalizeUsername(c.GetString("username"))
+		if cfg.Get().TOTPRequired(c.GetString("role")) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is required for your role"})
+			return
+		}
+		var payload MFACodePayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		if !checkCurrentTOTP(c, totpStore, username, payload.Code) {
+			return
+		}
+
+		if err := totpStore.Delete(username); err != nil {
+			log.Printf("Failed to disable TOTP for user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to disable two-factor authentication"})
+			return
+		}
+		log.Printf("User %s disabled two-factor authentication", username)
+		recordAudit(audit, store.AuditEntry{Event: store.AuditMFADisabled, Username: username, ClientIP: c.ClientIP()})
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+	}
+}
+
+// ResetTOTPHandler 供管理员删除丢失了验证器和恢复码的用户的两步验证，
+// 角色要求两步验证的用户下次登录时重新绑定
+func ResetTOTPHandler(totpStore *store.TOTPStore, audit *store.AuditLog) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := auth.NormalizeUsername(c.Param("username"))
+		status, err := totpStore.Status(username)
+		if err == nil && !status.Enabled {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Two-factor authentication is not enabled for this user"})
+			return
+		}
+		if err == nil {
+			err = totpStore.Delete(username)
+		}
+		if err != nil {
+			log.Printf("Failed to reset TOTP for user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset two-factor authentication"})
+			return
+		}
+
+		admin := c.GetString("username")
+		log.Printf("Admin %s reset two-factor authentication of user %s", admin, username)
+		recordAudit(audit, store.AuditEntry{Event: store.AuditMFADisabled, Username: username, Actor: admin, Detail: "reset by admin"})
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+	}
+}
+
+// checkCurrentTOTP 校验已启用两步验证的用户在修改设置前输入的验证码或恢复码，失败时写入响应并返回 false
+func checkCurrentTOTP(c *gin.Context, totpStore *store.TOTPStore, username, code string) bool {
+	_, err := verifySecondFactor(totpStore, username, code)
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, errMFACodeInvalid):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid verification code"})
+	case errors.Is(err, store.ErrTOTPNotEnrolled):
+		c.JSON(http.StatusConflict, gin.H{"error": "Two-factor authentication is not enabled"})
+	case errors.Is(err, store.ErrTOTPUnreadable):
+		c.JSON(http.StatusForbidden, gin.H{"error": "Two-factor authentication is unavailable for this account, please contact an administrator"})
+	default:
+		log.Printf("Failed to verify second factor of user %s: %v", username, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify the code"})
+	}
+	return false
+}
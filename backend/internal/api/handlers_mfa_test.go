@@ -0,0 +1,230 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/store"
+
+	"github.com/pquerna/otp/totp"
+)
+
+type mfaResponse struct {
+	Token              string   `json:"token"`
+	MFARequired        bool     `json:"mfa_required"`
+	MFAToken           string   `json:"mfa_token"`
+	EnrollmentRequired bool     `json:"enrollment_required"`
+	AttemptsRemaining  int      `json:"attempts_remaining"`
+	Secret             string   `json:"secret"`
+	RecoveryCodes      []string `json:"recovery_codes"`
+}
+
+func decodeMFAResponse(t *testing.T, body []byte) mfaResponse {
+	t.Helper()
+	var resp mfaResponse
+	if err := json.Unmarshal(body, &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	return resp
+}
+
+func totpCode(t *testing.T, secret string, at time.Time) string {
+	t.Helper()
+	code, err := totp.GenerateCode(secret, at)
+	if err != nil {
+		t.Fatalf("failed to generate TOTP code: %v", err)
+	}
+	return code
+}
+
+func TestTOTPLogin(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.Get().LoginBackoffBase = 0
+	env.directory.addUser("alice", "correct")
+	env.cli.Output("scontrol", "SLURM_JWT=slurm-token-alice\n")
+
+	// 用已登录的会话绑定验证器
+	session := env.login(t, "alice")
+	w := env.do(http.MethodPost, "/api/v1/mfa/enroll", session, nil)
+	expectStatus(t, w, http.StatusOK)
+	secret := decodeMFAResponse(t, w.Body.Bytes()).Secret
+	expectStatus(t, env.do(http.MethodPost, "/api/v1/mfa/activate", session, strings.NewReader(`{"code":"000000x"}`)), http.StatusBadRequest)
+	w = env.do(http.MethodPost, "/api/v1/mfa/activate", session, strings.NewReader(`{"code":"`+totpCode(t, secret, time.Now())+`"}`))
+	expectStatus(t, w, http.StatusOK)
+	recovery := decodeMFAResponse(t, w.Body.Bytes()).RecoveryCodes
+	if len(recovery) != 10 {
+		t.Fatalf("expected 10 recovery codes, got %v", recovery)
+	}
+	if err := env.tokenStore.Delete("alice"); err != nil {
+		t.Fatalf("failed to delete slurm token: %v", err)
+	}
+
+	login := func() mfaResponse {
+		t.Helper()
+		w := env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"alice","password":"correct"}`))
+		expectStatus(t, w, http.StatusOK)
+		resp := decodeMFAResponse(t, w.Body.Bytes())
+		if !resp.MFARequired || resp.MFAToken == "" || resp.Token != "" {
+			t.Fatalf("expected an MFA challenge instead of a token, got %s", w.Body.String())
+		}
+		return resp
+	}
+	verify := func(mfaToken, code string) *mfaResponse {
+		t.Helper()
+		w := env.do(http.MethodPost, "/api/login/mfa", "", strings.NewReader(`{"mfa_token":"`+mfaToken+`","code":"`+code+`"}`))
+		resp := decodeMFAResponse(t, w.Body.Bytes())
+		return &resp
+	}
+
+	challenge := login()
+	if _, ok := env.tokenStore.Get("alice"); ok {
+		t.Error("no slurm token should be minted before the second factor is verified")
+	}
+	if resp := verify(challenge.MFAToken, "123456"); resp.Token != "" || resp.AttemptsRemaining != 4 {
+		t.Fatalf("expected a wrong code to be rejected with 4 attempts remaining, got %+v", resp)
+	}
+	code := totpCode(t, secret, time.Now().Add(30*time.Second))
+	if resp := verify(challenge.MFAToken, code); resp.Token == "" {
+		t.Fatal("expected a valid code to complete the login")
+	}
+	if _, ok := env.tokenStore.Get("alice"); !ok {
+		t.Error("expected the slurm token to be stored after the second factor")
+	}
+	// 挑战只能使用一次，同一个验证码也不能在新的挑战中重放
+	expectStatus(t, env.do(http.MethodPost, "/api/login/mfa", "", strings.NewReader(`{"mfa_token":"`+challenge.MFAToken+`","code":"`+code+`"}`)), http.StatusBadRequest)
+	if resp := verify(login().MFAToken, code); resp.Token != "" {
+		t.Fatal("a used TOTP code must not be accepted again")
+	}
+
+	if resp := verify(login().MFAToken, strings.ToUpper(recovery[0])); resp.Token == "" {
+		t.Fatal("expected a recovery code to complete the login")
+	}
+	if resp := verify(login().MFAToken, recovery[0]); resp.Token != "" {
+		t.Fatal("a used recovery code must not be accepted again")
+	}
+	if used, _ := env.audit.List(store.AuditFilter{Username: "alice", Event: store.AuditRecoveryCodeUsed}); len(used) != 1 {
+		t.Errorf("expected one recovery_code_used audit entry, got %v", used)
+	}
+	w = env.do(http.MethodGet, "/api/v1/mfa", session, nil)
+	expectStatus(t, w, http.StatusOK)
+	var status struct {
+		Enabled   bool `json:"enabled"`
+		Remaining int  `json:"recovery_codes_remaining"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil || !status.Enabled || status.Remaining != 9 {
+		t.Errorf("expected TOTP enabled with 9 recovery codes, got %s", w.Body.String())
+	}
+
+	// 管理员重置后不再需要两步验证
+	admin := env.loginAs(t, "root", "admin")
+	expectStatus(t, env.do(http.MethodDelete, "/api/v1/admin/mfa/alice", admin, nil), http.StatusOK)
+	expectStatus(t, env.do(http.MethodDelete, "/api/v1/admin/mfa/alice", admin, nil), http.StatusNotFound)
+	env.loginWithRefresh(t, "alice", "correct")
+}
+
+func TestTOTPEnforcedEnrollment(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.Get().TOTPRequiredRoles = []string{"admin"}
+	env.directory.addUser("alice", "correct", "hpc-admins")
+	env.cli.Output("scontrol", "SLURM_JWT=slurm-token-alice\n")
+
+	w := env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"alice","password":"correct"}`))
+	expectStatus(t, w, http.StatusOK)
+	challenge := decodeMFAResponse(t, w.Body.Bytes())
+	if !challenge.MFARequired || !challenge.EnrollmentRequired {
+		t.Fatalf("expected an enrollment challenge, got %s", w.Body.String())
+	}
+	verifyBody := func(code string) *strings.Reader {
+		return strings.NewReader(`{"mfa_token":"` + challenge.MFAToken + `","code":"` + code + `"}`)
+	}
+	expectStatus(t, env.do(http.MethodPost, "/api/login/mfa", "", verifyBody("123456")), http.StatusConflict)
+
+	w = env.do(http.MethodPost, "/api/login/mfa/enroll", "", strings.NewReader(`{"mfa_token":"`+challenge.MFAToken+`"}`))
+	expectStatus(t, w, http.StatusOK)
+	secret := decodeMFAResponse(t, w.Body.Bytes()).Secret
+	if secret == "" {
+		t.Fatal("expected a TOTP secret")
+	}
+	w = env.do(http.MethodPost, "/api/login/mfa", "", verifyBody(totpCode(t, secret, time.Now())))
+	expectStatus(t, w, http.StatusOK)
+	resp := decodeMFAResponse(t, w.Body.Bytes())
+	if resp.Token == "" || len(resp.RecoveryCodes) != 10 {
+		t.Fatalf("expected a token and 10 recovery codes, got %s", w.Body.String())
+	}
+	pair := decodeTokenPair(t, w.Body.Bytes())
+
+	// 角色要求两步验证时不能自行停用
+	expectStatus(t, env.do(http.MethodPost, "/api/v1/mfa/disable", pair.Token, strings.NewReader(`{"code":"`+resp.RecoveryCodes[0]+`"}`)), http.StatusForbidden)
+	if status, body := env.refresh(pair.RefreshToken); status != http.StatusOK {
+		t.Fatalf("expected refresh of an MFA login to succeed, got %d: %s", status, body)
+	}
+}
+
+func TestRefreshRequiresTOTPAfterPromotion(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct")
+	pair := env.loginWithRefresh(t, "alice", "correct")
+
+	// 用户被提升为要求两步验证的角色后，未经两步验证的登录不能继续续期
+	env.cfg.Get().TOTPRequiredRoles = []string{"admin"}
+	env.directory.setGroups("alice", "hpc-admins")
+	if status, body := env.refresh(pair.RefreshToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected refresh without MFA to be rejected, got %d: %s", status, body)
+	}
+}
+
+func TestTOTPLoginIgnoresUsernameCase(t *testing.T) {
+	env := newTestEnv(t)
+	env.cfg.Get().LoginBackoffBase = 0
+	env.directory.addUser("alice", "correct")
+	// LDAP 查找用户时不区分大小写，认证结果中的用户名保持输入的大小写
+	env.directory.addUser("ALICE", "correct")
+	env.cli.Output("scontrol", "SLURM_JWT=slurm-token-alice\n")
+
+	session := env.login(t, "alice")
+	secret := decodeMFAResponse(t, env.do(http.MethodPost, "/api/v1/mfa/enroll", session, nil).Body.Bytes()).Secret
+	w := env.do(http.MethodPost, "/api/v1/mfa/activate", session, strings.NewReader(`{"code":"`+totpCode(t, secret, time.Now())+`"}`))
+	expectStatus(t, w, http.StatusOK)
+
+	// 改变大小写不能绕过两步验证
+	w = env.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"ALICE","password":"correct"}`))
+	expectStatus(t, w, http.StatusOK)
+	challenge := decodeMFAResponse(t, w.Body.Bytes())
+	if !challenge.MFARequired || challenge.Token != "" {
+		t.Fatalf("expected an MFA challenge for a differently cased username, got %s", w.Body.String())
+	}
+	// 失败计入规范化的用户名，登录完成后同一条记录被清除
+	expectStatus(t, env.do(http.MethodPost, "/api/login/mfa", "", strings.NewReader(`{"mfa_token":"`+challenge.MFAToken+`","code":"123456"}`)), http.StatusUnauthorized)
+	w = env.do(http.MethodPost, "/api/login/mfa", "", strings.NewReader(`{"mfa_token":"`+challenge.MFAToken+`","code":"`+totpCode(t, secret, time.Now().Add(30*time.Second))+`"}`))
+	expectStatus(t, w, http.StatusOK)
+	for _, attempt := range env.limiter.List() {
+		if attempt.Key == "alice" {
+			t.Errorf("expected the failed attempt of alice to be cleared, got %+v", attempt)
+		}
+	}
+	w = env.do(http.MethodGet, "/api/v1/mfa", env.login(t, "ALICE"), nil)
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"enabled":true`) {
+		t.Errorf("expected the same TOTP record for a differently cased session, got %s", w.Body.String())
+	}
+}
+
+func TestTOTPUnavailableWithoutEncryptionSecrets(t *testing.T) {
+	env := newTestEnv(t, func(c *config.Config) { c.TokenEncryptionSecrets = nil })
+	session := env.login(t, "alice")
+
+	// 不能用由 jwt_secret_key 派生的密钥保存 TOTP 密钥
+	w := env.do(http.MethodGet, "/api/v1/mfa", session, nil)
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"available":false`) {
+		t.Errorf("expected TOTP to be reported as unavailable, got %s", w.Body.String())
+	}
+	expectStatus(t, env.do(http.MethodPost, "/api/v1/mfa/enroll", session, nil), http.StatusServiceUnavailable)
+	if status, err := env.totp.Status("alice"); err != nil || status.Enabled {
+		t.Errorf("expected no TOTP record, got %+v, %v", status, err)
+	}
+}
@@ -0,0 +1,129 @@
+package api
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcStateCookieName 保存发起单点登录的浏览器所拿到的 state 的哈希，回调时必须与提交的 state 一致，
+// 防止攻击者把自己的授权码和 state 交给受害者的浏览器完成登录（login CSRF）
+const oidcStateCookieName = "dashboard_oidc_state"
+
+type OIDCCallbackPayload struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+// OIDCConfigHandler 告诉登录页是否提供单点登录
+func OIDCConfigHandler(cfg *config.Provider) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"enabled": cfg.Get().OIDCEnabled()})
+	}
+}
+
+// OIDCLoginHandler 开始一次单点登录，前端保存返回的 state 后跳转到 authorization_url
+func OIDCLoginHandler(cfg *config.Provider, oidcAuth *auth.OIDCAuthenticator) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authURL, state, err := oidcAuth.AuthCodeURL(c.Request.Context())
+		if errors.Is(err, auth.ErrOIDCDisabled) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not enabled"})
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to start OIDC login: %v", err)
+			c.JSON(http.StatusBadGateway, gin.H{"error": "Identity provider is unavailable"})
+			return
+		}
+		setOIDCStateCookie(c, cfg.Get(), hashOIDCState(state), auth.OIDCLoginTTL)
+		c.JSON(http.StatusOK, gin.H{"authorization_url": authURL, "state": state})
+	}
+}
+
+// OIDCCallbackHandler 接收前端回调页转交的授权码，登录成功时返回与 /api/login 相同的 token。
+// 映射得到的用户名必须存在于用户目录中，否则任何身份提供者账号都可以冒用集群用户名
+func OIDCCallbackHandler(cfg *config.Provider, executor services.Executor, oidcAuth *auth.OIDCAuthenticator, accounts *auth.AccountChecker, directory auth.Directory, tokenStore *store.TokenStore, refreshTokens *store.RefreshTokenStore, totpStore *store.TOTPStore, challenges *store.MFAChallengeStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var payload OIDCCallbackPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+		// state 只能在发起登录的浏览器中使用，cookie 用过即删
+		cookie, err := c.Cookie(oidcStateCookieName)
+		setOIDCStateCookie(c, cfg.Get(), "", -1)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie), []byte(hashOIDCState(payload.State))) != 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Login request expired, please try again"})
+			return
+		}
+
+		identity, err := oidcAuth.Exchange(c.Request.Context(), payload.Code, payload.State)
+		switch {
+		case err == nil:
+		case errors.Is(err, auth.ErrOIDCDisabled):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not enabled"})
+			return
+		case errors.Is(err, auth.ErrOIDCStateInvalid):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Login request expired, please try again"})
+			return
+		case errors.Is(err, auth.ErrInvalidCredentials):
+			log.Printf("OIDC login rejected: %v", err)
+			c.JSON(http.StatusForbidden, gin.H{"error": "This account is not allowed to log in"})
+			return
+		default:
+			log.Printf("OIDC login failed: %v", err)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Single sign-on failed"})
+			return
+		}
+
+		if err := accounts.Check(c.Request.Context(), identity.Username); err != nil {
+			if errors.Is(err, auth.ErrInvalidCredentials) {
+				log.Printf("OIDC login rejected: user %s does not exist in the directory", identity.Username)
+				c.JSON(http.StatusForbidden, gin.H{"error": "This account is not allowed to log in"})
+				return
+			}
+			log.Printf("Failed to verify OIDC user %s: %v", identity.Username, err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify the account"})
+			return
+		}
+
+		completeLogin(c, cfg.Get(), executor, directory, tokenStore, refreshTokens, totpStore, challenges, identity)
+	}
+}
+
+// setOIDCStateCookie 写入或删除（maxAge 为负数时）state cookie。cookie 只发送给回调接口；
+// 回调页是从身份提供者跳转回来的，因此使用 Lax 而不是 Strict，跨站部署时沿用 None
+func setOIDCStateCookie(c *gin.Context, conf *config.Config, value string, maxAge time.Duration) {
+	cookie := &http.Cookie{
+		Name:     oidcStateCookieName,
+		Value:    value,
+		Path:     "/api/oidc/callback",
+		MaxAge:   int(maxAge.Seconds()),
+		Secure:   conf.CookieSecure,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if conf.CookieSameSite == config.SameSiteNone {
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	if maxAge < 0 {
+		cookie.MaxAge = -1
+	}
+	http.SetCookie(c.Writer, cookie)
+}
+
+func hashOIDCState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,182 @@
+package api
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"slurm-dashboard/internal/testutil/fakeoidc"
+)
+
+// enableOIDC 将测试环境接到假身份提供者，并让 scontrol token 总是成功
+func enableOIDC(t *testing.T, env *testEnv) *fakeoidc.Server {
+	t.Helper()
+	idp := fakeoidc.New(t)
+	conf := env.cfg.Get()
+	conf.OIDCIssuerURL = idp.URL
+	conf.OIDCClientID = fakeoidc.ClientID
+	conf.OIDCClientSecret = fakeoidc.ClientSecret
+	conf.OIDCRedirectURL = "https://dashboard.example.com/oidc/callback"
+	conf.OIDCUsernameClaim = "email"
+	conf.OIDCUsernamePattern = `^([^@]+)@example\.edu$`
+	env.cli.Handle("scontrol", func(username string, args []string) (string, error) {
+		return "SLURM_JWT=minted-token\n", nil
+	})
+	return idp
+}
+
+// oidcLogin 是一次单点登录在回调时需要提交的内容
+type oidcLogin struct {
+	code, state string
+	// cookie 为 /api/oidc/login 写入浏览器的 state cookie
+	cookie *http.Cookie
+}
+
+// startOIDCLogin 调用 /api/oidc/login 并模拟用户在身份提供者处登录，返回授权码、state 和 state cookie
+func startOIDCLogin(t *testing.T, env *testEnv, idp *fakeoidc.Server, claims map[string]any) oidcLogin {
+	t.Helper()
+	w := env.do(http.MethodGet, "/api/oidc/login", "", nil)
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		AuthorizationURL string `json:"authorization_url"`
+		State            string `json:"state"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	code, state := idp.Authorize(t, resp.AuthorizationURL, claims)
+	if state != resp.State {
+		t.Fatalf("identity provider returned state %q, expected %q", state, resp.State)
+	}
+	cookie := responseCookies(w)[oidcStateCookieName]
+	if cookie == nil {
+		t.Fatalf("expected a state cookie, got %v", w.Result().Cookies())
+	}
+	return oidcLogin{code: code, state: state, cookie: cookie}
+}
+
+func oidcCallback(env *testEnv, login oidcLogin) *httptest.ResponseRecorder {
+	body := fmt.Sprintf(`{"code":%q,"state":%q}`, login.code, login.state)
+	var cookies []*http.Cookie
+	if login.cookie != nil {
+		cookies = append(cookies, login.cookie)
+	}
+	return env.doWithCookies(http.MethodPost, "/api/oidc/callback", cookies, "", strings.NewReader(body))
+}
+
+func TestOIDCLogin(t *testing.T) {
+	env := newTestEnv(t)
+	idp := enableOIDC(t, env)
+	env.directory.addUser("alice", "", "hpc-operators")
+
+	w := env.do(http.MethodGet, "/api/oidc/config", "", nil)
+	expectStatus(t, w, http.StatusOK)
+	if !strings.Contains(w.Body.String(), `"enabled":true`) {
+		t.Fatalf("expected OIDC to be enabled: %s", w.Body.String())
+	}
+
+	login := startOIDCLogin(t, env, idp, map[string]any{"email": "alice@example.edu"})
+	if !login.cookie.HttpOnly || login.cookie.SameSite != http.SameSiteLaxMode || login.cookie.Path != "/api/oidc/callback" {
+		t.Errorf("state cookie must be HttpOnly, SameSite=Lax and scoped to the callback: %+v", login.cookie)
+	}
+	w = oidcCallback(env, login)
+	expectStatus(t, w, http.StatusOK)
+
+	decodeTokenPair(t, w.Body.Bytes())
+	var resp struct {
+		User struct {
+			Username string `json:"username"`
+			Role     string `json:"role"`
+		} `json:"user"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.User.Username != "alice" || resp.User.Role != "operator" {
+		t.Errorf("expected alice with the operator role, got %s", w.Body.String())
+	}
+	if token, ok := env.tokenStore.Get("alice"); !ok || token != "minted-token" {
+		t.Errorf("expected slurm token to be stored, got %q", token)
+	}
+
+	// state 只能使用一次
+	w = oidcCallback(env, login)
+	expectStatus(t, w, http.StatusBadRequest)
+}
+
+func TestOIDCLoginRejectsUnmappedUsers(t *testing.T) {
+	env := newTestEnv(t)
+	idp := enableOIDC(t, env)
+
+	w := oidcCallback(env, startOIDCLogin(t, env, idp, map[string]any{"email": "mallory@example.org"}))
+	expectStatus(t, w, http.StatusForbidden)
+
+	if _, ok := env.tokenStore.Get("mallory"); ok {
+		t.Error("no slurm token should be stored for a rejected user")
+	}
+}
+
+func TestOIDCLoginRejectsUnknownUsers(t *testing.T) {
+	env := newTestEnv(t)
+	idp := enableOIDC(t, env)
+
+	// 声明映射得到的用户名不在用户目录中
+	w := oidcCallback(env, startOIDCLogin(t, env, idp, map[string]any{"email": "carol@example.edu"}))
+	expectStatus(t, w, http.StatusForbidden)
+
+	if _, ok := env.tokenStore.Get("carol"); ok {
+		t.Error("no slurm token should be stored for an unknown user")
+	}
+}
+
+func TestOIDCLoginRejectsRoot(t *testing.T) {
+	env := newTestEnv(t)
+	idp := enableOIDC(t, env)
+	env.directory.addUser("root", "", "hpc-admins")
+
+	w := oidcCallback(env, startOIDCLogin(t, env, idp, map[string]any{"email": "root@example.edu"}))
+	expectStatus(t, w, http.StatusForbidden)
+
+	if _, ok := env.tokenStore.Get("root"); ok {
+		t.Error("no slurm token should be stored for root")
+	}
+	for _, call := range env.cli.Calls() {
+		if call.Program == "scontrol" {
+			t.Errorf("no Slurm token should be issued for root, got %v", call.Args)
+		}
+	}
+}
+
+func TestOIDCLoginRejectsNonceMismatch(t *testing.T) {
+	env := newTestEnv(t)
+	idp := enableOIDC(t, env)
+
+	// 身份提供者返回的 ID token 属于另一次登录
+	w := oidcCallback(env, startOIDCLogin(t, env, idp, map[string]any{"email": "alice@example.edu", "nonce": "replayed"}))
+	expectStatus(t, w, http.StatusUnauthorized)
+}
+
+func TestOIDCCallbackRequiresStateCookie(t *testing.T) {
+	env := newTestEnv(t)
+	idp := enableOIDC(t, env)
+	env.directory.addUser("mallory", "")
+	env.directory.addUser("alice", "")
+
+	// 攻击者在自己的浏览器中发起登录，再把授权码和 state 交给受害者的浏览器
+	attacker := startOIDCLogin(t, env, idp, map[string]any{"email": "mallory@example.edu"})
+	victim := startOIDCLogin(t, env, idp, map[string]any{"email": "alice@example.edu"})
+
+	expectStatus(t, oidcCallback(env, oidcLogin{code: attacker.code, state: attacker.state}), http.StatusBadRequest)
+	expectStatus(t, oidcCallback(env, oidcLogin{code: attacker.code, state: attacker.state, cookie: victim.cookie}), http.StatusBadRequest)
+	if _, ok := env.tokenStore.Get("mallory"); ok {
+		t.Error("a callback without the matching state cookie must not log in")
+	}
+	expectStatus(t, oidcCallback(env, victim), http.StatusOK)
+}
+
+func TestOIDCDisabled(t *testing.T) {
+	env := newTestEnv(t)
+
+	w := env.do(http.MethodGet, "/api/oidc/login", "", nil)
+	expectStatus(t, w, http.StatusNotFound)
+}
@@ -0,0 +1,134 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/store"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+type RefreshPayload struct {
+	// RefreshToken 为空时使用 refresh token cookie，此时需要带上 CSRF token
+	RefreshToken string `json:"refresh_token"`
+}
+
+// issueTokens 签发一对 access token 和 refresh token。familyID 为空时开始一个新的登录，
+// 其有效期为 refresh_token_duration；否则沿用原家族的到期时间。mfa 表示该登录是否通过了两步验证。
+func issueTokens(conf *config.Config, refreshTokens *store.RefreshTokenStore, username, role, familyID string, familyExpiresAt time.Time, mfa bool) (gin.H, error) {
+	if familyID == "" {
+		familyID = uuid.New().String()
+		familyExpiresAt = time.Now().Add(conf.RefreshTokenDuration)
+	}
+
+	accessToken, claims, err := auth.GenerateCustomToken(conf, username, role, familyID)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := refreshTokens.Issue(store.RefreshToken{
+		Username:        username,
+		FamilyID:        familyID,
+		FamilyExpiresAt: familyExpiresAt,
+		AccessID:        claims.ID,
+		AccessExpiresAt: claims.ExpiresAt.Time,
+		MFA:             mfa,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return gin.H{
+		"token":         accessToken,
+		"refresh_token": refreshToken,
+		"expires_in":    int(conf.JWTDuration.Seconds()),
+		"user":          gin.H{"username": username, "role": role},
+	}, nil
+}
+
+// RefreshHandler 使用一次性的 refresh token 换取新的 access token 和 refresh token。
+// 每次刷新都重新计算角色，用户组的变更最迟在一个 access token 有效期后生效；
+// 已从用户目录中删除的用户的登录被吊销。
+func RefreshHandler(cfg *config.Provider, executor services.Executor, directory auth.Directory, accounts *auth.AccountChecker, refreshTokens *store.RefreshTokenStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		conf := cfg.Get()
+
+		var payload RefreshPayload
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&payload); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+				return
+			}
+		}
+		var csrfToken string
+		if payload.RefreshToken == "" && conf.SessionCookies {
+			if !validCSRF(c) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+				return
+			}
+			payload.RefreshToken, _ = c.Cookie(refreshCookieName)
+			csrfToken = c.GetHeader(csrfHeaderName)
+		}
+		if payload.RefreshToken == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		// 轮换前确认账户仍然存在，查询失败时 token 没有被消费，客户端可以重试。
+		// token 无效时由 Rotate 返回同样的错误
+		if record, err := refreshTokens.Peek(payload.RefreshToken); err == nil {
+			if err := accounts.Check(c.Request.Context(), record.Username); err != nil {
+				if errors.Is(err, auth.ErrInvalidCredentials) {
+					log.Printf("User %s no longer exists, revoking token family %s", record.Username, record.FamilyID)
+					if err := refreshTokens.RevokeFamily(record.FamilyID); err != nil {
+						log.Printf("Failed to revoke refresh tokens of user %s: %v", record.Username, err)
+					}
+					clearSessionCookies(c, conf)
+					c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please login again"})
+					return
+				}
+				log.Printf("Failed to verify user %s before refreshing the session: %v", record.Username, err)
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify the account"})
+				return
+			}
+		}
+
+		record, err := refreshTokens.Rotate(payload.RefreshToken)
+		switch {
+		case errors.Is(err, store.ErrRefreshTokenInvalid), errors.Is(err, store.ErrRefreshTokenReused):
+			clearSessionCookies(c, conf)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Session expired, please login again"})
+			return
+		case errors.Is(err, store.ErrRefreshTokenRaced):
+			// 另一个标签页刚刚完成刷新，保留 cookie，客户端改用已保存的新 token 即可
+			c.JSON(http.StatusConflict, gin.H{"error": "Session was just refreshed by another request, retry with the latest token"})
+			return
+		case err != nil:
+			log.Printf("Failed to rotate refresh token: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+			return
+		}
+
+		role := resolveRole(c.Request.Context(), conf, executor, directory, record.Username)
+		if conf.TOTPRequired(role) && !record.MFA {
+			// 用户在登录后被加入了要求两步验证的角色
+			log.Printf("User %s now has role %s which requires two-factor authentication, ending the session", record.Username, role)
+			clearSessionCookies(c, conf)
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Two-factor authentication is required, please login again"})
+			return
+		}
+		tokens, err := issueTokens(conf, refreshTokens, record.Username, role, record.FamilyID, record.FamilyExpiresAt, record.MFA)
+		if err != nil {
+			log.Printf("Failed to issue tokens for user %s: %v", record.Username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh session"})
+			return
+		}
+		respondWithTokens(c, conf, tokens, csrfToken)
+	}
+}
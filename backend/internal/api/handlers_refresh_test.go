@@ -0,0 +1,114 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+type tokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// loginWithRefresh 通过登录接口获取一对 access token 和 refresh token，调用前需要先在 env.directory 中登记用户
+func (e *testEnv) loginWithRefresh(t *testing.T, username, password string) tokenPair {
+	t.Helper()
+	e.cli.Output("scontrol", "SLURM_JWT=slurm-token-"+username+"\n")
+	e.slurm.SetToken(username, "slurm-token-"+username)
+
+	w := e.do(http.MethodPost, "/api/login", "", strings.NewReader(`{"username":"`+username+`","password":"`+password+`"}`))
+	expectStatus(t, w, http.StatusOK)
+	return decodeTokenPair(t, w.Body.Bytes())
+}
+
+func (e *testEnv) refresh(refreshToken string) (int, []byte) {
+	w := e.do(http.MethodPost, "/api/refresh", "", strings.NewReader(`{"refresh_token":"`+refreshToken+`"}`))
+	return w.Code, w.Body.Bytes()
+}
+
+func decodeTokenPair(t *testing.T, body []byte) tokenPair {
+	t.Helper()
+	var pair tokenPair
+	if err := json.Unmarshal(body, &pair); err != nil {
+		t.Fatalf("invalid response: %v", err)
+	}
+	if pair.Token == "" || pair.RefreshToken == "" || pair.ExpiresIn <= 0 {
+		t.Fatalf("incomplete token pair: %s", body)
+	}
+	return pair
+}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct")
+	first := env.loginWithRefresh(t, "alice", "correct")
+
+	status, body := env.refresh(first.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d: %s", status, body)
+	}
+	second := decodeTokenPair(t, body)
+	if second.RefreshToken == first.RefreshToken || second.Token == first.Token {
+		t.Fatal("refresh should issue a new token pair")
+	}
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/jobs", second.Token, nil), http.StatusOK)
+
+	if status, _ := env.refresh("not-a-refresh-token"); status != http.StatusUnauthorized {
+		t.Errorf("expected unknown refresh token to be rejected, got %d", status)
+	}
+}
+
+func TestRefreshTokenConcurrentReuse(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct")
+	first := env.loginWithRefresh(t, "alice", "correct")
+
+	status, body := env.refresh(first.RefreshToken)
+	if status != http.StatusOK {
+		t.Fatalf("expected refresh to succeed, got %d: %s", status, body)
+	}
+	second := decodeTokenPair(t, body)
+
+	// 多个标签页同时刷新时，晚到的请求在宽限期内收到 409，登录不被吊销。
+	// 宽限期之后的重放会吊销整个家族，见 store 包中的测试
+	if status, _ := env.refresh(first.RefreshToken); status != http.StatusConflict {
+		t.Fatalf("expected a concurrent refresh to be reported as a conflict, got %d", status)
+	}
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/jobs", second.Token, nil), http.StatusOK)
+	if status, body := env.refresh(second.RefreshToken); status != http.StatusOK {
+		t.Errorf("expected the rest of the family to stay valid, got %d: %s", status, body)
+	}
+}
+
+func TestLogoutRevokesRefreshToken(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct")
+	pair := env.loginWithRefresh(t, "alice", "correct")
+
+	expectStatus(t, env.do(http.MethodPost, "/api/logout", pair.Token, nil), http.StatusOK)
+	if status, _ := env.refresh(pair.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("expected refresh token to be revoked on logout, got %d", status)
+	}
+}
+
+func TestRefreshRevokedAfterUserRemoved(t *testing.T) {
+	env := newTestEnv(t)
+	env.directory.addUser("alice", "correct")
+	pair := env.loginWithRefresh(t, "alice", "correct")
+
+	// 账户从目录中删除后，登录不能继续续期，已签发的 access token 一并吊销
+	env.directory.removeUser("alice")
+	if status, body := env.refresh(pair.RefreshToken); status != http.StatusUnauthorized {
+		t.Fatalf("expected refresh of a removed user to be rejected, got %d: %s", status, body)
+	}
+	expectStatus(t, env.do(http.MethodGet, "/api/v1/jobs", pair.Token, nil), http.StatusUnauthorized)
+
+	// 用户恢复后旧的家族也不能再使用
+	env.directory.addUser("alice", "correct")
+	if status, _ := env.refresh(pair.RefreshToken); status != http.StatusUnauthorized {
+		t.Errorf("expected the revoked family to stay revoked, got %d", status)
+	}
+}
@@ -1,23 +1,15 @@
 package api
 
 import (
-	"fmt"
 	"log"
 	"net/http"
-	"os"
-	"os/exec"
-	"os/user"
 	"strconv"
-	"syscall"
 	"time"
 
-	"slurm-dashboard/config"
-	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/services"
 	"slurm-dashboard/internal/store"
 
-	"github.com/creack/pty"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 )
@@ -31,7 +23,7 @@ type SallocPayload struct {
 }
 
 // HandleCreateSallocSession 创建一个新的 salloc 会话 (POST)
-func HandleCreateSallocSession(cfg *config.Config, sessionStore *store.SessionStore) gin.HandlerFunc {
+func HandleCreateSallocSession(executor services.Executor, sessionStore *store.SessionStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		username, _ := c.Get("username")
 
@@ -41,15 +33,6 @@ func HandleCreateSallocSession(cfg *config.Config, sessionStore *store.SessionSt
 			return
 		}
 
-		// 获取用户信息并设置命令执行身份
-		osUser, err := user.Lookup(username.(string))
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lookup user"})
-			return
-		}
-		uid, _ := strconv.Atoi(osUser.Uid)
-		gid, _ := strconv.Atoi(osUser.Gid)
-
 		// 构建 salloc 命令
 		args := []string{}
 		args = append(args, "--ntasks-per-node", "1")
@@ -66,22 +49,8 @@ func HandleCreateSallocSession(cfg *config.Config, sessionStore *store.SessionSt
 			args = append(args, "--cpus-per-task", strconv.Itoa(payload.CPUCount))
 		}
 
-		cmd := exec.Command("salloc", args...)
-		cmd.Dir = osUser.HomeDir
-		cmd.SysProcAttr = &syscall.SysProcAttr{
-			Credential: &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)},
-		}
-		// 注入正确的环境变量
-		cmd.Env = []string{
-			"TERM=xterm",
-			fmt.Sprintf("HOME=%s", osUser.HomeDir),
-			fmt.Sprintf("USER=%s", username.(string)),
-			fmt.Sprintf("LOGNAME=%s", username.(string)),
-			fmt.Sprintf("PATH=%s", os.Getenv("PATH")),
-		}
-
-		// 同步方式启动带 PTY 的命令
-		ptmx, err := pty.Start(cmd)
+		// 以用户身份同步启动带 PTY 的命令
+		cmd, ptmx, err := executor.StartPTY(services.PTYRequest{Username: username.(string), Program: "salloc", Args: args})
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start salloc process", "details": err.Error()})
 			return
@@ -89,10 +58,11 @@ func HandleCreateSallocSession(cfg *config.Config, sessionStore *store.SessionSt
 
 		sessionID := uuid.New().String()
 		session := &store.InteractiveSession{
-			ID:       sessionID,
-			Username: username.(string),
-			Cmd:      cmd,
-			Pty:      ptmx,
+			ID:        sessionID,
+			Username:  username.(string),
+			StartedAt: time.Now(),
+			Cmd:       cmd,
+			Pty:       ptmx,
 		}
 		sessionStore.Add(session)
 		log.Printf("Started salloc process for user %s", username)
@@ -100,6 +70,7 @@ func HandleCreateSallocSession(cfg *config.Config, sessionStore *store.SessionSt
 		go func() {
 			cmd.Wait()
 			ptmx.Close()
+			sessionStore.MarkEnded(sessionID)
 			log.Printf("Salloc session %s for user %s has terminated.", sessionID, username)
 			time.AfterFunc(1*time.Minute, func() {
 				sessionStore.Remove(sessionID)
@@ -112,25 +83,25 @@ func HandleCreateSallocSession(cfg *config.Config, sessionStore *store.SessionSt
 }
 
 // HandleAttachSallocSession 连接到一个已存在的 salloc 会话 (WebSocket GET)
-func HandleAttachSallocSession(cfg *config.Config, sessionStore *store.SessionStore) gin.HandlerFunc {
+func HandleAttachSallocSession(sessionStore *store.SessionStore, tickets *store.TicketStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		sessionID := c.Param("session_id")
 
-		// 手动认证
-		tokenString := c.Query("token")
-		claims := &auth.CustomClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecretKey), nil
-		})
-		if err != nil || !token.Valid {
+		// 使用为该会话签发的一次性票据认证
+		username, err := redeemTicket(c, tickets, sessionID)
+		if err != nil {
 			c.AbortWithStatus(http.StatusUnauthorized)
 			return
 		}
-		username := claims.Username
 
 		// 查找会话
 		session, ok := sessionStore.Get(sessionID)
 		if !ok {
+			// 会话已经结束，或者随后端重启一起结束了
+			if info, found := sessionStore.Info(sessionID); found && info.Username == username {
+				c.AbortWithStatusJSON(http.StatusGone, gin.H{"error": "Interactive session has ended"})
+				return
+			}
 			c.AbortWithStatus(http.StatusNotFound)
 			return
 		}
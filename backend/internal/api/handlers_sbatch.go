@@ -1,25 +1,21 @@
 package api
 
 import (
-	"fmt"
 	"log"
 	"net/http"
-	"os"
 	"regexp"
-	"slurm-dashboard/config"
 	"slurm-dashboard/internal/services"
-	"slurm-dashboard/internal/store"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
 type SbatchPayload struct {
 	Script string `json:"script"`
 }
 
-// SbatchSubmitHandler 接收脚本内容，保存为临时文件，并使用sbatch提交
-func SbatchSubmitHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.HandlerFunc {
+// SbatchSubmitHandler 接收脚本内容，并以用户身份使用sbatch提交
+func SbatchSubmitHandler(executor services.Executor) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		username, exists := c.Get("username")
 		if !exists {
@@ -33,30 +29,18 @@ func SbatchSubmitHandler(cfg *config.Config, tokenStore *store.TokenStore) gin.H
 			return
 		}
 
-		// 使用 uuid 生成随机文件名，放在 /tmp 目录下
-		scriptName := uuid.New().String()
-		filePath := fmt.Sprintf("/tmp/%s.sh", scriptName)
-
-		// 将脚本写入文件
-		err := os.WriteFile(filePath, []byte(payload.Script), 0755)
-		if err != nil {
-			log.Printf("Failed to write temporary script file for user %s: %v", username, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create script file on server"})
-			return
-		}
-
-		defer os.Remove(filePath)
-		log.Printf("Created temporary script %s for user %s", filePath, username)
-
-		command := fmt.Sprintf("sbatch %s", filePath)
-
-		// 以用户身份执行 sbatch 命令
-		output, err := services.ExecuteCommandAsUser(username.(string), command)
+		// 脚本通过标准输入交给 sbatch，不再落地为临时文件，也不经过 shell
+		result, err := executor.Run(c.Request.Context(), services.Request{
+			Username: username.(string),
+			Program:  "sbatch",
+			Stdin:    strings.NewReader(payload.Script),
+		})
 		if err != nil {
-			log.Printf("Failed to execute sbatch command for user %s: %v", username, err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to execute sbatch command"})
+			log.Printf("Failed to execute sbatch command for user %s: %v, output: %s", username, err, result.Output())
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to execute sbatch command", "output": result.Output()})
 			return
 		}
+		output := result.Stdout
 
 		re := regexp.MustCompile(`Submitted batch job (\d+)`)
 		matches := re.FindStringSubmatch(output)
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSbatchSubmitHandler(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+	env.cli.Output("sbatch", "Submitted batch job 4242\n")
+
+	script := "#!/bin/bash\n#SBATCH -J test\necho \"$(hostname)\"; rm -rf ~/tmp\n"
+	body, _ := json.Marshal(SbatchPayload{Script: script})
+	w := env.do(http.MethodPost, "/api/v1/sbatch", token, bytes.NewReader(body))
+	expectStatus(t, w, http.StatusOK)
+
+	if !strings.Contains(w.Body.String(), `"job_id":"4242"`) {
+		t.Errorf("unexpected response %s", w.Body.String())
+	}
+
+	calls := env.cli.Calls()
+	if len(calls) != 1 {
+		t.Fatalf("expected exactly one command, got %+v", calls)
+	}
+	if call := calls[0]; call.Username != "alice" || len(call.Args) != 0 || call.Stdin != script {
+		t.Errorf("script should be passed to sbatch on stdin as alice, got %+v", call)
+	}
+}
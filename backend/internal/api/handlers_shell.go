@@ -5,15 +5,12 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"os/exec"
 	"os/user"
 
-	"slurm-dashboard/config"
-	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/store"
 
-	"github.com/creack/pty"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"github.com/gorilla/websocket"
 )
 
@@ -25,22 +22,14 @@ var upgrader = websocket.Upgrader{
 }
 
 // HandleShell 负责处理WebSocket Shell请求
-func ShellHandler(cfg *config.Config) gin.HandlerFunc {
+func ShellHandler(executor services.Executor, tickets *store.TicketStore) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString := c.Query("token")
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token is required"})
-			return
-		}
-		claims := &auth.CustomClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecretKey), nil
-		})
-		if err != nil || !token.Valid {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		// 浏览器的 WebSocket 无法携带 Authorization 头，使用一次性票据认证
+		username, err := redeemTicket(c, tickets, store.TicketTargetShell)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
 			return
 		}
-		username := claims.Username
 		log.Printf("Shell access requested for user: %s", username)
 
 		ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
@@ -59,18 +48,18 @@ func ShellHandler(cfg *config.Config) gin.HandlerFunc {
 		}
 
 		// 使用 `su` 来为指定用户启动一个完整的登录Shell
-		cmd := exec.Command("su", "-", username)
-
-		// 注入正确的环境变量
-		cmd.Env = []string{
-			"TERM=xterm",
-			fmt.Sprintf("HOME=%s", osUser.HomeDir),
-			fmt.Sprintf("USER=%s", username),
-			fmt.Sprintf("LOGNAME=%s", username),
-			fmt.Sprintf("PATH=%s", os.Getenv("PATH")),
-		}
-
-		ptmx, err := pty.Start(cmd)
+		cmd, ptmx, err := executor.StartPTY(services.PTYRequest{
+			Program: "su",
+			Args:    []string{"-", username},
+			// 注入正确的环境变量
+			Env: []string{
+				"TERM=xterm",
+				fmt.Sprintf("HOME=%s", osUser.HomeDir),
+				fmt.Sprintf("USER=%s", username),
+				fmt.Sprintf("LOGNAME=%s", username),
+				fmt.Sprintf("PATH=%s", os.Getenv("PATH")),
+			},
+		})
 		if err != nil {
 			log.Printf("Failed to start pty with su for user %s: %v", username, err)
 			ws.WriteMessage(websocket.TextMessage, []byte("Error: Failed to start shell process."))
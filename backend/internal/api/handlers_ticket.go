@@ -0,0 +1,70 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type WebSocketTicketPayload struct {
+	// Target 为 "shell" 或 "salloc"，后者需要同时提供 SessionID
+	Target    string `json:"target" binding:"required,oneof=shell salloc"`
+	SessionID string `json:"session_id"`
+}
+
+// CreateWebSocketTicketHandler 为紧接着的一次 WebSocket 连接签发一次性票据，
+// 避免把 dashboard JWT 放进 URL 而出现在访问日志和浏览器历史中
+func CreateWebSocketTicketHandler(sessionStore *store.SessionStore, tickets *store.TicketStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims := c.MustGet("claims").(*auth.CustomClaims)
+		username := claims.Username
+
+		var payload WebSocketTicketPayload
+		if err := c.ShouldBindJSON(&payload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request payload"})
+			return
+		}
+
+		target := store.TicketTargetShell
+		if payload.Target == "salloc" {
+			// 只能为自己的会话签发票据，已结束的会话在连接时返回 410
+			info, ok := sessionStore.Info(payload.SessionID)
+			if payload.SessionID == "" || !ok || info.Username != username {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Interactive session not found"})
+				return
+			}
+			target = payload.SessionID
+		}
+
+		ticket, err := tickets.Issue(store.Ticket{
+			Username: username,
+			Target:   target,
+			ClientIP: c.ClientIP(),
+			AccessID: claims.ID,
+		})
+		if err != nil {
+			log.Printf("Failed to issue websocket ticket for user %s: %v", username, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to issue ticket"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"ticket": ticket, "expires_in": int(store.TicketTTL.Seconds())})
+	}
+}
+
+// redeemTicket 校验 WebSocket 请求中的 ?ticket= 并返回票据对应的用户名
+func redeemTicket(c *gin.Context, tickets *store.TicketStore, target string) (string, error) {
+	ticket := c.Query("ticket")
+	if ticket == "" {
+		return "", errors.New("ticket is required")
+	}
+	record, err := tickets.Redeem(ticket, target, c.ClientIP())
+	if err != nil {
+		return "", err
+	}
+	return record.Username, nil
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"slurm-dashboard/internal/store"
+)
+
+// issueTicket 通过接口为 token 对应的用户签发 WebSocket 票据
+func (e *testEnv) issueTicket(t *testing.T, token, payload string) string {
+	t.Helper()
+	w := e.do(http.MethodPost, "/api/v1/ws/ticket", token, strings.NewReader(payload))
+	expectStatus(t, w, http.StatusOK)
+	var resp struct {
+		Ticket string `json:"ticket"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Ticket == "" {
+		t.Fatalf("invalid ticket response: %s", w.Body.String())
+	}
+	return resp.Ticket
+}
+
+// connect 模拟一次 WebSocket 握手前的认证。票据有效时请求会通过认证，
+// 随后因为不是真正的 WebSocket 握手而得到 400。
+func (e *testEnv) connect(path, remoteAddr string) int {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if remoteAddr != "" {
+		req.RemoteAddr = remoteAddr
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestShellTicketIsSingleUse(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+	ticket := env.issueTicket(t, token, `{"target":"shell"}`)
+
+	if status := env.connect("/api/v1/shell?ticket="+ticket, ""); status != http.StatusBadRequest {
+		t.Fatalf("expected a valid ticket to pass authentication, got %d", status)
+	}
+	if status := env.connect("/api/v1/shell?ticket="+ticket, ""); status != http.StatusUnauthorized {
+		t.Errorf("expected a used ticket to be rejected, got %d", status)
+	}
+	// dashboard JWT 不能再直接用于 WebSocket
+	if status := env.connect("/api/v1/shell?token="+token, ""); status != http.StatusUnauthorized {
+		t.Errorf("expected a JWT in the query string to be rejected, got %d", status)
+	}
+}
+
+func TestTicketBoundToClientAndTarget(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+
+	ticket := env.issueTicket(t, token, `{"target":"shell"}`)
+	if status := env.connect("/api/v1/shell?ticket="+ticket, "198.51.100.7:4321"); status != http.StatusUnauthorized {
+		t.Errorf("expected a ticket used from another address to be rejected, got %d", status)
+	}
+
+	env.sessionStore.Add(&store.InteractiveSession{ID: "s1", Username: "alice", StartedAt: time.Now()})
+	ticket = env.issueTicket(t, token, `{"target":"salloc","session_id":"s1"}`)
+	if status := env.connect("/api/v1/shell?ticket="+ticket, ""); status != http.StatusUnauthorized {
+		t.Errorf("expected a session ticket to be rejected by the shell, got %d", status)
+	}
+}
+
+func TestTicketForOtherUsersSession(t *testing.T) {
+	env := newTestEnv(t)
+	env.sessionStore.Add(&store.InteractiveSession{ID: "s1", Username: "bob", StartedAt: time.Now()})
+	token := env.login(t, "alice")
+
+	w := env.do(http.MethodPost, "/api/v1/ws/ticket", token, strings.NewReader(`{"target":"salloc","session_id":"s1"}`))
+	expectStatus(t, w, http.StatusNotFound)
+}
+
+func TestTicketRejectedAfterLogout(t *testing.T) {
+	env := newTestEnv(t)
+	token := env.login(t, "alice")
+	ticket := env.issueTicket(t, token, `{"target":"shell"}`)
+
+	expectStatus(t, env.do(http.MethodPost, "/api/logout", token, nil), http.StatusOK)
+	if status := env.connect("/api/v1/shell?ticket="+ticket, ""); status != http.StatusUnauthorized {
+		t.Errorf("expected tickets of a revoked token to be rejected, got %d", status)
+	}
+}
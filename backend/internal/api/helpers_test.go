@@ -0,0 +1,220 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/secret"
+	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/slurm"
+	"slurm-dashboard/internal/store"
+	"slurm-dashboard/internal/testutil/fakecli"
+	"slurm-dashboard/internal/testutil/fakeslurm"
+
+	"github.com/gin-gonic/gin"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// testEnv 将假 slurmrestd、假 Slurm 命令和完整路由组装在一起
+type testEnv struct {
+	cfg           *config.Provider
+	slurm         *fakeslurm.Server
+	cli           *fakecli.CLI
+	directory     *fakeDirectory
+	tokenStore    *store.TokenStore
+	sessionStore  *store.SessionStore
+	revocations   *store.RevocationStore
+	refreshTokens *store.RefreshTokenStore
+	tickets       *store.TicketStore
+	apiKeys       *store.APIKeyStore
+	limiter       *auth.LoginLimiter
+	audit         *store.AuditLog
+	totp          *store.TOTPStore
+	router        *gin.Engine
+}
+
+// newTestEnv 创建测试环境，configure 可以在创建路由前修改配置
+func newTestEnv(t *testing.T, configure ...func(*config.Config)) *testEnv {
+	t.Helper()
+
+	srv := fakeslurm.New(t)
+	cfg := config.Default()
+	cfg.LDAPServerHost = "ldap.invalid"
+	cfg.LDAPAdminDN = "cn=admin,dc=example,dc=com"
+	cfg.LDAPAdminPassword = "secret"
+	cfg.LDAPSearchBaseDN = "dc=example,dc=com"
+	cfg.SlurmAPIHost = srv.URL
+	cfg.JWTSecretKey = "test-secret-key-that-is-long-enough"
+	cfg.TokenEncryptionSecrets = []string{"test-token-encryption-secret-long-enough"}
+	cfg.RoleGroups = map[string][]string{"admin": {"hpc-admins"}, "operator": {"hpc-operators"}}
+	for _, fn := range configure {
+		fn(cfg)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("test config is invalid: %v", err)
+	}
+	provider := config.NewStaticProvider(cfg)
+
+	db := store.NewMemoryStore()
+	if err := store.Migrate(db); err != nil {
+		t.Fatalf("failed to migrate store: %v", err)
+	}
+	keyring, err := secret.NewKeyring(cfg.TokenEncryptionKeys(), "slurm-token")
+	if err != nil {
+		t.Fatalf("failed to create keyring: %v", err)
+	}
+	env := &testEnv{
+		cfg:          provider,
+		slurm:        srv,
+		cli:          fakecli.New(),
+		directory:    newFakeDirectory(),
+		tokenStore:   store.NewTokenStore(db, keyring),
+		sessionStore: store.NewSessionStore(db),
+		revocations:  store.NewRevocationStore(db),
+	}
+	env.refreshTokens = store.NewRefreshTokenStore(db, env.revocations)
+	env.tickets = store.NewTicketStore(env.revocations)
+	env.apiKeys = store.NewAPIKeyStore(db)
+	env.limiter = auth.NewLoginLimiter(provider)
+	env.audit = store.NewAuditLog(db, cfg.AuditRetention)
+	// 与 main 相同，没有 token_encryption_secrets 时不能绑定 TOTP
+	var totpKeyring *secret.Keyring
+	if len(cfg.TokenEncryptionSecrets) > 0 {
+		if totpKeyring, err = secret.NewKeyring(cfg.TokenEncryptionSecrets, "totp-secret"); err != nil {
+			t.Fatalf("failed to create keyring: %v", err)
+		}
+	}
+	env.totp = store.NewTOTPStore(db, totpKeyring)
+	renewer := services.NewTokenRenewer(provider, env.cli, env.tokenStore)
+	env.router = NewRouter(Dependencies{
+		Config:        provider,
+		Slurm:         slurm.NewRenewingSource(slurm.NewClient(provider), renewer.Renew),
+		Executor:      env.cli,
+		Authenticator: env.directory,
+		Directory:     env.directory,
+		OIDC:          auth.NewOIDCAuthenticator(provider),
+		TokenStore:    env.tokenStore,
+		SessionStore:  env.sessionStore,
+		Revocations:   env.revocations,
+		RefreshTokens: env.refreshTokens,
+		Tickets:       env.tickets,
+		APIKeys:       env.apiKeys,
+		Renewer:       renewer,
+		Accounts:      auth.NewAccountChecker(provider, env.cli, env.directory),
+		Limiter:       env.limiter,
+		Audit:         env.audit,
+		TOTP:          env.totp,
+		Challenges:    store.NewMFAChallengeStore(),
+	})
+	return env
+}
+
+// fakeDirectory 是内存中的用户目录，测试可以随时修改用户所属的组或删除用户
+type fakeDirectory struct {
+	mu        sync.Mutex
+	passwords map[string]string
+	// groups 中没有的用户不在目录中，查询组时返回 ErrInvalidCredentials
+	groups map[string][]string
+}
+
+func newFakeDirectory() *fakeDirectory {
+	return &fakeDirectory{passwords: make(map[string]string), groups: make(map[string][]string)}
+}
+
+func (d *fakeDirectory) removeUser(username string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.passwords, username)
+	delete(d.groups, username)
+}
+
+func (d *fakeDirectory) addUser(username, password string, groups ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.passwords[username] = password
+	d.groups[username] = groups
+}
+
+func (d *fakeDirectory) ensureUser(username string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.groups[username]; !ok {
+		d.groups[username] = nil
+	}
+}
+
+func (d *fakeDirectory) setGroups(username string, groups ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.groups[username] = groups
+}
+
+func (d *fakeDirectory) Authenticate(username, password string) (*auth.Identity, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if expected, ok := d.passwords[username]; !ok || expected != password {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return &auth.Identity{Username: username, Backend: config.AuthBackendLDAP, Groups: d.groups[username]}, nil
+}
+
+func (d *fakeDirectory) Groups(username string) ([]string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	groups, ok := d.groups[username]
+	if !ok {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return groups, nil
+}
+
+// login 模拟一个已登录的普通用户：登记 Slurm token 并返回对应的 dashboard JWT
+func (e *testEnv) login(t *testing.T, username string) string {
+	t.Helper()
+	return e.loginAs(t, username, auth.RoleUser)
+}
+
+// loginAs 与 login 相同，但签发的 token 带有指定的角色。目录中还没有该用户时以空的组加入
+func (e *testEnv) loginAs(t *testing.T, username, role string) string {
+	t.Helper()
+	e.directory.ensureUser(username)
+	slurmToken := "slurm-token-" + username
+	e.slurm.SetToken(username, slurmToken)
+	if err := e.tokenStore.Set(username, slurmToken, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("failed to store slurm token: %v", err)
+	}
+	token, _, err := auth.GenerateCustomToken(e.cfg.Get(), username, role, "")
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	return token
+}
+
+func (e *testEnv) do(method, path, token string, body io.Reader) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, body)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	w := httptest.NewRecorder()
+	e.router.ServeHTTP(w, req)
+	return w
+}
+
+func expectStatus(t *testing.T, w *httptest.ResponseRecorder, status int) {
+	t.Helper()
+	if w.Code != status {
+		t.Fatalf("expected status %d (%s), got %d: %s", status, http.StatusText(status), w.Code, w.Body.String())
+	}
+}
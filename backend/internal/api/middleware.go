@@ -1,19 +1,45 @@
 package api
 
 import (
+	"errors"
+	"log"
 	"net/http"
 	"slurm-dashboard/config"
 	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/store"
 	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 )
 
-func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
+// errTokenRevoked 表示 token 已通过登出被吊销
+var errTokenRevoked = errors.New("token has been revoked")
+
+// authenticateToken 校验 dashboard JWT 并检查其是否已被吊销，供中间件和 WebSocket 处理器共用
+func authenticateToken(cfg *config.Provider, revocations *store.RevocationStore, tokenString string) (*auth.CustomClaims, error) {
+	claims, err := auth.ParseToken(cfg.Get(), tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if revocations.IsRevoked(claims.ID) {
+		return nil, errTokenRevoked
+	}
+	return claims, nil
+}
+
+// AuthMiddleware 接受登录取得的 dashboard JWT 和用户创建的 API key（以 store.APIKeyPrefix 开头），
+// 两者都放在 Authorization: Bearer 中。使用 API key 的请求以 user 角色执行，并在 context 中设置 "api_key"；
+// key 的所有者必须仍然存在于用户目录中，用户的 Slurm token 已被清理时为其重新签发。
+// 开启 session_cookies 时，没有 Authorization 头的请求使用 access token cookie，修改类请求还需要通过 CSRF 校验。
+func AuthMiddleware(cfg *config.Provider, revocations *store.RevocationStore, apiKeys *store.APIKeyStore, accounts *auth.AccountChecker, renewer *services.TokenRenewer) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
+			if cookie, err := c.Cookie(accessCookieName); err == nil && cookie != "" && cfg.Get().SessionCookies {
+				authenticateCookie(c, cfg, revocations, cookie)
+				return
+			}
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Authorization header is required"})
 			return
 		}
@@ -24,17 +50,101 @@ func AuthMiddleware(cfg *config.Config) gin.HandlerFunc {
 			return
 		}
 
-		claims := &auth.CustomClaims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return []byte(cfg.JWTSecretKey), nil
-		})
+		if strings.HasPrefix(tokenString, store.APIKeyPrefix) {
+			key, err := apiKeys.Authenticate(tokenString)
+			if err != nil {
+				if !errors.Is(err, store.ErrAPIKeyInvalid) {
+					log.Printf("Failed to look up API key: %v", err)
+				}
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+				return
+			}
+			if err := accounts.Check(c.Request.Context(), key.Username); err != nil {
+				if errors.Is(err, auth.ErrInvalidCredentials) {
+					log.Printf("Rejected API key %s: user %s no longer exists", key.ID, key.Username)
+					c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired API key"})
+					return
+				}
+				log.Printf("Failed to verify the owner %s of API key %s: %v", key.Username, key.ID, err)
+				c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "Unable to verify the API key owner"})
+				return
+			}
+			if _, err := renewer.Ensure(c.Request.Context(), key.Username); err != nil {
+				log.Printf("Slurm token generation error for API key %s of user %s: %v", key.ID, key.Username, err)
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate Slurm token"})
+				return
+			}
+			c.Set("username", key.Username)
+			c.Set("role", auth.RoleUser)
+			c.Set("api_key", key)
+			c.Next()
+			return
+		}
 
-		if err != nil || !token.Valid {
+		claims, err := authenticateToken(cfg, revocations, tokenString)
+		if err != nil {
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
 			return
 		}
 
 		c.Set("username", claims.Username)
+		c.Set("role", claims.Role)
+		c.Set("claims", claims)
 		c.Next()
 	}
 }
+
+// authenticateCookie 校验 access token cookie。浏览器会为其他站点发起的请求自动带上 cookie，
+// 因此修改类请求必须带有与 CSRF cookie 一致的 X-CSRF-Token 头
+func authenticateCookie(c *gin.Context, cfg *config.Provider, revocations *store.RevocationStore, tokenString string) {
+	claims, err := authenticateToken(cfg, revocations, tokenString)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired token"})
+		return
+	}
+	if !isSafeMethod(c.Request.Method) && !validCSRF(c) {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Missing or invalid CSRF token"})
+		return
+	}
+
+	c.Set("username", claims.Username)
+	c.Set("role", claims.Role)
+	c.Set("claims", claims)
+	c.Next()
+}
+
+// RequireScope 要求使用 API key 的请求具有 scope，登录取得的 token 不受限制。必须放在 AuthMiddleware 之后
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if value, ok := c.Get("api_key"); ok && !value.(store.APIKey).HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "API key does not have the " + scope + " scope"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireSession 只允许通过登录取得的 token 访问，用于登出、管理 API key 等不应交给脚本的操作
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, ok := c.Get("claims"); !ok {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "This endpoint requires an interactive login"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireRole 只允许 token 中的角色属于 roles 的用户访问，必须放在 AuthMiddleware 之后
+func RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		role := c.GetString("role")
+		for _, allowed := range roles {
+			if role == allowed {
+				c.Next()
+				return
+			}
+		}
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Insufficient permissions"})
+	}
+}
@@ -1,9 +1,13 @@
 package api
 
 import (
+	"log"
 	"net/http"
 	"path"
 	"slurm-dashboard/config"
+	"slurm-dashboard/internal/auth"
+	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/slurm"
 	"slurm-dashboard/internal/store"
 	"strings"
 
@@ -11,14 +15,50 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
-func NewRouter(cfg *config.Config, tokenStore *store.TokenStore, sessionStore *store.SessionStore) *gin.Engine {
+// Dependencies 汇总了路由中各处理器依赖的服务和存储
+type Dependencies struct {
+	Config        *config.Provider
+	Slurm         slurm.Source
+	Executor      services.Executor
+	Authenticator auth.Authenticator
+	Directory     auth.Directory
+	OIDC          *auth.OIDCAuthenticator
+	TokenStore    *store.TokenStore
+	SessionStore  *store.SessionStore
+	Revocations   *store.RevocationStore
+	RefreshTokens *store.RefreshTokenStore
+	Tickets       *store.TicketStore
+	APIKeys       *store.APIKeyStore
+	Renewer       *services.TokenRenewer
+	Accounts      *auth.AccountChecker
+	Limiter       *auth.LoginLimiter
+	Audit         *store.AuditLog
+	TOTP          *store.TOTPStore
+	Challenges    *store.MFAChallengeStore
+}
+
+func NewRouter(deps Dependencies) *gin.Engine {
+	cfg, slurmSource, executor, directory := deps.Config, deps.Slurm, deps.Executor, deps.Directory
+	tokenStore, sessionStore, revocations := deps.TokenStore, deps.SessionStore, deps.Revocations
+	refreshTokens, tickets, apiKeys := deps.RefreshTokens, deps.Tickets, deps.APIKeys
+	totpStore, challenges, limiter, audit := deps.TOTP, deps.Challenges, deps.Limiter, deps.Audit
+	authMiddleware := AuthMiddleware(cfg, revocations, apiKeys, deps.Accounts, deps.Renewer)
+
 	router := gin.Default()
+	// 只信任 trusted_proxies 转发的客户端 IP，否则任何人都可以伪造 X-Forwarded-For 绕过按 IP 的登录限制
+	if err := router.SetTrustedProxies(cfg.Get().TrustedProxies); err != nil {
+		log.Printf("Invalid trusted_proxies, not trusting any proxy: %v", err)
+		router.SetTrustedProxies(nil)
+	}
 
-	// CORS 配置
-	corsConfig := cors.DefaultConfig()
-	corsConfig.AllowAllOrigins = true
-	corsConfig.AllowHeaders = append(corsConfig.AllowHeaders, "Authorization")
-	router.Use(cors.New(corsConfig))
+	// 只允许 cors_allowed_origins 中的前端跨域访问，未配置时浏览器只能从同源页面调用 API
+	if origins := cfg.Get().CORSAllowedOrigins; len(origins) > 0 {
+		corsConfig := cors.DefaultConfig()
+		corsConfig.AllowOrigins = origins
+		corsConfig.AllowCredentials = true
+		corsConfig.AllowHeaders = append(corsConfig.AllowHeaders, "Authorization", csrfHeaderName)
+		router.Use(cors.New(corsConfig))
+	}
 
 	// 静态文件服务
 	router.StaticFS("/assets", http.Dir("./static/assets"))
@@ -31,32 +71,74 @@ func NewRouter(cfg *config.Config, tokenStore *store.TokenStore, sessionStore *s
 	})
 
 	// 公开的路由
-	router.POST("/api/login", LoginHandler(cfg, tokenStore))
+	router.POST("/api/login", LoginHandler(cfg, executor, deps.Authenticator, directory, tokenStore, refreshTokens, totpStore, challenges, limiter, audit))
+	router.POST("/api/login/mfa", MFAVerifyHandler(cfg, executor, tokenStore, refreshTokens, totpStore, challenges, limiter, audit))
+	router.POST("/api/login/mfa/enroll", MFAEnrollHandler(cfg, totpStore, challenges))
+	router.GET("/api/oidc/config", OIDCConfigHandler(cfg))
+	router.GET("/api/oidc/login", OIDCLoginHandler(cfg, deps.OIDC))
+	router.POST("/api/oidc/callback", OIDCCallbackHandler(cfg, executor, deps.OIDC, deps.Accounts, directory, tokenStore, refreshTokens, totpStore, challenges))
+	router.POST("/api/refresh", RefreshHandler(cfg, executor, directory, deps.Accounts, refreshTokens))
+	router.POST("/api/logout", authMiddleware, RequireSession(), LogoutHandler(cfg, tokenStore, sessionStore, revocations, refreshTokens))
 
-	// 独立认证的路由
-	router.GET("/api/v1/shell", ShellHandler(cfg))
-	router.GET("/api/v1/salloc/interactive/:session_id/attach", HandleAttachSallocSession(cfg, sessionStore))
+	// 使用一次性票据认证的 WebSocket 路由
+	router.GET("/api/v1/shell", ShellHandler(executor, tickets))
+	router.GET("/api/v1/salloc/interactive/:session_id/attach", HandleAttachSallocSession(sessionStore, tickets))
 
-	// 受保护的API v1路由组
+	// 受保护的API v1路由组，API key 只能访问其范围内的路由
+	read, submit, cancel := RequireScope(auth.ScopeRead), RequireScope(auth.ScopeSubmit), RequireScope(auth.ScopeCancel)
 	apiV1 := router.Group("/api/v1")
-	apiV1.Use(AuthMiddleware(cfg))
+	apiV1.Use(authMiddleware)
 	{
-		apiV1.GET("/cluster/status", GetClusterStatusHandler(cfg, tokenStore))
-		apiV1.GET("/cluster/status_limit", GetClusterStatusByUserHandler(cfg, tokenStore))
-		apiV1.GET("/cluster/partitions", GetPartitionsHandler(cfg, tokenStore))
-		apiV1.GET("/jobs", GetJobsHandler(cfg, tokenStore))
-		apiV1.GET("/jobs/info", HandleGetAllJobInfoLogs(cfg, tokenStore))
+		apiV1.GET("/cluster/status_limit", read, GetClusterStatusByUserHandler(slurmSource, executor, tokenStore))
+		apiV1.GET("/cluster/partitions", read, GetPartitionsHandler(executor))
+		apiV1.GET("/jobs", read, GetJobsHandler(slurmSource, tokenStore))
+		apiV1.GET("/jobs/info", read, HandleGetAllJobInfoLogs(cfg, tokenStore))
 		jobGroup := apiV1.Group("/job")
 		{
-			jobGroup.POST("/submit", SubmitJobHandler(cfg, tokenStore))
-			jobGroup.POST("/allocate", AllocateJobHandler(cfg, tokenStore))
-			jobGroup.GET("/:job_id", HandleGetJobByID(cfg, tokenStore))
-			jobGroup.DELETE("/:job_id", HandleDeleteJob(cfg, tokenStore))
-			jobGroup.GET("/connect/:job_id", HandleGetJobConnectLog(cfg, tokenStore))
+			jobGroup.POST("/submit", submit, SubmitJobHandler(slurmSource, tokenStore))
+			jobGroup.POST("/allocate", submit, AllocateJobHandler(slurmSource, tokenStore))
+			jobGroup.GET("/:job_id", read, HandleGetJobByID(slurmSource, tokenStore))
+			jobGroup.DELETE("/:job_id", cancel, HandleDeleteJob(slurmSource, tokenStore))
+			jobGroup.GET("/connect/:job_id", read, HandleGetJobConnectLog(cfg, tokenStore))
+		}
+
+		apiV1.POST("/ws/ticket", RequireSession(), CreateWebSocketTicketHandler(sessionStore, tickets))
+		// 交互式分配只能通过 WebSocket 连接和释放，而 API key 无法取得连接票据，分配会一直占用资源
+		apiV1.POST("/salloc/interactive", RequireSession(), HandleCreateSallocSession(executor, sessionStore))
+		apiV1.POST("/sbatch", submit, SbatchSubmitHandler(executor))
+
+		// 管理自己的 API key，泄露的 key 不能用来创建新的 key
+		keyGroup := apiV1.Group("/keys", RequireSession())
+		{
+			keyGroup.GET("", ListAPIKeysHandler(apiKeys))
+			keyGroup.POST("", CreateAPIKeyHandler(apiKeys))
+			keyGroup.DELETE("/:key_id", RevokeAPIKeyHandler(apiKeys))
 		}
 
-		apiV1.POST("/salloc/interactive", HandleCreateSallocSession(cfg, sessionStore))
-		apiV1.POST("/sbatch", SbatchSubmitHandler(cfg, tokenStore))
+		// 两步验证设置，修改已启用的设置需要输入当前的验证码
+		mfaGroup := apiV1.Group("/mfa", RequireSession())
+		{
+			mfaGroup.GET("", TOTPStatusHandler(cfg, totpStore))
+			mfaGroup.POST("/enroll", TOTPEnrollHandler(cfg, totpStore))
+			mfaGroup.POST("/activate", TOTPActivateHandler(totpStore, audit))
+			mfaGroup.POST("/recovery-codes", RegenerateRecoveryCodesHandler(totpStore))
+			mfaGroup.POST("/disable", DisableTOTPHandler(cfg, totpStore, audit))
+		}
+
+		// 仅管理员可以访问的路由
+		adminGroup := apiV1.Group("/admin")
+		adminGroup.Use(RequireRole(auth.RoleAdmin))
+		{
+			adminGroup.GET("/cluster/status", GetClusterStatusHandler(slurmSource, tokenStore))
+			adminGroup.GET("/users", ListActiveUsersHandler(tokenStore))
+			if stats, ok := directory.(directoryStats); ok {
+				adminGroup.GET("/ldap", LDAPStatsHandler(stats))
+			}
+			adminGroup.GET("/lockouts", ListLoginLockoutsHandler(limiter))
+			adminGroup.DELETE("/lockouts/:kind/:key", ClearLoginLockoutHandler(limiter, audit))
+			adminGroup.GET("/audit", ListAuditLogHandler(audit))
+			adminGroup.DELETE("/mfa/:username", ResetTOTPHandler(totpStore, audit))
+		}
 	}
 
 	return router
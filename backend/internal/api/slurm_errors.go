@@ -0,0 +1,28 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"slurm-dashboard/internal/slurm"
+
+	"github.com/gin-gonic/gin"
+)
+
+// respondSlurmError 将 slurm 客户端返回的错误转换为统一的 HTTP 响应
+func respondSlurmError(c *gin.Context, err error, message string) {
+	log.Printf("%s: %v", message, err)
+
+	var apiErr *slurm.APIError
+	switch {
+	case slurm.IsUnreachable(err):
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to reach Slurm API", "details": err.Error()})
+	case errors.Is(err, slurm.ErrNotSupported):
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": message, "details": "Slurm API is unavailable and this operation has no command line fallback"})
+	case errors.As(err, &apiErr):
+		c.JSON(apiErr.HTTPStatus(), gin.H{"error": message, "details": err.Error(), "errors": apiErr.Errors, "warnings": apiErr.Warnings})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": message, "details": err.Error()})
+	}
+}
@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/services"
+)
+
+// accountCheckInterval 为确认用户仍然存在后不再重复查询的时间
+const accountCheckInterval = 5 * time.Minute
+
+// AccountChecker 确认不经过登录的请求（例如 API key）所属的用户仍然存在于 role_source 对应的用户目录中。
+// 账户被删除或停用后，其 API key 最迟在 accountCheckInterval 之后失效
+type AccountChecker struct {
+	cfg       *config.Provider
+	executor  services.Executor
+	directory Directory
+
+	mu sync.Mutex
+	// verified 为每个用户最近一次确认存在的时间
+	verified map[string]time.Time
+	// now 返回当前时间，测试中可以替换
+	now func() time.Time
+}
+
+func NewAccountChecker(cfg *config.Provider, executor services.Executor, directory Directory) *AccountChecker {
+	return &AccountChecker{cfg: cfg, executor: executor, directory: directory, verified: make(map[string]time.Time), now: time.Now}
+}
+
+// Check 确认用户仍然存在。目录中找不到用户时返回 ErrInvalidCredentials，查询失败时返回其他错误
+func (a *AccountChecker) Check(ctx context.Context, username string) error {
+	a.mu.Lock()
+	verifiedAt, ok := a.verified[username]
+	a.mu.Unlock()
+	if ok && a.now().Sub(verifiedAt) < accountCheckInterval {
+		return nil
+	}
+
+	_, err := LookupGroups(ctx, a.cfg.Get(), a.executor, a.directory, username)
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err != nil {
+		delete(a.verified, username)
+		return err
+	}
+	a.verified[username] = a.now()
+	return nil
+}
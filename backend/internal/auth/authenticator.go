@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"slurm-dashboard/config"
+)
+
+// ErrInvalidCredentials 表示用户不存在、不唯一或密码错误
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Identity 是认证成功的用户。Backend 为完成认证的后端；
+// Groups 只在 ldap 后端认证且 role_source 为 ldap 时取得，其他情况下角色由 role_source 另行查询。
+type Identity struct {
+	Username string
+	Backend  string
+	Groups   []string
+}
+
+// Authenticator 是一种认证方式
+type Authenticator interface {
+	// Authenticate 校验用户名和密码，用户不存在或密码错误时返回 ErrInvalidCredentials
+	Authenticate(username, password string) (*Identity, error)
+}
+
+// Directory 是用户目录，除了校验密码外还能查询用户所属的组
+type Directory interface {
+	Authenticator
+	// Groups 返回用户所属的组，用于在刷新 token 时重新计算角色
+	Groups(username string) ([]string, error)
+}
+
+// Chain 按 auth_backends 的顺序依次尝试各个认证后端，配置重新加载后立即生效
+type Chain struct {
+	cfg      *config.Provider
+	backends map[string]Authenticator
+}
+
+var _ Authenticator = (*Chain)(nil)
+
+// NewChain 创建认证链，backends 为后端名到实现的映射，auth_backends 中未提供实现的后端会被当作不可用
+func NewChain(cfg *config.Provider, backends map[string]Authenticator) *Chain {
+	return &Chain{cfg: cfg, backends: backends}
+}
+
+// Authenticate 返回第一个认证成功的后端的结果。某个后端出错（例如 LDAP 不可达）时继续尝试其余后端。
+// 所有后端都失败时，只要有后端明确拒绝了用户名或密码就返回 ErrInvalidCredentials，
+// 避免一个后端的故障让错误的密码不计入登录失败次数；所有后端都出错时才返回这些错误。
+func (c *Chain) Authenticate(username, password string) (*Identity, error) {
+	var errs []error
+	rejected := false
+	for _, name := range c.cfg.Get().AuthBackends {
+		backend, ok := c.backends[name]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: auth backend is not available", name))
+			continue
+		}
+		identity, err := backend.Authenticate(username, password)
+		if err == nil {
+			identity.Backend = name
+			return identity, nil
+		}
+		if errors.Is(err, ErrInvalidCredentials) {
+			rejected = true
+		} else {
+			errs = append(errs, fmt.Errorf("%s: %w", name, err))
+		}
+	}
+	if rejected || len(errs) == 0 {
+		if len(errs) > 0 {
+			log.Printf("Auth backends failed while checking credentials for %s: %v", username, errors.Join(errs...))
+		}
+		return nil, ErrInvalidCredentials
+	}
+	return nil, errors.Join(errs...)
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"slurm-dashboard/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type stubAuthenticator struct {
+	password string
+	err      error
+}
+
+func (s stubAuthenticator) Authenticate(username, password string) (*Identity, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	if password != s.password {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Username: username}, nil
+}
+
+func TestChainTriesBackendsInOrder(t *testing.T) {
+	cfg := config.Default()
+	cfg.AuthBackends = []string{config.AuthBackendLDAP, config.AuthBackendHtpasswd}
+	provider := config.NewStaticProvider(cfg)
+	ldapDown := errors.New("no LDAP server is available")
+	chain := NewChain(provider, map[string]Authenticator{
+		config.AuthBackendLDAP:     stubAuthenticator{err: ldapDown},
+		config.AuthBackendHtpasswd: stubAuthenticator{password: "local"},
+	})
+
+	// LDAP 不可达时仍然可以用本地用户登录
+	identity, err := chain.Authenticate("alice", "local")
+	if err != nil || identity.Backend != config.AuthBackendHtpasswd {
+		t.Fatalf("expected the htpasswd backend to authenticate, got %+v, %v", identity, err)
+	}
+
+	// 其他后端明确拒绝了密码时，LDAP 的故障不应掩盖错误的密码
+	if _, err := chain.Authenticate("alice", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+
+	// 没有后端能给出结论时返回错误而不是 ErrInvalidCredentials，以便记录日志
+	cfg.AuthBackends = []string{config.AuthBackendLDAP}
+	if _, err := chain.Authenticate("alice", "wrong"); !errors.Is(err, ldapDown) || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected the LDAP error to be reported, got %v", err)
+	}
+
+	cfg.AuthBackends = []string{config.AuthBackendHtpasswd}
+	if _, err := chain.Authenticate("alice", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials, got %v", err)
+	}
+}
+
+func TestHtpasswdAuthenticator(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("correct"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "users.htpasswd")
+	content := "# development users\nalice:" + string(hash) + "\nbob:{SHA}fEqNCco3Yq9h5ZUglD3CZJT4lBs=\n"
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.HtpasswdFile = path
+	h := NewHtpasswdAuthenticator(config.NewStaticProvider(cfg))
+
+	if identity, err := h.Authenticate("alice", "correct"); err != nil || identity.Username != "alice" {
+		t.Fatalf("expected alice to authenticate, got %+v, %v", identity, err)
+	}
+	for _, tc := range []struct{ username, password string }{
+		{"alice", "wrong"},
+		{"carol", "correct"},
+		// 非 bcrypt 的哈希被忽略
+		{"bob", "password"},
+	} {
+		if _, err := h.Authenticate(tc.username, tc.password); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("%s/%s: expected ErrInvalidCredentials, got %v", tc.username, tc.password, err)
+		}
+	}
+
+	// 修改文件后不需要重启即可删除用户
+	if err := os.WriteFile(path, []byte("# nobody\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Authenticate("alice", "correct"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected the reloaded file to drop alice, got %v", err)
+	}
+}
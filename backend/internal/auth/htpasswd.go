@@ -0,0 +1,109 @@
+package auth
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"slurm-dashboard/config"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// HtpasswdAuthenticator 使用 htpasswd 格式的用户文件认证，只支持 bcrypt 哈希（htpasswd -B）。
+// 文件在修改时间或大小变化后重新读取，增删用户不需要重启。
+type HtpasswdAuthenticator struct {
+	cfg *config.Provider
+
+	mu      sync.Mutex
+	path    string
+	modTime time.Time
+	size    int64
+	users   map[string][]byte
+
+	dummyOnce sync.Once
+	dummyHash []byte
+}
+
+var _ Authenticator = (*HtpasswdAuthenticator)(nil)
+
+func NewHtpasswdAuthenticator(cfg *config.Provider) *HtpasswdAuthenticator {
+	return &HtpasswdAuthenticator{cfg: cfg}
+}
+
+func (h *HtpasswdAuthenticator) Authenticate(username, password string) (*Identity, error) {
+	users, err := h.load()
+	if err != nil {
+		return nil, err
+	}
+	hash, ok := users[username]
+	if !ok {
+		// 与存在的用户花费相同的时间，避免通过响应时间探测用户名
+		hash = h.dummy()
+	}
+	if bcrypt.CompareHashAndPassword(hash, []byte(password)) != nil || !ok || password == "" {
+		return nil, ErrInvalidCredentials
+	}
+	return &Identity{Username: username}, nil
+}
+
+// load 返回当前 htpasswd_file 中的用户，文件未变化时使用缓存
+func (h *HtpasswdAuthenticator) load() (map[string][]byte, error) {
+	path := h.cfg.Get().HtpasswdFile
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read htpasswd file: %w", err)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.users != nil && h.path == path && h.modTime.Equal(info.ModTime()) && h.size == info.Size() {
+		return h.users, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read htpasswd file: %w", err)
+	}
+	users, err := parseHtpasswd(data)
+	if err != nil {
+		return nil, fmt.Errorf("htpasswd file %s: %w", path, err)
+	}
+	h.path, h.modTime, h.size, h.users = path, info.ModTime(), info.Size(), users
+	log.Printf("Loaded %d users from htpasswd file %s", len(users), path)
+	return users, nil
+}
+
+// parseHtpasswd 解析 "用户名:哈希" 格式的行，忽略空行和 # 开头的注释，非 bcrypt 的哈希被跳过
+func parseHtpasswd(data []byte) (map[string][]byte, error) {
+	users := make(map[string][]byte)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		username, hash, ok := strings.Cut(line, ":")
+		if !ok || username == "" {
+			return nil, fmt.Errorf("line %d: expected username:hash", lineNo)
+		}
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			log.Printf("Ignoring htpasswd entry for user %s on line %d: only bcrypt hashes are supported", username, lineNo)
+			continue
+		}
+		users[username] = []byte(hash)
+	}
+	return users, scanner.Err()
+}
+
+func (h *HtpasswdAuthenticator) dummy() []byte {
+	h.dummyOnce.Do(func() {
+		h.dummyHash, _ = bcrypt.GenerateFromPassword([]byte("slurm-dashboard"), bcrypt.DefaultCost)
+	})
+	return h.dummyHash
+}
@@ -1,52 +1,152 @@
 package auth
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"net"
+	"os"
 
 	"slurm-dashboard/config"
 
 	"github.com/go-ldap/ldap/v3"
 )
 
-func AuthenticateLDAP(cfg *config.Config, username, password string) (bool, error) {
-	l, err := ldap.Dial("tcp", fmt.Sprintf("%s:%d", cfg.LDAPServerHost, cfg.LDAPServerPort))
+// dialLDAP 按 ldap_tls_mode 连接 addr，TLS 失败时直接返回错误，不会退回明文连接
+func dialLDAP(conf *config.Config, addr string) (*ldap.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
 	if err != nil {
-		return false, fmt.Errorf("failed to connect to LDAP server: %w", err)
+		return nil, err
 	}
-	defer l.Close()
+	dialer := ldap.DialWithDialer(&net.Dialer{Timeout: conf.LDAPTimeout})
 
-	err = l.Bind(cfg.LDAPAdminDN, cfg.LDAPAdminPassword)
+	var l *ldap.Conn
+	switch conf.LDAPTLSMode {
+	case config.LDAPTLSNone:
+		l, err = ldap.DialURL("ldap://"+addr, dialer)
+	case config.LDAPTLSLDAPS:
+		tlsConfig, tlsErr := ldapTLSConfig(conf, host)
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+		l, err = ldap.DialURL("ldaps://"+addr, dialer, ldap.DialWithTLSConfig(tlsConfig))
+	default:
+		tlsConfig, tlsErr := ldapTLSConfig(conf, host)
+		if tlsErr != nil {
+			return nil, tlsErr
+		}
+		l, err = ldap.DialURL("ldap://"+addr, dialer)
+		if err == nil {
+			l.SetTimeout(conf.LDAPTimeout)
+			if err = l.StartTLS(tlsConfig); err != nil {
+				l.Close()
+				return nil, fmt.Errorf("failed to start TLS with LDAP server %s: %w", addr, err)
+			}
+		}
+	}
 	if err != nil {
-		return false, fmt.Errorf("failed to bind as admin/service account: %w", err)
+		return nil, fmt.Errorf("failed to connect to LDAP server %s: %w", addr, err)
+	}
+	l.SetTimeout(conf.LDAPTimeout)
+	return l, nil
+}
+
+// ldapTLSConfig 根据配置构造校验 LDAP 服务器证书的 TLS 配置，未配置 ldap_tls_server_name 时校验 host
+func ldapTLSConfig(cfg *config.Config, host string) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName: cfg.LDAPTLSServerName,
+		MinVersion: tls.VersionTLS12,
 	}
+	if tlsConfig.ServerName == "" {
+		tlsConfig.ServerName = host
+	}
+	if cfg.LDAPTLSMinVersion == "1.3" {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	if cfg.LDAPTLSCAFile != "" {
+		pem, err := os.ReadFile(cfg.LDAPTLSCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read LDAP CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in LDAP CA file %s", cfg.LDAPTLSCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	tlsConfig.InsecureSkipVerify = cfg.LDAPTLSInsecureSkipVerify
+	return tlsConfig, nil
+}
 
+// findLDAPUser 在已以服务账号绑定的连接上查找唯一的用户条目
+func findLDAPUser(l *ldap.Conn, cfg *config.Config, username string) (*ldap.Entry, error) {
+	attributes := []string{"dn"}
+	if cfg.LDAPGroupMembership == config.GroupMembershipMemberOf {
+		attributes = append(attributes, "memberOf")
+	}
 	searchRequest := ldap.NewSearchRequest(
 		cfg.LDAPSearchBaseDN,
 		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
-		fmt.Sprintf(cfg.LDAPUserSearchFilter, username),
-		[]string{"dn"},
+		fmt.Sprintf(cfg.LDAPUserSearchFilter, ldap.EscapeFilter(username)),
+		attributes,
 		nil,
 	)
 
 	sr, err := l.Search(searchRequest)
 	if err != nil {
-		return false, fmt.Errorf("user search failed: %w", err)
+		return nil, fmt.Errorf("user search failed: %w", err)
 	}
 
 	if len(sr.Entries) != 1 {
 		log.Printf("User %s not found or not unique, entries found: %d", username, len(sr.Entries))
-		return false, nil
+		return nil, ErrInvalidCredentials
+	}
+	return sr.Entries[0], nil
+}
+
+// ldapUserGroups 按 ldap_group_membership 返回用户所属组的名称（DN 第一个 RDN 的值）
+func ldapUserGroups(l *ldap.Conn, cfg *config.Config, username string, entry *ldap.Entry) ([]string, error) {
+	if cfg.LDAPGroupMembership == config.GroupMembershipMemberOf {
+		var groups []string
+		for _, dn := range entry.GetAttributeValues("memberOf") {
+			groups = append(groups, groupName(dn))
+		}
+		return groups, nil
 	}
 
-	userDN := sr.Entries[0].DN
-	err = l.Bind(userDN, password)
+	baseDN := cfg.LDAPGroupSearchBaseDN
+	if baseDN == "" {
+		baseDN = cfg.LDAPSearchBaseDN
+	}
+	searchRequest := ldap.NewSearchRequest(
+		baseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, 0, false,
+		fmt.Sprintf(cfg.LDAPGroupSearchFilter, ldap.EscapeFilter(username)),
+		[]string{"cn"},
+		nil,
+	)
+	sr, err := l.Search(searchRequest)
 	if err != nil {
-		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
-			return false, nil
+		return nil, fmt.Errorf("group search failed: %w", err)
+	}
+
+	groups := make([]string, 0, len(sr.Entries))
+	for _, group := range sr.Entries {
+		if name := group.GetAttributeValue("cn"); name != "" {
+			groups = append(groups, name)
+		} else {
+			groups = append(groups, groupName(group.DN))
 		}
-		return false, fmt.Errorf("final user bind failed: %w", err)
 	}
+	return groups, nil
+}
 
-	return true, nil
+// groupName 返回组 DN 中第一个 RDN 的值，例如 cn=hpc-admins,ou=groups,dc=example,dc=com 返回 hpc-admins
+func groupName(dn string) string {
+	parsed, err := ldap.ParseDN(dn)
+	if err != nil || len(parsed.RDNs) == 0 || len(parsed.RDNs[0].Attributes) == 0 {
+		return dn
+	}
+	return parsed.RDNs[0].Attributes[0].Value
 }
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"slurm-dashboard/config"
+)
+
+// tlsServer 启动一个只用于 TLS 握手的服务器，返回其地址和签发证书的 PEM 文件
+func tlsServer(t *testing.T) (*config.Config, string) {
+	t.Helper()
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	t.Cleanup(srv.Close)
+
+	host, port, err := net.SplitHostPort(srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Default()
+	cfg.LDAPServerHost = host
+	cfg.LDAPServerPort, _ = strconv.Atoi(port)
+	cfg.LDAPTLSMode = config.LDAPTLSLDAPS
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, certPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return cfg, caFile
+}
+
+func TestDialLDAPSVerifiesCertificate(t *testing.T) {
+	cfg, caFile := tlsServer(t)
+
+	// 默认使用系统 CA，自签名证书应当被拒绝
+	if l, err := dialLDAP(cfg, cfg.LDAPServerAddresses()[0]); err == nil {
+		l.Close()
+		t.Fatal("expected an untrusted certificate to be rejected")
+	}
+
+	cfg.LDAPTLSCAFile = caFile
+	l, err := dialLDAP(cfg, cfg.LDAPServerAddresses()[0])
+	if err != nil {
+		t.Fatalf("expected the configured CA to be trusted: %v", err)
+	}
+	l.Close()
+
+	// 证书中的名称与 ldap_tls_server_name 不符
+	cfg.LDAPTLSServerName = "ldap.example.org"
+	if l, err := dialLDAP(cfg, cfg.LDAPServerAddresses()[0]); err == nil {
+		l.Close()
+		t.Fatal("expected a server name mismatch to be rejected")
+	}
+}
+
+func TestDialLDAPSInsecureSkipVerify(t *testing.T) {
+	cfg, _ := tlsServer(t)
+	cfg.LDAPTLSInsecureSkipVerify = true
+
+	l, err := dialLDAP(cfg, cfg.LDAPServerAddresses()[0])
+	if err != nil {
+		t.Fatalf("expected verification to be skipped: %v", err)
+	}
+	l.Close()
+}
+
+func TestLDAPTLSConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.LDAPServerHost = "ldap.example.com"
+	cfg.LDAPTLSMinVersion = "1.3"
+
+	tlsConfig, err := ldapTLSConfig(cfg, cfg.LDAPServerHost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tlsConfig.ServerName != "ldap.example.com" || tlsConfig.MinVersion != tls.VersionTLS13 || tlsConfig.InsecureSkipVerify {
+		t.Errorf("unexpected TLS config: %+v", tlsConfig)
+	}
+
+	cfg.LDAPTLSCAFile = filepath.Join(t.TempDir(), "empty.pem")
+	if err := os.WriteFile(cfg.LDAPTLSCAFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ldapTLSConfig(cfg, cfg.LDAPServerHost); err == nil {
+		t.Error("expected a CA file without certificates to be rejected")
+	}
+}
@@ -0,0 +1,387 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"slurm-dashboard/config"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// 连续连接失败的服务器在退避时间内被跳过，退避时间按失败次数从 ldapBackoffBase 指数增长到 ldapBackoffMax
+const (
+	ldapBackoffBase = time.Second
+	ldapBackoffMax  = time.Minute
+)
+
+// LDAPServerStats 是单个 LDAP 服务器的状态和绑定指标
+type LDAPServerStats struct {
+	Address             string        `json:"address"`
+	Healthy             bool          `json:"healthy"`
+	ConsecutiveFailures int           `json:"consecutive_failures"`
+	RetryAfter          *time.Time    `json:"retry_after,omitempty"`
+	LastError           string        `json:"last_error,omitempty"`
+	IdleConnections     int           `json:"idle_connections"`
+	Binds               int64         `json:"binds"`
+	BindFailures        int64         `json:"bind_failures"`
+	TotalBindLatency    time.Duration `json:"total_bind_latency_ns"`
+	MaxBindLatency      time.Duration `json:"max_bind_latency_ns"`
+}
+
+type ldapServer struct {
+	addr      string
+	idle      []*ldap.Conn
+	failures  int
+	downUntil time.Time
+	lastError string
+
+	binds            int64
+	bindFailures     int64
+	totalBindLatency time.Duration
+	maxBindLatency   time.Duration
+}
+
+// ldapSession 是从连接池取出的一个连接
+type ldapSession struct {
+	*ldap.Conn
+	client *LDAPClient
+	server *ldapServer
+	conf   *config.Config
+	// serviceBound 为 true 表示连接当前以服务账号绑定，只有这样的连接才能放回连接池
+	serviceBound bool
+}
+
+// bind 绑定并记录绑定延迟
+func (s *ldapSession) bind(dn, password string) error {
+	s.serviceBound = false
+	start := time.Now()
+	err := s.Bind(dn, password)
+	s.client.recordBind(s.server, time.Since(start), err)
+	return err
+}
+
+func (s *ldapSession) bindService() error {
+	if err := s.bind(s.conf.LDAPAdminDN, s.conf.LDAPAdminPassword); err != nil {
+		return fmt.Errorf("failed to bind as admin/service account: %w", err)
+	}
+	s.serviceBound = true
+	return nil
+}
+
+// run 执行 fn。连接在请求过程中被服务器断开时，go-ldap 返回的错误不带 ErrorNetwork 结果码，
+// 这里统一包装为 ErrorNetwork，以便触发故障切换
+func (s *ldapSession) run(fn func(*ldapSession) error) error {
+	err := fn(s)
+	if err != nil && !isUnavailable(err) && s.IsClosing() {
+		return ldap.NewError(ldap.ErrorNetwork, err)
+	}
+	return err
+}
+
+// LDAPClient 维护到各 LDAP 服务器的连接池，池中的连接都已以服务账号绑定。
+// 按 ldap_servers 的顺序选择服务器，无法连接的服务器按退避时间跳过，直到健康检查或后续请求确认其恢复。
+type LDAPClient struct {
+	cfg *config.Provider
+
+	mu sync.Mutex
+	// conf 为连接池中连接所对应的配置，配置重新加载后丢弃所有空闲连接
+	conf    *config.Config
+	servers map[string]*ldapServer
+}
+
+var _ Directory = (*LDAPClient)(nil)
+
+func NewLDAPClient(cfg *config.Provider) *LDAPClient {
+	return &LDAPClient{cfg: cfg, servers: make(map[string]*ldapServer)}
+}
+
+// Authenticate 校验用户名和密码，并在同一个连接上查询用户所属的组
+func (c *LDAPClient) Authenticate(username, password string) (*Identity, error) {
+	if password == "" {
+		// 空密码会被 LDAP 服务器视为匿名绑定而成功
+		return nil, ErrInvalidCredentials
+	}
+
+	var identity *Identity
+	err := c.withSession(func(s *ldapSession) error {
+		entry, err := findLDAPUser(s.Conn, s.conf, username)
+		if err != nil {
+			return err
+		}
+
+		bindErr := s.bind(entry.DN, password)
+		// 普通用户不一定有搜索组的权限，而且连接要以服务账号身份放回连接池
+		if err := s.bindService(); err != nil {
+			return err
+		}
+		if bindErr != nil {
+			if ldap.IsErrorWithCode(bindErr, ldap.LDAPResultInvalidCredentials) {
+				return ErrInvalidCredentials
+			}
+			return fmt.Errorf("final user bind failed: %w", bindErr)
+		}
+
+		identity = &Identity{Username: username}
+		if s.conf.RoleSource == config.RoleSourceLDAP {
+			identity.Groups, err = ldapUserGroups(s.Conn, s.conf, username, entry)
+		}
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return identity, nil
+}
+
+// Groups 以服务账号查询用户所属的组
+func (c *LDAPClient) Groups(username string) ([]string, error) {
+	var groups []string
+	err := c.withSession(func(s *ldapSession) error {
+		entry, err := findLDAPUser(s.Conn, s.conf, username)
+		if err != nil {
+			return err
+		}
+		groups, err = ldapUserGroups(s.Conn, s.conf, username, entry)
+		return err
+	})
+	return groups, err
+}
+
+// RunHealthChecks 按配置的间隔检查每个服务器能否连接并以服务账号绑定，直到 ctx 结束。
+// 检查同时让连接池保持有可用的连接。
+func (c *LDAPClient) RunHealthChecks(ctx context.Context) {
+	for {
+		conf := c.currentConfig()
+		for _, addr := range conf.LDAPServerAddresses() {
+			if !conf.UsesLDAP() {
+				break
+			}
+			srv := c.server(addr)
+			if err := c.tryServer(srv, conf, (*ldapSession).bindService); err != nil {
+				c.markFailure(srv, err)
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(conf.LDAPHealthCheckInterval):
+		}
+	}
+}
+
+// Stats 按配置顺序返回各服务器的状态和绑定指标
+func (c *LDAPClient) Stats() []LDAPServerStats {
+	conf := c.currentConfig()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	var stats []LDAPServerStats
+	for _, addr := range conf.LDAPServerAddresses() {
+		srv, ok := c.servers[addr]
+		if !ok {
+			stats = append(stats, LDAPServerStats{Address: addr, Healthy: true})
+			continue
+		}
+		st := LDAPServerStats{
+			Address:             addr,
+			Healthy:             srv.failures == 0,
+			ConsecutiveFailures: srv.failures,
+			LastError:           srv.lastError,
+			IdleConnections:     len(srv.idle),
+			Binds:               srv.binds,
+			BindFailures:        srv.bindFailures,
+			TotalBindLatency:    srv.totalBindLatency,
+			MaxBindLatency:      srv.maxBindLatency,
+		}
+		if srv.downUntil.After(now) {
+			retryAfter := srv.downUntil
+			st.RetryAfter = &retryAfter
+		}
+		stats = append(stats, st)
+	}
+	return stats
+}
+
+// Close 关闭所有空闲连接
+func (c *LDAPClient) Close() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, srv := range c.servers {
+		closeAll(srv.idle)
+		srv.idle = nil
+	}
+}
+
+// withSession 依次在可用的服务器上执行 fn，只有连接失败才会切换到下一个服务器，
+// 其他错误（例如密码错误）直接返回
+func (c *LDAPClient) withSession(fn func(*ldapSession) error) error {
+	conf := c.currentConfig()
+	var lastErr error
+	for _, srv := range c.candidates(conf) {
+		err := c.tryServer(srv, conf, fn)
+		if !isUnavailable(err) {
+			return err
+		}
+		c.markFailure(srv, err)
+		lastErr = err
+	}
+	if lastErr == nil {
+		return fmt.Errorf("no LDAP server is configured")
+	}
+	return fmt.Errorf("no LDAP server is available: %w", lastErr)
+}
+
+// tryServer 在 srv 上执行 fn。空闲连接可能已经被服务器关闭，此时换一个新连接重试一次。
+func (c *LDAPClient) tryServer(srv *ldapServer, conf *config.Config, fn func(*ldapSession) error) error {
+	if s := c.takeIdle(srv, conf); s != nil {
+		err := s.run(fn)
+		if !isUnavailable(err) {
+			c.release(s)
+			c.markSuccess(srv)
+			return err
+		}
+		s.Close()
+	}
+
+	conn, err := dialLDAP(conf, srv.addr)
+	if err != nil {
+		return err
+	}
+	s := &ldapSession{Conn: conn, client: c, server: srv, conf: conf}
+	if err := s.bindService(); err != nil {
+		s.Close()
+		return err
+	}
+	err = s.run(fn)
+	if isUnavailable(err) {
+		s.Close()
+		return err
+	}
+	c.release(s)
+	c.markSuccess(srv)
+	return err
+}
+
+// currentConfig 返回当前配置，配置变化后丢弃按旧配置建立的空闲连接
+func (c *LDAPClient) currentConfig() *config.Config {
+	conf := c.cfg.Get()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conf != conf {
+		for _, srv := range c.servers {
+			closeAll(srv.idle)
+			srv.idle = nil
+		}
+		c.conf = conf
+	}
+	return conf
+}
+
+func (c *LDAPClient) server(addr string) *ldapServer {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	srv, ok := c.servers[addr]
+	if !ok {
+		srv = &ldapServer{addr: addr}
+		c.servers[addr] = srv
+	}
+	return srv
+}
+
+// candidates 按配置顺序返回服务器，处于退避期的服务器排在最后，所有服务器都不可用时仍会尝试它们
+func (c *LDAPClient) candidates(conf *config.Config) []*ldapServer {
+	var available, backingOff []*ldapServer
+	now := time.Now()
+	for _, addr := range conf.LDAPServerAddresses() {
+		srv := c.server(addr)
+		c.mu.Lock()
+		down := srv.downUntil.After(now)
+		c.mu.Unlock()
+		if down {
+			backingOff = append(backingOff, srv)
+		} else {
+			available = append(available, srv)
+		}
+	}
+	return append(available, backingOff...)
+}
+
+func (c *LDAPClient) takeIdle(srv *ldapServer, conf *config.Config) *ldapSession {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(srv.idle) > 0 {
+		conn := srv.idle[len(srv.idle)-1]
+		srv.idle = srv.idle[:len(srv.idle)-1]
+		if !conn.IsClosing() {
+			return &ldapSession{Conn: conn, client: c, server: srv, conf: conf, serviceBound: true}
+		}
+		conn.Close()
+	}
+	return nil
+}
+
+// release 将以服务账号绑定的连接放回连接池，池满或配置已经变化时关闭连接
+func (c *LDAPClient) release(s *ldapSession) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if !s.serviceBound || s.IsClosing() || s.conf != c.conf || len(s.server.idle) >= s.conf.LDAPPoolSize {
+		s.Close()
+		return
+	}
+	s.server.idle = append(s.server.idle, s.Conn)
+}
+
+func (c *LDAPClient) recordBind(srv *ldapServer, d time.Duration, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	srv.binds++
+	srv.totalBindLatency += d
+	if d > srv.maxBindLatency {
+		srv.maxBindLatency = d
+	}
+	if err != nil {
+		srv.bindFailures++
+	}
+}
+
+func (c *LDAPClient) markFailure(srv *ldapServer, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	srv.failures++
+	backoff := ldapBackoffMax
+	if shift := srv.failures - 1; shift < 6 {
+		backoff = min(ldapBackoffBase<<shift, ldapBackoffMax)
+	}
+	srv.downUntil = time.Now().Add(backoff)
+	srv.lastError = err.Error()
+	closeAll(srv.idle)
+	srv.idle = nil
+	log.Printf("LDAP server %s is unavailable (%d consecutive failures), skipping it for %s: %v", srv.addr, srv.failures, backoff, err)
+}
+
+func (c *LDAPClient) markSuccess(srv *ldapServer) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if srv.failures > 0 {
+		log.Printf("LDAP server %s is available again", srv.addr)
+	}
+	srv.failures = 0
+	srv.downUntil = time.Time{}
+	srv.lastError = ""
+}
+
+// isUnavailable 判断错误是否由无法连接服务器或连接中断引起，这类错误会触发切换到下一个服务器
+func isUnavailable(err error) bool {
+	return ldap.IsErrorWithCode(err, ldap.ErrorNetwork)
+}
+
+func closeAll(conns []*ldap.Conn) {
+	for _, conn := range conns {
+		conn.Close()
+	}
+}
@@ -0,0 +1,95 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/testutil/fakeldap"
+)
+
+func newLDAPTestClient(t *testing.T, servers ...*fakeldap.Server) *LDAPClient {
+	t.Helper()
+	cfg := config.Default()
+	for _, srv := range servers {
+		cfg.LDAPServers = append(cfg.LDAPServers, srv.Addr())
+	}
+	cfg.LDAPTLSMode = config.LDAPTLSNone
+	cfg.LDAPAdminDN = fakeldap.AdminDN
+	cfg.LDAPAdminPassword = fakeldap.AdminPassword
+	cfg.LDAPSearchBaseDN = fakeldap.BaseDN
+	cfg.LDAPUserSearchFilter = "(uid=%s)"
+	client := NewLDAPClient(config.NewStaticProvider(cfg))
+	t.Cleanup(client.Close)
+	return client
+}
+
+func TestLDAPClientAuthenticate(t *testing.T) {
+	srv := fakeldap.New(t)
+	srv.AddUser("alice", "correct", "alice", "hpc-admins")
+	client := newLDAPTestClient(t, srv)
+
+	identity, err := client.Authenticate("alice", "correct")
+	if err != nil {
+		t.Fatalf("expected authentication to succeed: %v", err)
+	}
+	if len(identity.Groups) != 2 || identity.Groups[1] != "hpc-admins" {
+		t.Fatalf("unexpected groups: %v", identity.Groups)
+	}
+
+	if _, err := client.Authenticate("alice", "wrong"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for a wrong password, got %v", err)
+	}
+	if _, err := client.Authenticate("mallory", "correct"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+
+	// 所有请求复用连接池中的同一个连接
+	if n := srv.Connections(); n != 1 {
+		t.Fatalf("expected pooled connection to be reused, got %d connections", n)
+	}
+}
+
+func TestLDAPClientFailover(t *testing.T) {
+	primary := fakeldap.New(t)
+	secondary := fakeldap.New(t)
+	for _, srv := range []*fakeldap.Server{primary, secondary} {
+		srv.AddUser("alice", "correct", "hpc-operators")
+	}
+	client := newLDAPTestClient(t, primary, secondary)
+
+	if _, err := client.Authenticate("alice", "correct"); err != nil {
+		t.Fatalf("expected authentication to succeed: %v", err)
+	}
+	if secondary.Connections() != 0 {
+		t.Fatal("expected the first server to be preferred")
+	}
+
+	// 主服务器宕机后，池中的连接失效，请求切换到备用服务器
+	primary.Close()
+	groups, err := client.Groups("alice")
+	if err != nil {
+		t.Fatalf("expected failover to the second server: %v", err)
+	}
+	if len(groups) != 1 || groups[0] != "hpc-operators" {
+		t.Fatalf("unexpected groups: %v", groups)
+	}
+
+	stats := client.Stats()
+	if len(stats) != 2 || stats[0].Healthy || stats[0].RetryAfter == nil || !stats[1].Healthy {
+		t.Fatalf("expected the first server to be backing off: %+v", stats)
+	}
+	if stats[1].Binds == 0 {
+		t.Fatalf("expected bind metrics for the second server: %+v", stats[1])
+	}
+
+	// 处于退避期的服务器排在最后，后续请求直接使用备用服务器
+	if _, err := client.Authenticate("alice", "correct"); err != nil {
+		t.Fatalf("expected authentication to succeed: %v", err)
+	}
+
+	secondary.Close()
+	if _, err := client.Groups("alice"); err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Fatalf("expected an availability error when all servers are down, got %v", err)
+	}
+}
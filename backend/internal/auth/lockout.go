@@ -0,0 +1,181 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"slurm-dashboard/config"
+)
+
+// 登录失败计数的对象
+const (
+	LockoutUser = "user"
+	LockoutIP   = "ip"
+)
+
+// loginPruneInterval 为清理过期失败记录的最小间隔
+const loginPruneInterval = time.Minute
+
+// LoginAttempt 是一个用户名或 IP 的登录失败记录
+type LoginAttempt struct {
+	Kind          string    `json:"kind"`
+	Key           string    `json:"key"`
+	Failures      int       `json:"failures"`
+	LastFailureAt time.Time `json:"last_failure_at"`
+	// RetryAt 之前该用户名或 IP 的登录会被直接拒绝
+	RetryAt time.Time `json:"retry_at"`
+	Locked  bool      `json:"locked"`
+}
+
+type attemptKey struct {
+	kind, key string
+}
+
+// LoginLimiter 按用户名和客户端 IP 限制密码登录的失败次数：每次失败后指数退避，
+// 连续失败达到上限后锁定一段时间。记录只保存在内存中，后端重启后清零。
+type LoginLimiter struct {
+	cfg *config.Provider
+	now func() time.Time
+
+	mu        sync.Mutex
+	attempts  map[attemptKey]*LoginAttempt
+	lastPrune time.Time
+}
+
+func NewLoginLimiter(cfg *config.Provider) *LoginLimiter {
+	return &LoginLimiter{cfg: cfg, now: time.Now, attempts: make(map[attemptKey]*LoginAttempt)}
+}
+
+// NormalizeUsername 统一用户名的大小写和空白，避免通过变换大小写绕过按用户名的限制
+func NormalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// Check 返回用户名和 IP 距离允许下一次登录尝试还需等待的时间，为 0 时允许尝试
+func (l *LoginLimiter) Check(username, ip string) time.Duration {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	var wait time.Duration
+	for _, key := range l.keys(username, ip) {
+		if a, ok := l.attempts[key]; ok && a.RetryAt.After(now) {
+			wait = max(wait, a.RetryAt.Sub(now))
+		}
+	}
+	return wait
+}
+
+// RecordFailure 记录一次密码错误，返回因此次失败而被锁定的记录
+func (l *LoginLimiter) RecordFailure(username, ip string) []LoginAttempt {
+	conf := l.cfg.Get()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.prune(conf, now)
+
+	var locked []LoginAttempt
+	for _, key := range l.keys(username, ip) {
+		a, ok := l.attempts[key]
+		if !ok || now.Sub(a.LastFailureAt) > conf.LoginLockoutDuration {
+			a = &LoginAttempt{Kind: key.kind, Key: key.key}
+			l.attempts[key] = a
+		}
+		a.Failures++
+		a.LastFailureAt = now
+
+		limit := conf.LoginMaxFailures
+		if key.kind == LockoutIP {
+			limit = conf.LoginMaxFailuresPerIP
+		}
+		switch {
+		case a.Failures >= limit:
+			if !a.Locked {
+				a.Locked = true
+				locked = append(locked, *a)
+			}
+			a.RetryAt = now.Add(conf.LoginLockoutDuration)
+		case conf.LoginBackoffBase > 0:
+			a.RetryAt = now.Add(backoff(conf, a.Failures))
+		}
+	}
+	return locked
+}
+
+// RecordSuccess 在登录成功后清除用户名的失败记录。IP 的记录保留，
+// 避免攻击者用自己的账号登录来重置 IP 的计数。
+func (l *LoginLimiter) RecordSuccess(username string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.attempts, attemptKey{LockoutUser, NormalizeUsername(username)})
+}
+
+// List 返回仍在计数期内的失败记录，已锁定的排在前面
+func (l *LoginLimiter) List() []LoginAttempt {
+	conf := l.cfg.Get()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	l.prune(conf, now)
+
+	attempts := make([]LoginAttempt, 0, len(l.attempts))
+	for _, a := range l.attempts {
+		if !a.RetryAt.After(now) && now.Sub(a.LastFailureAt) > conf.LoginLockoutDuration {
+			continue
+		}
+		attempt := *a
+		attempt.Locked = a.Locked && a.RetryAt.After(now)
+		attempts = append(attempts, attempt)
+	}
+	sort.Slice(attempts, func(i, j int) bool {
+		if attempts[i].Locked != attempts[j].Locked {
+			return attempts[i].Locked
+		}
+		return attempts[i].LastFailureAt.After(attempts[j].LastFailureAt)
+	})
+	return attempts
+}
+
+// Clear 删除一个用户名或 IP 的失败记录并解除锁定，记录不存在时返回 false
+func (l *LoginLimiter) Clear(kind, key string) bool {
+	if kind == LockoutUser {
+		key = NormalizeUsername(key)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	k := attemptKey{kind, key}
+	_, ok := l.attempts[k]
+	delete(l.attempts, k)
+	return ok
+}
+
+// backoff 返回第 failures 次失败后需要等待的时间，每次失败翻倍，最长为锁定时间
+func backoff(conf *config.Config, failures int) time.Duration {
+	d := conf.LoginBackoffBase
+	for i := 1; i < failures && d < conf.LoginLockoutDuration; i++ {
+		d *= 2
+	}
+	return min(d, conf.LoginLockoutDuration)
+}
+
+func (l *LoginLimiter) keys(username, ip string) []attemptKey {
+	keys := []attemptKey{{LockoutUser, NormalizeUsername(username)}}
+	if ip != "" {
+		keys = append(keys, attemptKey{LockoutIP, ip})
+	}
+	return keys
+}
+
+// prune 删除已经过了计数期且不再限制登录的记录，调用者需持有 mu
+func (l *LoginLimiter) prune(conf *config.Config, now time.Time) {
+	if now.Sub(l.lastPrune) < loginPruneInterval {
+		return
+	}
+	l.lastPrune = now
+	for key, a := range l.attempts {
+		if now.Sub(a.LastFailureAt) > conf.LoginLockoutDuration && !a.RetryAt.After(now) {
+			delete(l.attempts, key)
+		}
+	}
+}
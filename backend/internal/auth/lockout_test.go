@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"testing"
+	"time"
+
+	"slurm-dashboard/config"
+)
+
+// newTestLimiter 返回使用可调时钟的 LoginLimiter
+func newTestLimiter(cfg *config.Config) (*LoginLimiter, *time.Time) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewLoginLimiter(config.NewStaticProvider(cfg))
+	limiter.now = func() time.Time { return now }
+	return limiter, &now
+}
+
+func TestLoginLimiterBackoffAndLockout(t *testing.T) {
+	cfg := config.Default()
+	cfg.LoginMaxFailures = 3
+	cfg.LoginLockoutDuration = 10 * time.Minute
+	cfg.LoginBackoffBase = time.Second
+	limiter, now := newTestLimiter(cfg)
+
+	if wait := limiter.Check("alice", "192.0.2.1"); wait != 0 {
+		t.Fatalf("first attempt must be allowed, got wait %s", wait)
+	}
+	limiter.RecordFailure("alice", "192.0.2.1")
+	if wait := limiter.Check("alice", "192.0.2.1"); wait != time.Second {
+		t.Fatalf("expected a 1s backoff, got %s", wait)
+	}
+	// 大小写不同的用户名共享计数，换 IP 也不能绕过
+	*now = now.Add(time.Second)
+	limiter.RecordFailure("Alice", "192.0.2.2")
+	if wait := limiter.Check("alice", "192.0.2.3"); wait != 2*time.Second {
+		t.Fatalf("expected the backoff to double, got %s", wait)
+	}
+
+	*now = now.Add(2 * time.Second)
+	locked := limiter.RecordFailure("alice", "192.0.2.1")
+	if len(locked) != 1 || locked[0].Kind != LockoutUser || locked[0].Key != "alice" {
+		t.Fatalf("expected alice to be locked, got %+v", locked)
+	}
+	if wait := limiter.Check("alice", "192.0.2.9"); wait != 10*time.Minute {
+		t.Fatalf("expected a 10m lockout, got %s", wait)
+	}
+	if wait := limiter.Check("bob", "192.0.2.9"); wait != 0 {
+		t.Fatalf("other users must not be affected, got %s", wait)
+	}
+
+	attempts := limiter.List()
+	if len(attempts) == 0 || !attempts[0].Locked || attempts[0].Key != "alice" || attempts[0].Failures != 3 {
+		t.Fatalf("expected alice to be listed first as locked, got %+v", attempts)
+	}
+
+	// 锁定到期后重新计数
+	*now = now.Add(10*time.Minute + time.Second)
+	if wait := limiter.Check("alice", "192.0.2.1"); wait != 0 {
+		t.Fatalf("lockout must expire, got wait %s", wait)
+	}
+	if locked := limiter.RecordFailure("alice", "192.0.2.1"); len(locked) != 0 {
+		t.Fatalf("failures must be counted again from zero, got %+v", locked)
+	}
+}
+
+func TestLoginLimiterPerIPAndClear(t *testing.T) {
+	cfg := config.Default()
+	cfg.LoginMaxFailuresPerIP = 3
+	cfg.LoginBackoffBase = 0
+	limiter, _ := newTestLimiter(cfg)
+
+	// 同一 IP 尝试不同的用户名
+	for _, username := range []string{"alice", "bob", "carol"} {
+		limiter.RecordFailure(username, "192.0.2.1")
+	}
+	if wait := limiter.Check("dave", "192.0.2.1"); wait != cfg.LoginLockoutDuration {
+		t.Fatalf("expected the IP to be locked, got wait %s", wait)
+	}
+	if wait := limiter.Check("alice", "192.0.2.2"); wait != 0 {
+		t.Fatalf("without backoff a single failure must not block the user, got %s", wait)
+	}
+
+	// 登录成功不会重置 IP 的计数
+	limiter.RecordSuccess("dave")
+	if wait := limiter.Check("dave", "192.0.2.1"); wait == 0 {
+		t.Fatal("a successful login must not clear the IP lockout")
+	}
+	if !limiter.Clear(LockoutIP, "192.0.2.1") {
+		t.Fatal("expected the IP record to be cleared")
+	}
+	if wait := limiter.Check("dave", "192.0.2.1"); wait != 0 {
+		t.Fatalf("expected the lockout to be cleared, got %s", wait)
+	}
+	if limiter.Clear(LockoutUser, "nobody") {
+		t.Error("clearing an unknown user must report false")
+	}
+}
@@ -0,0 +1,227 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/user"
+	"regexp"
+	"strconv"
+	"sync"
+	"time"
+
+	"slurm-dashboard/config"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"golang.org/x/oauth2"
+)
+
+// OIDCBackend 为 OIDC 登录的 Identity.Backend
+const OIDCBackend = "oidc"
+
+// OIDCLoginTTL 为从跳转到身份提供者到完成回调允许的最长时间
+const OIDCLoginTTL = 10 * time.Minute
+
+var (
+	// ErrOIDCDisabled 表示没有配置 oidc_issuer_url
+	ErrOIDCDisabled = errors.New("OIDC login is not enabled")
+	// ErrOIDCStateInvalid 表示回调中的 state 不存在、已使用或已过期
+	ErrOIDCStateInvalid = errors.New("OIDC login state is invalid or expired")
+)
+
+// clusterUsernamePattern 限制从声明映射得到的用户名，该用户名会作为 Slurm 和系统账号使用
+var clusterUsernamePattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,63}$`)
+
+// lookupLocalUser 在本机的 NSS 中查找用户，测试中可以替换
+var lookupLocalUser = user.Lookup
+
+// oidcLogin 是一次尚未完成的登录，以 state 为键保存在内存中
+type oidcLogin struct {
+	verifier  string
+	nonce     string
+	expiresAt time.Time
+}
+
+// OIDCAuthenticator 实现带 PKCE 的 OpenID Connect 授权码流程。
+// 身份提供者的 discovery 文档在第一次登录时获取，oidc_issuer_url 变化后重新获取。
+type OIDCAuthenticator struct {
+	cfg    *config.Provider
+	client *http.Client
+
+	mu       sync.Mutex
+	issuer   string
+	provider *oidc.Provider
+	logins   map[string]oidcLogin
+}
+
+func NewOIDCAuthenticator(cfg *config.Provider) *OIDCAuthenticator {
+	return &OIDCAuthenticator{
+		cfg:    cfg,
+		client: &http.Client{Timeout: 10 * time.Second},
+		logins: make(map[string]oidcLogin),
+	}
+}
+
+// AuthCodeURL 开始一次登录，返回跳转到身份提供者的地址和本次登录的 state
+func (a *OIDCAuthenticator) AuthCodeURL(ctx context.Context) (string, string, error) {
+	conf := a.cfg.Get()
+	if !conf.OIDCEnabled() {
+		return "", "", ErrOIDCDisabled
+	}
+	ctx = oidc.ClientContext(ctx, a.client)
+	provider, err := a.discover(ctx, conf)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomString()
+	if err != nil {
+		return "", "", err
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	now := time.Now()
+	a.mu.Lock()
+	for key, login := range a.logins {
+		if now.After(login.expiresAt) {
+			delete(a.logins, key)
+		}
+	}
+	a.logins[state] = oidcLogin{verifier: verifier, nonce: nonce, expiresAt: now.Add(OIDCLoginTTL)}
+	a.mu.Unlock()
+
+	authURL := oauth2Config(conf, provider).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(verifier))
+	return authURL, state, nil
+}
+
+// Exchange 完成登录：消费 state，用授权码和 PKCE verifier 换取 ID token，校验签名、受众、有效期和 nonce，
+// 并按 oidc_username_claim 和 oidc_username_pattern 得到集群用户名。
+// 用户名无法映射时返回包装了 ErrInvalidCredentials 的错误。
+func (a *OIDCAuthenticator) Exchange(ctx context.Context, code, state string) (*Identity, error) {
+	conf := a.cfg.Get()
+	if !conf.OIDCEnabled() {
+		return nil, ErrOIDCDisabled
+	}
+
+	a.mu.Lock()
+	login, ok := a.logins[state]
+	delete(a.logins, state)
+	a.mu.Unlock()
+	if !ok || time.Now().After(login.expiresAt) {
+		return nil, ErrOIDCStateInvalid
+	}
+
+	ctx = oidc.ClientContext(ctx, a.client)
+	provider, err := a.discover(ctx, conf)
+	if err != nil {
+		return nil, err
+	}
+	token, err := oauth2Config(conf, provider).Exchange(ctx, code, oauth2.VerifierOption(login.verifier))
+	if err != nil {
+		return nil, fmt.Errorf("failed to exchange authorization code: %w", err)
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok {
+		return nil, errors.New("token response does not contain an id_token")
+	}
+	idToken, err := provider.Verifier(&oidc.Config{ClientID: conf.OIDCClientID}).Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify ID token: %w", err)
+	}
+	if idToken.Nonce != login.nonce {
+		return nil, errors.New("ID token nonce does not match the login request")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("failed to decode ID token claims: %w", err)
+	}
+	username, err := oidcUsername(conf, claims)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{Username: username, Backend: OIDCBackend}, nil
+}
+
+// discover 返回 oidc_issuer_url 对应的身份提供者，获取失败时下次登录重试
+func (a *OIDCAuthenticator) discover(ctx context.Context, conf *config.Config) (*oidc.Provider, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.provider != nil && a.issuer == conf.OIDCIssuerURL {
+		return a.provider, nil
+	}
+	provider, err := oidc.NewProvider(ctx, conf.OIDCIssuerURL)
+	if err != nil {
+		return nil, fmt.Errorf("OIDC discovery failed: %w", err)
+	}
+	a.issuer, a.provider = conf.OIDCIssuerURL, provider
+	return provider, nil
+}
+
+func oauth2Config(conf *config.Config, provider *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     conf.OIDCClientID,
+		ClientSecret: conf.OIDCClientSecret,
+		Endpoint:     provider.Endpoint(),
+		RedirectURL:  conf.OIDCRedirectURL,
+		Scopes:       conf.OIDCScopes,
+	}
+}
+
+// oidcUsername 从 ID token 的声明中取得集群用户名
+func oidcUsername(conf *config.Config, claims map[string]any) (string, error) {
+	username, _ := claims[conf.OIDCUsernameClaim].(string)
+	if username == "" {
+		return "", fmt.Errorf("%w: ID token has no %s claim", ErrInvalidCredentials, conf.OIDCUsernameClaim)
+	}
+	if conf.OIDCUsernamePattern != "" {
+		m := regexp.MustCompile(conf.OIDCUsernamePattern).FindStringSubmatch(username)
+		if m == nil {
+			return "", fmt.Errorf("%w: %s claim %q does not match oidc_username_pattern", ErrInvalidCredentials, conf.OIDCUsernameClaim, username)
+		}
+		username = m[1]
+	}
+	if !clusterUsernamePattern.MatchString(username) {
+		return "", fmt.Errorf("%w: %q is not a valid cluster username", ErrInvalidCredentials, username)
+	}
+	if err := checkSystemAccount(conf, username); err != nil {
+		return "", err
+	}
+	return username, nil
+}
+
+// checkSystemAccount 拒绝 root 和本机上 uid 小于 oidc_min_uid 的系统账号。
+// 本机找不到的用户交给之后的目录查询判断是否存在
+func checkSystemAccount(conf *config.Config, username string) error {
+	if username == "root" {
+		return fmt.Errorf("%w: %q is a system account", ErrInvalidCredentials, username)
+	}
+	account, err := lookupLocalUser(username)
+	if err != nil {
+		var unknown user.UnknownUserError
+		if errors.As(err, &unknown) {
+			return nil
+		}
+		return fmt.Errorf("failed to look up local user %q: %w", username, err)
+	}
+	uid, err := strconv.Atoi(account.Uid)
+	if err != nil || uid < conf.OIDCMinUID {
+		return fmt.Errorf("%w: %q is a system account (uid %s)", ErrInvalidCredentials, username, account.Uid)
+	}
+	return nil
+}
+
+func randomString() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
@@ -0,0 +1,119 @@
+//go:build pam
+
+package auth
+
+/*
+#cgo LDFLAGS: -lpam
+#include <security/pam_appl.h>
+#include <stdlib.h>
+#include <string.h>
+
+// dashboard_conv 用 appdata_ptr 中的密码回答所有提示，信息类消息直接忽略
+static int dashboard_conv(int n, const struct pam_message **msg, struct pam_response **resp, void *appdata) {
+	struct pam_response *r;
+	int i;
+
+	if (n <= 0 || n > PAM_MAX_NUM_MSG) {
+		return PAM_CONV_ERR;
+	}
+	r = calloc(n, sizeof(struct pam_response));
+	if (r == NULL) {
+		return PAM_BUF_ERR;
+	}
+	for (i = 0; i < n; i++) {
+		switch (msg[i]->msg_style) {
+		case PAM_PROMPT_ECHO_OFF:
+		case PAM_PROMPT_ECHO_ON:
+			r[i].resp = strdup((const char *)appdata);
+			if (r[i].resp == NULL) {
+				goto fail;
+			}
+			break;
+		case PAM_ERROR_MSG:
+		case PAM_TEXT_INFO:
+			break;
+		default:
+			goto fail;
+		}
+	}
+	*resp = r;
+	return PAM_SUCCESS;
+
+fail:
+	for (i = 0; i < n; i++) {
+		if (r[i].resp != NULL) {
+			memset(r[i].resp, 0, strlen(r[i].resp));
+			free(r[i].resp);
+		}
+	}
+	free(r);
+	return PAM_CONV_ERR;
+}
+
+static int dashboard_pam_authenticate(const char *service, const char *user, const char *password) {
+	struct pam_conv conv = { dashboard_conv, (void *)password };
+	pam_handle_t *pamh = NULL;
+	int ret;
+
+	ret = pam_start(service, user, &conv, &pamh);
+	if (ret != PAM_SUCCESS) {
+		return ret;
+	}
+	ret = pam_authenticate(pamh, PAM_SILENT | PAM_DISALLOW_NULL_AUTHTOK);
+	if (ret == PAM_SUCCESS) {
+		// 检查账号是否过期或被禁止登录
+		ret = pam_acct_mgmt(pamh, PAM_SILENT | PAM_DISALLOW_NULL_AUTHTOK);
+	}
+	pam_end(pamh, ret);
+	return ret;
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+
+	"slurm-dashboard/config"
+)
+
+// PAMSupported 表示当前构建是否包含 PAM 支持
+const PAMSupported = true
+
+// PAMAuthenticator 通过 pam_service 对应的 PAM 服务认证本机账号。
+// 读取 /etc/shadow 等模块通常要求后端以 root 运行。
+type PAMAuthenticator struct {
+	cfg *config.Provider
+}
+
+var _ Authenticator = (*PAMAuthenticator)(nil)
+
+func NewPAMAuthenticator(cfg *config.Provider) *PAMAuthenticator {
+	return &PAMAuthenticator{cfg: cfg}
+}
+
+func (p *PAMAuthenticator) Authenticate(username, password string) (*Identity, error) {
+	if password == "" {
+		return nil, ErrInvalidCredentials
+	}
+
+	service := C.CString(p.cfg.Get().PAMService)
+	defer C.free(unsafe.Pointer(service))
+	user := C.CString(username)
+	defer C.free(unsafe.Pointer(user))
+	secret := C.CString(password)
+	defer func() {
+		C.memset(unsafe.Pointer(secret), 0, C.size_t(len(password)))
+		C.free(unsafe.Pointer(secret))
+	}()
+
+	switch ret := C.dashboard_pam_authenticate(service, user, secret); ret {
+	case C.PAM_SUCCESS:
+		return &Identity{Username: username}, nil
+	case C.PAM_AUTH_ERR, C.PAM_USER_UNKNOWN, C.PAM_MAXTRIES, C.PAM_ACCT_EXPIRED,
+		C.PAM_NEW_AUTHTOK_REQD, C.PAM_PERM_DENIED, C.PAM_CRED_INSUFFICIENT:
+		return nil, ErrInvalidCredentials
+	default:
+		return nil, fmt.Errorf("PAM authentication failed with code %d", int(ret))
+	}
+}
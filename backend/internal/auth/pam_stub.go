@@ -0,0 +1,25 @@
+//go:build !pam
+
+package auth
+
+import (
+	"errors"
+
+	"slurm-dashboard/config"
+)
+
+// PAMSupported 表示当前构建是否包含 PAM 支持，使用 -tags pam 构建（需要 cgo 和 libpam 开发文件）时为 true
+const PAMSupported = false
+
+// PAMAuthenticator 在不包含 PAM 支持的构建中总是返回错误
+type PAMAuthenticator struct{}
+
+var _ Authenticator = (*PAMAuthenticator)(nil)
+
+func NewPAMAuthenticator(cfg *config.Provider) *PAMAuthenticator {
+	return &PAMAuthenticator{}
+}
+
+func (p *PAMAuthenticator) Authenticate(username, password string) (*Identity, error) {
+	return nil, errors.New("PAM support is not compiled in, rebuild with -tags pam")
+}
@@ -1,38 +1,66 @@
 package auth
 
 import (
-	"log"
-	"os/exec"
+	"context"
+	"fmt"
 	"strings"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/services"
+)
+
+// 角色名称，写入 access token 的 role 声明并返回给前端
+const (
+	RoleAdmin              = "admin"
+	RoleOperator           = "operator"
+	RoleAccountCoordinator = "account-coordinator"
+	RoleUser               = "user"
 )
 
-// CheckAdminStatus 检查用户是否为管理员。
-// 管理员条件: 用户组包含 wheel, root, 或 sudo。
-func CheckAdminStatus(username string) string {
-	adminGroups := map[string]struct{}{
-		"wheel": {},
-		"root":  {},
-		"sudo":  {},
+// rolePriority 按权限从高到低排列，用户属于多个角色的组时取第一个匹配的角色
+var rolePriority = []string{RoleAdmin, RoleOperator, RoleAccountCoordinator}
+
+// RoleForGroups 按配置中的 role_groups 将用户所属的组映射为角色
+func RoleForGroups(cfg *config.Config, groups []string) string {
+	member := make(map[string]struct{}, len(groups))
+	for _, group := range groups {
+		member[group] = struct{}{}
+	}
+	for _, role := range rolePriority {
+		for _, group := range cfg.RoleGroups[role] {
+			if _, ok := member[group]; ok {
+				return role
+			}
+		}
+	}
+	return RoleUser
+}
+
+// LookupGroups 按 role_source 查询用户所属的组
+func LookupGroups(ctx context.Context, cfg *config.Config, executor services.Executor, directory Directory, username string) ([]string, error) {
+	if cfg.RoleSource == config.RoleSourceLocal {
+		return LocalGroups(ctx, executor, username)
 	}
-	cmd := exec.Command("groups", username)
-	output, err := cmd.Output()
+	return directory.Groups(username)
+}
+
+// LocalGroups 通过本机的 groups 命令查询用户所属的组，要求后端主机与集群使用相同的 NSS。
+// 用户不存在时返回 ErrInvalidCredentials
+func LocalGroups(ctx context.Context, executor services.Executor, username string) ([]string, error) {
+	result, err := executor.Run(ctx, services.Request{Program: "groups", Args: []string{username}})
 	if err != nil {
-		log.Printf("Could not check groups for user %s: %v. Defaulting to 'user' role.", username, err)
-		return "user"
+		// coreutils 的 groups 对不存在的用户输出 "groups: 'username': no such user" 并以 1 退出
+		if result != nil && result.ExitCode == 1 && strings.Contains(result.Stderr, "no such user") {
+			return nil, fmt.Errorf("%w: %s is not a local user", ErrInvalidCredentials, username)
+		}
+		return nil, err
 	}
 	// groups 命令的输出为 'username : group1 group2 ...'
-	parts := strings.SplitN(string(output), ":", 2)
-	groupsStr := string(output)
+	output := result.Stdout
+	parts := strings.SplitN(output, ":", 2)
+	groupsStr := output
 	if len(parts) == 2 {
 		groupsStr = parts[1]
 	}
-
-	userGroups := strings.Fields(groupsStr)
-	for _, group := range userGroups {
-		if _, isAdminGroup := adminGroups[group]; isAdminGroup {
-			return "admin"
-		}
-	}
-
-	return "user"
+	return strings.Fields(groupsStr), nil
 }
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/testutil/fakecli"
+)
+
+func TestRoleForGroups(t *testing.T) {
+	cfg := config.Default()
+	cfg.RoleGroups = map[string][]string{
+		RoleAdmin:              {"hpc-admins"},
+		RoleOperator:           {"hpc-operators"},
+		RoleAccountCoordinator: {"pi-coordinators", "lab-managers"},
+	}
+
+	tests := []struct {
+		groups []string
+		want   string
+	}{
+		{nil, RoleUser},
+		{[]string{"wheel", "sudo"}, RoleUser},
+		{[]string{"lab-managers"}, RoleAccountCoordinator},
+		{[]string{"pi-coordinators", "hpc-operators"}, RoleOperator},
+		{[]string{"hpc-operators", "hpc-admins"}, RoleAdmin},
+	}
+	for _, tt := range tests {
+		if got := RoleForGroups(cfg, tt.groups); got != tt.want {
+			t.Errorf("RoleForGroups(%v) = %q, want %q", tt.groups, got, tt.want)
+		}
+	}
+}
+
+func TestLocalGroups(t *testing.T) {
+	cli := fakecli.New()
+	cli.Output("groups", "alice : alice hpc-admins\n")
+
+	groups, err := LocalGroups(context.Background(), cli, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(groups) != 2 || groups[0] != "alice" || groups[1] != "hpc-admins" {
+		t.Errorf("unexpected groups %v", groups)
+	}
+
+	cli.Handle("groups", func(username string, args []string) (string, error) {
+		return "groups: '" + args[0] + "': no such user", errors.New("exit status 1")
+	})
+	if _, err := LocalGroups(context.Background(), cli, "mallory"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("expected ErrInvalidCredentials for an unknown user, got %v", err)
+	}
+}
+
+func TestGroupName(t *testing.T) {
+	if got := groupName("cn=hpc-admins,ou=groups,dc=example,dc=com"); got != "hpc-admins" {
+		t.Errorf("unexpected group name %q", got)
+	}
+	if got := groupName("not a dn"); got != "not a dn" {
+		t.Errorf("invalid DNs should be returned unchanged, got %q", got)
+	}
+}
+
+func TestAccountCheckerCachesResults(t *testing.T) {
+	cfg := config.Default()
+	cfg.RoleSource = config.RoleSourceLocal
+	cli := fakecli.New()
+	cli.Output("groups", "alice : alice\n")
+	checker := NewAccountChecker(config.NewStaticProvider(cfg), cli, nil)
+	now := time.Now()
+	checker.now = func() time.Time { return now }
+
+	if err := checker.Check(context.Background(), "alice"); err != nil {
+		t.Fatalf("expected alice to exist, got %v", err)
+	}
+	// 账户被删除后，缓存期内仍然放行，过期后重新查询
+	cli.Handle("groups", func(string, []string) (string, error) {
+		return "", errors.New("groups: 'alice': no such user")
+	})
+	if err := checker.Check(context.Background(), "alice"); err != nil {
+		t.Fatalf("expected the cached result within the interval, got %v", err)
+	}
+	now = now.Add(accountCheckInterval)
+	if err := checker.Check(context.Background(), "alice"); err == nil {
+		t.Fatal("expected the removed account to be rejected after the interval")
+	}
+	if calls := len(cli.Calls()); calls != 2 {
+		t.Errorf("expected two lookups, got %d", calls)
+	}
+}
@@ -0,0 +1,25 @@
+package auth
+
+// API key 的权限范围。使用 API key 的请求只能访问其范围内的接口，并且总是以 user 角色执行；
+// 通过登录取得的 access token 不受范围限制。
+const (
+	// ScopeRead 允许查询集群、分区和作业的状态与日志
+	ScopeRead = "read"
+	// ScopeSubmit 允许提交批处理作业和申请资源，不包括只能在浏览器中使用的交互式分配
+	ScopeSubmit = "submit"
+	// ScopeCancel 允许取消作业
+	ScopeCancel = "cancel"
+)
+
+// Scopes 为所有可授予 API key 的范围
+var Scopes = []string{ScopeRead, ScopeSubmit, ScopeCancel}
+
+// ValidScope 判断 scope 是否为已知的范围
+func ValidScope(scope string) bool {
+	for _, s := range Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
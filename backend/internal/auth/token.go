@@ -1,27 +1,58 @@
 package auth
 
 import (
+	"errors"
 	"slurm-dashboard/config"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
+// ErrMissingTokenID 表示 token 没有 jti，无法被吊销，这类旧 token 不再被接受
+var ErrMissingTokenID = errors.New("token has no jti claim")
+
 type CustomClaims struct {
 	Username string `json:"username"`
+	// Role 为签发时计算出的角色，没有该声明的 token 视为普通用户
+	Role string `json:"role,omitempty"`
+	// FamilyID 为签发该 access token 的登录所对应的 refresh token 家族，登出时据此吊销整个登录
+	FamilyID string `json:"fid,omitempty"`
 	jwt.RegisteredClaims
 }
 
-func GenerateCustomToken(cfg *config.Config, username string) (string, error) {
-	claims := CustomClaims{
+// GenerateCustomToken 签发一个短期 access token，同时返回其声明以便记录 jti 和到期时间
+func GenerateCustomToken(cfg *config.Config, username, role, familyID string) (string, *CustomClaims, error) {
+	claims := &CustomClaims{
 		username,
+		role,
+		familyID,
 		jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(cfg.JWTDuration)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    cfg.JWTIssuer,
 		},
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(cfg.JWTSecretKey))
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(cfg.JWTSecretKey))
+	if err != nil {
+		return "", nil, err
+	}
+	return token, claims, nil
+}
+
+// ParseToken 校验签名和有效期并返回 token 中的声明，不检查吊销状态
+func ParseToken(cfg *config.Config, tokenString string) (*CustomClaims, error) {
+	claims := &CustomClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return []byte(cfg.JWTSecretKey), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
+	if err != nil {
+		return nil, err
+	}
+	if claims.ID == "" {
+		return nil, ErrMissingTokenID
+	}
+	return claims, nil
 }
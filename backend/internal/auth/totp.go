@@ -0,0 +1,99 @@
+package auth
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"image/png"
+	"strings"
+	"time"
+
+	"github.com/pquerna/otp"
+	"github.com/pquerna/otp/totp"
+)
+
+const (
+	// TOTPPeriod 为验证码的时间步长，使用验证器应用通用的 30 秒、6 位、SHA-1
+	TOTPPeriod = 30
+	// totpSkew 为允许的前后时间步数，容忍用户设备的时钟偏差
+	totpSkew = 1
+	// RecoveryCodeCount 为每次生成的恢复码个数
+	RecoveryCodeCount = 10
+	// totpQRCodeSize 为二维码图片的边长（像素）
+	totpQRCodeSize = 200
+)
+
+// recoveryEncoding 为恢复码使用的不带填充的 base32 编码
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// TOTPEnrollment 是绑定验证器应用所需的信息
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	// URL 为 otpauth:// 格式的配置 URI，QRCode 为其二维码的 PNG data URI
+	URL    string `json:"otpauth_url"`
+	QRCode string `json:"qr_code"`
+}
+
+// NewTOTPEnrollment 为 username 生成新的 TOTP 密钥
+func NewTOTPEnrollment(issuer, username string) (*TOTPEnrollment, error) {
+	key, err := totp.Generate(totp.GenerateOpts{
+		Issuer:      issuer,
+		AccountName: username,
+		Period:      TOTPPeriod,
+		Digits:      otp.DigitsSix,
+		Algorithm:   otp.AlgorithmSHA1,
+	})
+	if err != nil {
+		return nil, err
+	}
+	img, err := key.Image(totpQRCodeSize, totpQRCodeSize)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		return nil, err
+	}
+	return &TOTPEnrollment{
+		Secret: key.Secret(),
+		URL:    key.URL(),
+		QRCode: "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}, nil
+}
+
+// VerifyTOTP 在 now 前后 totpSkew 个时间步内校验验证码，返回匹配的时间步。
+// 调用者需要拒绝不大于上次使用的时间步，防止同一个验证码被重放。
+func VerifyTOTP(secret, code string, now time.Time) (int64, bool) {
+	code = strings.TrimSpace(code)
+	if len(code) != otp.DigitsSix.Length() {
+		return 0, false
+	}
+	opts := totp.ValidateOpts{Period: TOTPPeriod, Digits: otp.DigitsSix, Algorithm: otp.AlgorithmSHA1}
+	step := now.Unix() / TOTPPeriod
+	for offset := int64(-totpSkew); offset <= totpSkew; offset++ {
+		expected, err := totp.GenerateCodeCustom(secret, time.Unix((step+offset)*TOTPPeriod, 0), opts)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(code)) == 1 {
+			return step + offset, true
+		}
+	}
+	return 0, false
+}
+
+// NewRecoveryCodes 生成 RecoveryCodeCount 个一次性的恢复码，格式为 xxxxx-xxxxx
+func NewRecoveryCodes() ([]string, error) {
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		raw := make([]byte, 7)
+		if _, err := rand.Read(raw); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(recoveryEncoding.EncodeToString(raw))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+	}
+	return codes, nil
+}
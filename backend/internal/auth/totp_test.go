@@ -0,0 +1,56 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/pquerna/otp/totp"
+)
+
+func TestVerifyTOTP(t *testing.T) {
+	enrollment, err := NewTOTPEnrollment("Slurm Dashboard", "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(enrollment.URL, "otpauth://totp/Slurm%20Dashboard:alice?") || !strings.HasPrefix(enrollment.QRCode, "data:image/png;base64,") {
+		t.Fatalf("unexpected enrollment %q", enrollment.URL)
+	}
+
+	now := time.Unix(1_700_000_000, 0)
+	code, err := totp.GenerateCode(enrollment.Secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	step, ok := VerifyTOTP(enrollment.Secret, code, now)
+	if !ok || step != now.Unix()/TOTPPeriod {
+		t.Fatalf("expected the current code to be accepted, got step %d, %v", step, ok)
+	}
+	// 容忍一个时间步的时钟偏差
+	if _, ok := VerifyTOTP(enrollment.Secret, code, now.Add(TOTPPeriod*time.Second)); !ok {
+		t.Error("a code from the previous step must be accepted")
+	}
+	if _, ok := VerifyTOTP(enrollment.Secret, code, now.Add(3*TOTPPeriod*time.Second)); ok {
+		t.Error("a code from three steps ago must be rejected")
+	}
+	if _, ok := VerifyTOTP(enrollment.Secret, "12345", now); ok {
+		t.Error("a short code must be rejected")
+	}
+}
+
+func TestNewRecoveryCodes(t *testing.T) {
+	codes, err := NewRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[string]bool)
+	for _, code := range codes {
+		if len(code) != 11 || code[5] != '-' || seen[code] {
+			t.Errorf("unexpected recovery code %q", code)
+		}
+		seen[code] = true
+	}
+	if len(codes) != RecoveryCodeCount {
+		t.Errorf("expected %d codes, got %d", RecoveryCodeCount, len(codes))
+	}
+}
@@ -7,7 +7,7 @@ type SlurmPartitionNodesObject struct {
 }
 
 type SlurmPartitionStateObject struct {
-	State []string `json:"state"`
+	State SlurmStringList `json:"state"`
 }
 
 type SlurmPartitionInfo struct {
@@ -19,25 +19,25 @@ type SlurmPartitionInfo struct {
 type SlurmPartitionResponse struct {
 	Partitions []SlurmPartitionInfo `json:"partitions"`
 	Meta       interface{}          `json:"meta"`
-	Errors     []interface{}        `json:"errors"`
-	Warnings   []interface{}        `json:"warnings"`
+	Errors     []SlurmError         `json:"errors"`
+	Warnings   []SlurmWarning       `json:"warnings"`
 }
 
 // --- /nodes 接口的模型 ---
 
 type SlurmNodeInfo struct {
-	Name          string   `json:"name"`
-	State         []string `json:"state"`
-	Partitions    []string `json:"partitions"`
-	TotalCPUs     uint32   `json:"cpus"`
-	AllocatedCPUs uint32   `json:"alloc_cpus"`
-	Gres          string   `json:"gres"`
-	GresUsed      string   `json:"gres_used"`
+	Name          string          `json:"name"`
+	State         SlurmStringList `json:"state"`
+	Partitions    []string        `json:"partitions"`
+	TotalCPUs     uint32          `json:"cpus"`
+	AllocatedCPUs uint32          `json:"alloc_cpus"`
+	Gres          string          `json:"gres"`
+	GresUsed      string          `json:"gres_used"`
 }
 
 type SlurmNodeResponse struct {
 	Nodes    []SlurmNodeInfo `json:"nodes"`
 	Meta     interface{}     `json:"meta"`
-	Errors   []interface{}   `json:"errors"`
-	Warnings []interface{}   `json:"warnings"`
+	Errors   []SlurmError    `json:"errors"`
+	Warnings []SlurmWarning  `json:"warnings"`
 }
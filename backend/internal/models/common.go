@@ -0,0 +1,22 @@
+package models
+
+// SlurmError 对应 slurmrestd 响应中 errors 数组的元素
+type SlurmError struct {
+	Description string `json:"description"`
+	ErrorNumber int32  `json:"error_number"`
+	Error       string `json:"error"`
+	Source      string `json:"source"`
+}
+
+// SlurmWarning 对应 slurmrestd 响应中 warnings 数组的元素
+type SlurmWarning struct {
+	Description string `json:"description"`
+	Source      string `json:"source"`
+}
+
+// SlurmBasicResponse 是 slurmrestd 所有响应共有的部分，也是取消作业等操作的完整响应
+type SlurmBasicResponse struct {
+	Meta     interface{}    `json:"meta"`
+	Errors   []SlurmError   `json:"errors"`
+	Warnings []SlurmWarning `json:"warnings"`
+}
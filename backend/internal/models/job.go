@@ -1,5 +1,10 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+)
+
 // SlurmUint64NoVal 是一个辅助struct，用于解析Slurm中常见的 "set/infinite/number" 格式的数值对象
 type SlurmUint64NoVal struct {
 	Set      bool   `json:"set"`
@@ -7,13 +12,55 @@ type SlurmUint64NoVal struct {
 	Number   uint64 `json:"number"`
 }
 
+// UnmarshalJSON 同时兼容 {set,infinite,number} 对象和纯数字两种编码，
+// 不同版本的 slurmrestd 以及 CLI 的 --json 输出在这一点上并不一致
+func (v *SlurmUint64NoVal) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*v = SlurmUint64NoVal{}
+		return nil
+	}
+	var number uint64
+	if err := json.Unmarshal(data, &number); err == nil {
+		*v = SlurmUint64NoVal{Set: true, Number: number}
+		return nil
+	}
+	type plain SlurmUint64NoVal
+	var obj plain
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("invalid slurm number value %s: %w", string(data), err)
+	}
+	*v = SlurmUint64NoVal(obj)
+	return nil
+}
+
+// SlurmStringList 用于解析既可能是字符串数组、也可能是单个字符串的状态字段（如 job_state）
+type SlurmStringList []string
+
+func (l *SlurmStringList) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		*l = nil
+		return nil
+	}
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*l = SlurmStringList{single}
+		return nil
+	}
+	var list []string
+	if err := json.Unmarshal(data, &list); err != nil {
+		return fmt.Errorf("invalid slurm string list %s: %w", string(data), err)
+	}
+	*l = list
+	return nil
+}
+
 // SlurmJobInfo 定义了我们从Slurm API接收到的单个作业信息
 type SlurmJobInfo struct {
 	JobID        uint32           `json:"job_id"`
 	UserID       uint32           `json:"user_id"`
 	Name         string           `json:"name"`
 	UserName     string           `json:"user_name"`
-	JobState     []string         `json:"job_state"`
+	JobState     SlurmStringList  `json:"job_state"`
 	SubmitTime   SlurmUint64NoVal `json:"submit_time"`
 	StartTime    SlurmUint64NoVal `json:"start_time"`
 	TimeLimit    SlurmUint64NoVal `json:"time_limit"`
@@ -30,6 +77,16 @@ type SlurmJobInfo struct {
 type SlurmJobResponse struct {
 	Jobs     []SlurmJobInfo `json:"jobs"`
 	Meta     interface{}    `json:"meta"`
-	Errors   []interface{}  `json:"errors"`
-	Warnings []interface{}  `json:"warnings"`
+	Errors   []SlurmError   `json:"errors"`
+	Warnings []SlurmWarning `json:"warnings"`
+}
+
+// SlurmJobSubmitResponse 定义了 /job/submit 和 /job/allocate 接口返回的顶层JSON结构
+type SlurmJobSubmitResponse struct {
+	JobID            int32          `json:"job_id"`
+	StepID           string         `json:"step_id"`
+	JobSubmitUserMsg string         `json:"job_submit_user_msg"`
+	Meta             interface{}    `json:"meta"`
+	Errors           []SlurmError   `json:"errors"`
+	Warnings         []SlurmWarning `json:"warnings"`
 }
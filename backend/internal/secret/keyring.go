@@ -0,0 +1,116 @@
+// Package secret 提供由主密钥派生的 AEAD 加密，用于保护需要落盘的凭据。
+package secret
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// sealedVersion 是密文格式的版本前缀，格式为 v1.<key id>.<base64(nonce || ciphertext)>
+const sealedVersion = "v1"
+
+var (
+	// ErrUnknownKey 表示密文使用的密钥已经不在密钥环中
+	ErrUnknownKey = errors.New("secret: sealed with an unknown key")
+	// ErrMalformed 表示密文格式不正确或已被篡改
+	ErrMalformed = errors.New("secret: malformed or tampered value")
+)
+
+type key struct {
+	id   string
+	aead cipher.AEAD
+}
+
+// Keyring 持有由主密钥派生的 AES-256-GCM 密钥。第一个密钥用于加密，
+// 其余密钥只用于解密轮换前写入的数据。
+type Keyring struct {
+	keys []key
+}
+
+// NewKeyring 使用 HKDF-SHA256 从 secrets 派生密钥，purpose 区分不同用途，
+// 使同一主密钥在不同场景下得到互不相关的密钥
+func NewKeyring(secrets []string, purpose string) (*Keyring, error) {
+	if len(secrets) == 0 {
+		return nil, errors.New("secret: at least one master secret is required")
+	}
+	k := &Keyring{}
+	for i, s := range secrets {
+		derived, err := hkdf.Key(sha256.New, []byte(s), nil, "slurm-dashboard "+purpose, 32)
+		if err != nil {
+			return nil, fmt.Errorf("secret: failed to derive key %d: %w", i, err)
+		}
+		block, err := aes.NewCipher(derived)
+		if err != nil {
+			return nil, err
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, err
+		}
+		// key id 只用于选择解密密钥，由派生密钥的哈希截断得到，不泄露密钥本身
+		sum := sha256.Sum256(derived)
+		k.keys = append(k.keys, key{id: hex.EncodeToString(sum[:4]), aead: aead})
+	}
+	return k, nil
+}
+
+// Seal 使用当前密钥加密 plaintext。aad 与密文绑定，解密时必须提供相同的值，
+// 例如用户名，防止密文被挪用到其他用户名下。
+func (k *Keyring) Seal(plaintext, aad []byte) (string, error) {
+	current := k.keys[0]
+	nonce := make([]byte, current.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := current.aead.Seal(nonce, nonce, plaintext, aad)
+	return sealedVersion + "." + current.id + "." + base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+// Open 解密 Seal 生成的密文
+func (k *Keyring) Open(sealed string, aad []byte) ([]byte, error) {
+	id, data, err := parse(sealed)
+	if err != nil {
+		return nil, err
+	}
+	for _, candidate := range k.keys {
+		if candidate.id != id {
+			continue
+		}
+		nonceSize := candidate.aead.NonceSize()
+		if len(data) < nonceSize {
+			return nil, ErrMalformed
+		}
+		plaintext, err := candidate.aead.Open(nil, data[:nonceSize], data[nonceSize:], aad)
+		if err != nil {
+			return nil, ErrMalformed
+		}
+		return plaintext, nil
+	}
+	return nil, ErrUnknownKey
+}
+
+// IsCurrent 报告密文是否由当前密钥加密，为 false 时应重新加密
+func (k *Keyring) IsCurrent(sealed string) bool {
+	id, _, err := parse(sealed)
+	return err == nil && id == k.keys[0].id
+}
+
+func parse(sealed string) (string, []byte, error) {
+	parts := strings.SplitN(sealed, ".", 3)
+	if len(parts) != 3 || parts[0] != sealedVersion {
+		return "", nil, ErrMalformed
+	}
+	data, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", nil, ErrMalformed
+	}
+	return parts[1], data, nil
+}
@@ -0,0 +1,65 @@
+package secret
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const (
+	oldSecret = "old-master-secret-0123456789abcdef"
+	newSecret = "new-master-secret-0123456789abcdef"
+)
+
+func TestKeyringSealOpen(t *testing.T) {
+	k, err := NewKeyring([]string{newSecret}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sealed, err := k.Seal([]byte("slurm-jwt"), []byte("alice"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "slurm-jwt") {
+		t.Fatal("sealed value contains the plaintext")
+	}
+
+	plaintext, err := k.Open(sealed, []byte("alice"))
+	if err != nil || string(plaintext) != "slurm-jwt" {
+		t.Fatalf("unexpected plaintext %q (%v)", plaintext, err)
+	}
+	if _, err := k.Open(sealed, []byte("bob")); !errors.Is(err, ErrMalformed) {
+		t.Fatalf("expected aad mismatch to fail, got %v", err)
+	}
+
+	other, _ := NewKeyring([]string{newSecret}, "other-purpose")
+	if _, err := other.Open(sealed, []byte("alice")); err == nil {
+		t.Fatal("keys derived for another purpose must not open the value")
+	}
+}
+
+func TestKeyringRotation(t *testing.T) {
+	before, _ := NewKeyring([]string{oldSecret}, "test")
+	sealed, err := before.Seal([]byte("slurm-jwt"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, _ := NewKeyring([]string{newSecret, oldSecret}, "test")
+	if rotated.IsCurrent(sealed) {
+		t.Fatal("value sealed with the previous key should need re-encryption")
+	}
+	plaintext, err := rotated.Open(sealed, nil)
+	if err != nil || string(plaintext) != "slurm-jwt" {
+		t.Fatalf("previous key should still decrypt, got %q (%v)", plaintext, err)
+	}
+	resealed, _ := rotated.Seal(plaintext, nil)
+	if !rotated.IsCurrent(resealed) {
+		t.Fatal("value sealed after rotation should use the current key")
+	}
+
+	after, _ := NewKeyring([]string{newSecret}, "test")
+	if _, err := after.Open(sealed, nil); !errors.Is(err, ErrUnknownKey) {
+		t.Fatalf("expected ErrUnknownKey once the old secret is removed, got %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// envMarker 分隔登录 shell 启动时打印的内容（motd、profile 中的 echo 等）与 env 的输出
+const envMarker = "__SLURM_DASHBOARD_ENV__"
+
+// 这些变量描述的是采集环境时那个 shell 本身，不应传给后续命令
+var ignoredLoginEnv = []string{"_", "SHLVL", "PWD", "OLDPWD"}
+
+// userCredential 返回用户的 uid、主组和附加组。
+// 附加组无法解析时只记录日志，命令仍以 uid/gid 执行。
+func userCredential(osUser *user.User) (*syscall.Credential, error) {
+	uid, err := strconv.ParseUint(osUser.Uid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid uid %q for user %s", osUser.Uid, osUser.Username)
+	}
+	gid, err := strconv.ParseUint(osUser.Gid, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid gid %q for user %s", osUser.Gid, osUser.Username)
+	}
+
+	credential := &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid), Groups: []uint32{}}
+	groupIDs, err := osUser.GroupIds()
+	if err != nil {
+		log.Printf("Could not resolve supplementary groups for user %s: %v", osUser.Username, err)
+		return credential, nil
+	}
+	for _, id := range groupIDs {
+		g, err := strconv.ParseUint(id, 10, 32)
+		if err != nil {
+			continue
+		}
+		credential.Groups = append(credential.Groups, uint32(g))
+	}
+	return credential, nil
+}
+
+// defaultEnvironment 是不采集登录环境时使用的最小环境变量
+func defaultEnvironment(osUser *user.User) []string {
+	return []string{
+		"TERM=xterm",
+		fmt.Sprintf("HOME=%s", osUser.HomeDir),
+		fmt.Sprintf("USER=%s", osUser.Username),
+		fmt.Sprintf("LOGNAME=%s", osUser.Username),
+		fmt.Sprintf("PATH=%s", os.Getenv("PATH")),
+	}
+}
+
+// userEnvironment 返回以 osUser 身份执行命令时的环境变量。
+// 启用 command_login_environment 时使用缓存的登录 shell 环境，首次使用时采集；
+// 采集失败则退回最小环境，下次执行时重试。
+func (e *LocalExecutor) userEnvironment(ctx context.Context, osUser *user.User, credential *syscall.Credential) []string {
+	base := defaultEnvironment(osUser)
+	conf := e.cfg.Get()
+	if !conf.CommandLoginEnvironment {
+		return base
+	}
+
+	e.envMu.Lock()
+	env, ok := e.loginEnv[osUser.Username]
+	e.envMu.Unlock()
+	if ok {
+		return append([]string(nil), env...)
+	}
+
+	env, err := captureLoginEnvironment(ctx, osUser, credential, base, conf.CommandTimeout, conf.CommandMaxOutputBytes)
+	if err != nil {
+		log.Printf("Failed to capture login environment for user %s, using minimal environment: %v", osUser.Username, err)
+		return base
+	}
+
+	e.envMu.Lock()
+	e.loginEnv[osUser.Username] = env
+	e.envMu.Unlock()
+	log.Printf("Captured login environment for user %s (%d variables)", osUser.Username, len(env))
+	return append([]string(nil), env...)
+}
+
+func (e *LocalExecutor) BeginSession(username string) {
+	e.envMu.Lock()
+	defer e.envMu.Unlock()
+	delete(e.loginEnv, username)
+}
+
+// captureLoginEnvironment 以用户身份启动其登录 shell 并读取 env 的输出，
+// 与 SSH 登录一样会加载 /etc/profile、~/.bash_profile 等文件
+func captureLoginEnvironment(ctx context.Context, osUser *user.User, credential *syscall.Credential, base []string, timeout time.Duration, maxOutput int) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	shell := loginShell(ctx, osUser.Username)
+	cmd := exec.CommandContext(ctx, shell, "-c", "printf '\\0"+envMarker+"\\0'; env -0")
+	// argv[0] 以 - 开头表示登录 shell，对 bash、zsh、tcsh 等都适用
+	cmd.Args[0] = "-" + filepath.Base(shell)
+	cmd.Dir = osUser.HomeDir
+	cmd.Env = base
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential, Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	stdout := &limitedBuffer{limit: maxOutput}
+	cmd.Stdout = stdout
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("login shell %s failed: %w", shell, err)
+	}
+	if stdout.truncated {
+		return nil, errors.New("login environment exceeded the configured size limit")
+	}
+	return parseLoginEnvironment(stdout.buf.Bytes())
+}
+
+// parseLoginEnvironment 解析 envMarker 之后以 NUL 分隔的 KEY=VALUE 列表
+func parseLoginEnvironment(output []byte) ([]string, error) {
+	marker := []byte("\x00" + envMarker + "\x00")
+	idx := bytes.Index(output, marker)
+	if idx < 0 {
+		return nil, errors.New("login shell did not print the environment")
+	}
+
+	var env []string
+	for _, entry := range strings.Split(string(output[idx+len(marker):]), "\x00") {
+		key, _, ok := strings.Cut(entry, "=")
+		if !ok || key == "" || isIgnoredLoginEnv(key) {
+			continue
+		}
+		env = append(env, entry)
+	}
+	return env, nil
+}
+
+func isIgnoredLoginEnv(key string) bool {
+	for _, ignored := range ignoredLoginEnv {
+		if key == ignored {
+			return true
+		}
+	}
+	return false
+}
+
+// loginShell 通过 NSS 查询用户的登录 shell，以便同时支持本地和 LDAP 用户，查询失败时使用 /bin/bash
+func loginShell(ctx context.Context, username string) string {
+	out, err := exec.CommandContext(ctx, "getent", "passwd", username).Output()
+	if err == nil {
+		fields := strings.Split(strings.TrimSpace(string(out)), ":")
+		if len(fields) == 7 && fields[6] != "" {
+			return fields[6]
+		}
+	}
+	return "/bin/bash"
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"context"
+	"os"
+	"os/user"
+	"strings"
+	"testing"
+	"time"
+
+	"slurm-dashboard/config"
+)
+
+func TestParseLoginEnvironment(t *testing.T) {
+	output := "Welcome to the cluster!\n\x00" + envMarker + "\x00" +
+		"PATH=/opt/modules/bin:/usr/bin\x00MODULEPATH=/opt/modules\x00SHLVL=1\x00_=/usr/bin/env\x00MULTI=a\nb\x00"
+
+	env, err := parseLoginEnvironment([]byte(output))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"PATH=/opt/modules/bin:/usr/bin", "MODULEPATH=/opt/modules", "MULTI=a\nb"}
+	if strings.Join(env, "|") != strings.Join(want, "|") {
+		t.Fatalf("expected %q, got %q", want, env)
+	}
+
+	if _, err := parseLoginEnvironment([]byte("no marker here")); err == nil {
+		t.Fatal("expected error without marker")
+	}
+}
+
+func TestUserCredentialIncludesSupplementaryGroups(t *testing.T) {
+	current, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+	groupIDs, err := current.GroupIds()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	credential, err := userCredential(current)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(credential.Groups) != len(groupIDs) {
+		t.Fatalf("expected %d groups, got %v", len(groupIDs), credential.Groups)
+	}
+}
+
+func TestLocalExecutorLoginEnvironment(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("switching credentials requires root")
+	}
+	current, err := user.Current()
+	if err != nil {
+		t.Skip(err)
+	}
+
+	cfg := config.Default()
+	cfg.CommandTimeout = 5 * time.Second
+	cfg.CommandLoginEnvironment = true
+	e := NewLocalExecutor(config.NewStaticProvider(cfg))
+
+	result, err := e.Run(context.Background(), Request{Username: current.Username, Program: "printenv", Args: []string{"HOME"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(result.Stdout) != current.HomeDir {
+		t.Fatalf("expected HOME=%s, got %q", current.HomeDir, result.Stdout)
+	}
+
+	e.envMu.Lock()
+	_, cached := e.loginEnv[current.Username]
+	e.envMu.Unlock()
+	if !cached {
+		t.Fatal("expected login environment to be cached")
+	}
+
+	e.BeginSession(current.Username)
+	e.envMu.Lock()
+	_, cached = e.loginEnv[current.Username]
+	e.envMu.Unlock()
+	if cached {
+		t.Fatal("expected BeginSession to drop the cached environment")
+	}
+}
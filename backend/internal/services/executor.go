@@ -1,40 +1,222 @@
 package services
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/user"
-	"strconv"
+	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
+	"time"
+
+	"slurm-dashboard/config"
+
+	"github.com/creack/pty"
 )
 
-func ExecuteCommandAsUser(username string, command string) (string, error) {
-	osUser, err := user.Lookup(username)
-	if err != nil {
-		return "", fmt.Errorf("failed to lookup user %s: %w", username, err)
+// ErrTimeout 表示命令在截止时间前没有结束而被终止
+var ErrTimeout = errors.New("command timed out")
+
+// Request 描述一次一次性命令的执行。
+// 命令以 Program 加参数列表的形式直接执行，不经过 shell，参数中的特殊字符不会被解释。
+type Request struct {
+	// Username 为执行命令的系统用户，为空时以后端进程自身的身份执行
+	Username string
+	// Program 为程序名或路径，不含路径时从 PATH 中查找
+	Program string
+	Args    []string
+	// Env 在 Username 为空时作为进程的完整环境变量，否则追加在用户默认环境之后
+	Env []string
+	// Stdin 为 nil 时进程的标准输入为空
+	Stdin io.Reader
+	// Timeout 为 0 时使用配置中的 command_timeout
+	Timeout time.Duration
+	// MaxOutputBytes 限制 stdout 和 stderr 各自保留的字节数，为 0 时使用配置中的 command_max_output_bytes
+	MaxOutputBytes int
+}
+
+// String 返回命令行的可读形式，仅用于日志和错误信息
+func (r Request) String() string {
+	return strings.Join(append([]string{r.Program}, r.Args...), " ")
+}
+
+// Result 是命令执行的结构化结果
+type Result struct {
+	Stdout    string
+	Stderr    string
+	ExitCode  int
+	Duration  time.Duration
+	Truncated bool
+}
+
+// Output 返回 stdout 与 stderr 拼接后的内容，用于日志和错误信息
+func (r *Result) Output() string {
+	if r == nil {
+		return ""
 	}
-	uid, _ := strconv.Atoi(osUser.Uid)
-	gid, _ := strconv.Atoi(osUser.Gid)
+	return strings.TrimSpace(r.Stdout + "\n" + r.Stderr)
+}
 
-	cmd := exec.Command("bash", "-c", command)
-	cmd.Dir = osUser.HomeDir
+// PTYRequest 描述一个在伪终端中运行的长期进程，例如 salloc 或登录 shell
+type PTYRequest struct {
+	// Username 为执行进程的系统用户，为空时以后端进程自身的身份执行
+	Username string
+	Program  string
+	Args     []string
+	// Env 在 Username 为空时作为进程的完整环境变量，否则追加在用户默认环境之后
+	Env []string
+}
 
-	cmd.SysProcAttr = &syscall.SysProcAttr{}
-	cmd.SysProcAttr.Credential = &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}
+// Executor 是所有外部命令的统一执行入口，测试中可以替换为假的实现
+type Executor interface {
+	// Run 执行一条命令并等待其结束。命令以非零状态退出时同时返回 Result 和错误。
+	Run(ctx context.Context, req Request) (*Result, error)
+	// StartPTY 在伪终端中启动一个进程，调用方负责等待进程结束并关闭伪终端
+	StartPTY(req PTYRequest) (*exec.Cmd, *os.File, error)
+	// BeginSession 在用户登录时调用，丢弃该用户此前缓存的登录环境
+	BeginSession(username string)
+}
+
+// LocalExecutor 在本机以目标用户的 uid/gid 及附加组执行命令
+type LocalExecutor struct {
+	cfg     *config.Provider
+	metrics *Metrics
+
+	envMu    sync.Mutex
+	loginEnv map[string][]string
+}
+
+func NewLocalExecutor(cfg *config.Provider) *LocalExecutor {
+	return &LocalExecutor{cfg: cfg, metrics: NewMetrics(), loginEnv: make(map[string][]string)}
+}
+
+// Metrics 返回按程序名统计的执行指标
+func (e *LocalExecutor) Metrics() map[string]CommandStats {
+	return e.metrics.Snapshot()
+}
 
-	cmd.Env = []string{
-		"TERM=xterm",
-		fmt.Sprintf("HOME=%s", osUser.HomeDir),
-		fmt.Sprintf("USER=%s", username),
-		fmt.Sprintf("LOGNAME=%s", username),
-		fmt.Sprintf("PATH=%s", os.Getenv("PATH")),
+func (e *LocalExecutor) Run(ctx context.Context, req Request) (*Result, error) {
+	conf := e.cfg.Get()
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = conf.CommandTimeout
+	}
+	maxOutput := req.MaxOutputBytes
+	if maxOutput <= 0 {
+		maxOutput = conf.CommandMaxOutputBytes
 	}
 
-	output, err := cmd.CombinedOutput()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, req.Program, req.Args...)
+	if err := e.applyUser(ctx, cmd, req.Username); err != nil {
+		return nil, err
+	}
+	if len(req.Env) > 0 {
+		cmd.Env = append(cmd.Env, req.Env...)
+	}
+	cmd.Stdin = req.Stdin
+	// 在独立的进程组中运行，超时后连同命令启动的子进程一起终止
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+
+	stdout := &limitedBuffer{limit: maxOutput}
+	stderr := &limitedBuffer{limit: maxOutput}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	runErr := cmd.Run()
+	result := &Result{
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		ExitCode:  cmd.ProcessState.ExitCode(),
+		Duration:  time.Since(start),
+		Truncated: stdout.truncated || stderr.truncated,
+	}
+
+	timedOut := errors.Is(ctx.Err(), context.DeadlineExceeded)
+	e.metrics.record(filepath.Base(req.Program), result.Duration, runErr != nil, timedOut)
+
+	switch {
+	case timedOut:
+		return result, fmt.Errorf("%w after %s: %s", ErrTimeout, timeout, req)
+	case runErr != nil && req.Username == "":
+		return result, fmt.Errorf("failed to execute command: %w", runErr)
+	case runErr != nil:
+		return result, fmt.Errorf("failed to execute command as user %s: %w", req.Username, runErr)
+	}
+	return result, nil
+}
+
+func (e *LocalExecutor) StartPTY(req PTYRequest) (*exec.Cmd, *os.File, error) {
+	cmd := exec.Command(req.Program, req.Args...)
+	if err := e.applyUser(context.Background(), cmd, req.Username); err != nil {
+		return nil, nil, err
+	}
+	if len(req.Env) > 0 {
+		cmd.Env = append(cmd.Env, req.Env...)
+	}
+
+	ptmx, err := pty.Start(cmd)
+	e.metrics.record(filepath.Base(req.Program), 0, err != nil, false)
 	if err != nil {
-		return string(output), fmt.Errorf("failed to execute command as user %s: %w", username, err)
+		return nil, nil, err
+	}
+	return cmd, ptmx, nil
+}
+
+// applyUser 将命令的执行身份、工作目录和环境变量设置为 username 对应的系统用户
+func (e *LocalExecutor) applyUser(ctx context.Context, cmd *exec.Cmd, username string) error {
+	if username == "" {
+		return nil
 	}
+	osUser, err := user.Lookup(username)
+	if err != nil {
+		return fmt.Errorf("failed to lookup user %s: %w", username, err)
+	}
+	credential, err := userCredential(osUser)
+	if err != nil {
+		return err
+	}
+
+	cmd.Dir = osUser.HomeDir
+	cmd.SysProcAttr = &syscall.SysProcAttr{Credential: credential}
+	cmd.Env = e.userEnvironment(ctx, osUser, credential)
+	return nil
+}
+
+// limitedBuffer 只保留前 limit 个字节，超出部分被丢弃并标记为截断
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.limit - b.buf.Len(); remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
 
-	return string(output), nil
+func (b *limitedBuffer) String() string {
+	return b.buf.String()
 }
@@ -0,0 +1,101 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"slurm-dashboard/config"
+)
+
+func newTestExecutor(t *testing.T) *LocalExecutor {
+	t.Helper()
+	cfg := config.Default()
+	cfg.CommandTimeout = 5 * time.Second
+	cfg.CommandMaxOutputBytes = 1024
+	return NewLocalExecutor(config.NewStaticProvider(cfg))
+}
+
+func TestLocalExecutorRun(t *testing.T) {
+	e := newTestExecutor(t)
+
+	result, err := e.Run(context.Background(), Request{Program: "bash", Args: []string{"-c", "echo out; echo err >&2; exit 3"}})
+	if err == nil {
+		t.Fatal("expected error for non-zero exit")
+	}
+	if result.Stdout != "out\n" || result.Stderr != "err\n" || result.ExitCode != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	stats := e.Metrics()["bash"]
+	if stats.Count != 1 || stats.Failures != 1 || stats.Timeouts != 0 {
+		t.Fatalf("unexpected metrics %+v", stats)
+	}
+}
+
+func TestLocalExecutorTimeoutKillsProcessGroup(t *testing.T) {
+	e := newTestExecutor(t)
+
+	start := time.Now()
+	_, err := e.Run(context.Background(), Request{Program: "bash", Args: []string{"-c", "sleep 30 & sleep 30"}, Timeout: 200 * time.Millisecond})
+	if !errors.Is(err, ErrTimeout) {
+		t.Fatalf("expected ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Fatalf("command was not killed in time: %s", elapsed)
+	}
+	if stats := e.Metrics()["bash"]; stats.Timeouts != 1 {
+		t.Fatalf("unexpected metrics %+v", stats)
+	}
+}
+
+func TestLocalExecutorTruncatesOutput(t *testing.T) {
+	e := newTestExecutor(t)
+
+	result, err := e.Run(context.Background(), Request{Program: "head", Args: []string{"-c", "4096", "/dev/zero"}, MaxOutputBytes: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.Truncated || len(result.Stdout) != 100 {
+		t.Fatalf("expected 100 truncated bytes, got %d (truncated=%v)", len(result.Stdout), result.Truncated)
+	}
+}
+
+func TestLocalExecutorPassesArgumentsLiterally(t *testing.T) {
+	e := newTestExecutor(t)
+
+	arg := "user=alice; touch /tmp/pwned $(id)"
+	result, err := e.Run(context.Background(), Request{
+		Program: "cat",
+		Args:    []string{"-"},
+		Stdin:   strings.NewReader(arg),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Stdout != arg {
+		t.Fatalf("expected stdin to be passed through, got %q", result.Stdout)
+	}
+
+	result, err = e.Run(context.Background(), Request{
+		Program: "printenv",
+		Args:    []string{"DASHBOARD_TEST"},
+		Env:     []string{"DASHBOARD_TEST=" + arg},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(result.Stdout) != arg {
+		t.Fatalf("expected env to be passed through, got %q", result.Stdout)
+	}
+
+	result, err = e.Run(context.Background(), Request{Program: "echo", Args: []string{arg}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(result.Stdout) != arg {
+		t.Fatalf("expected argument to be passed literally, got %q", result.Stdout)
+	}
+}
@@ -0,0 +1,57 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// CommandStats 是单个程序的累计执行指标
+type CommandStats struct {
+	Count         int64         `json:"count"`
+	Failures      int64         `json:"failures"`
+	Timeouts      int64         `json:"timeouts"`
+	TotalDuration time.Duration `json:"total_duration_ns"`
+	MaxDuration   time.Duration `json:"max_duration_ns"`
+}
+
+// Metrics 按程序名汇总命令执行情况
+type Metrics struct {
+	mu    sync.Mutex
+	stats map[string]*CommandStats
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{stats: make(map[string]*CommandStats)}
+}
+
+func (m *Metrics) record(program string, d time.Duration, failed, timedOut bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	s, ok := m.stats[program]
+	if !ok {
+		s = &CommandStats{}
+		m.stats[program] = s
+	}
+	s.Count++
+	s.TotalDuration += d
+	if d > s.MaxDuration {
+		s.MaxDuration = d
+	}
+	if failed {
+		s.Failures++
+	}
+	if timedOut {
+		s.Timeouts++
+	}
+}
+
+// Snapshot 返回当前指标的副本
+func (m *Metrics) Snapshot() map[string]CommandStats {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	snapshot := make(map[string]CommandStats, len(m.stats))
+	for program, s := range m.stats {
+		snapshot[program] = *s
+	}
+	return snapshot
+}
@@ -0,0 +1,147 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/store"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// renewCheckInterval 为后台检查 token 到期时间的间隔
+const renewCheckInterval = time.Minute
+
+// TokenRenewer 在 Slurm token 到期前为仍在使用 dashboard 的用户重新执行 scontrol token，
+// 并删除长期不活动用户的 token
+type TokenRenewer struct {
+	cfg      *config.Provider
+	executor Executor
+	tokens   *store.TokenStore
+
+	// flights 按用户名合并并发的签发，同一用户同时只执行一次 scontrol token，
+	// 不同用户之间互不等待
+	flights singleflight.Group
+}
+
+func NewTokenRenewer(cfg *config.Provider, executor Executor, tokens *store.TokenStore) *TokenRenewer {
+	return &TokenRenewer{cfg: cfg, executor: executor, tokens: tokens}
+}
+
+// Renew 在 slurmrestd 拒绝 staleToken 后为用户签发新 token。
+// 如果其他请求已经完成了续期，直接返回新的 token。
+func (r *TokenRenewer) Renew(ctx context.Context, username, staleToken string) (string, error) {
+	if current, ok := r.tokens.Get(username); ok && current != staleToken {
+		return current, nil
+	}
+	return r.issue(ctx, username, func(ctx context.Context) (string, error) {
+		// 排队期间可能已经有其他请求完成了续期
+		if current, ok := r.tokens.Get(username); ok && current != staleToken {
+			return current, nil
+		}
+		return r.renew(ctx, username)
+	})
+}
+
+// Ensure 返回用户的 Slurm token，没有时为其签发一个。
+// 用于不经过登录的请求，例如使用 API key 的脚本在用户的 token 因不活动被清理之后访问。
+func (r *TokenRenewer) Ensure(ctx context.Context, username string) (string, error) {
+	if current, ok := r.tokens.Get(username); ok {
+		return current, nil
+	}
+	return r.issue(ctx, username, func(ctx context.Context) (string, error) {
+		if current, ok := r.tokens.Get(username); ok {
+			return current, nil
+		}
+		conf := r.cfg.Get()
+		token, err := GetSlurmToken(ctx, r.executor, username, conf.SlurmTokenLifespanSec)
+		if err != nil {
+			return "", err
+		}
+		if err := r.tokens.Set(username, token, time.Now().Add(conf.SlurmTokenLifespan())); err != nil {
+			return "", err
+		}
+		log.Printf("Issued Slurm token for user %s without an interactive login", username)
+		return token, nil
+	})
+}
+
+// issue 执行 fn，同一用户并发的调用共享同一次执行的结果。
+// 发起者取消请求不会中断其他调用方正在等待的签发，命令本身的超时由 Executor 控制
+func (r *TokenRenewer) issue(ctx context.Context, username string, fn func(ctx context.Context) (string, error)) (string, error) {
+	result := r.flights.DoChan(username, func() (any, error) {
+		return fn(context.WithoutCancel(ctx))
+	})
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return "", res.Err
+		}
+		return res.Val.(string), nil
+	}
+}
+
+// Run 定期续期即将到期的 token，直到 ctx 结束
+func (r *TokenRenewer) Run(ctx context.Context) {
+	ticker := time.NewTicker(renewCheckInterval)
+	defer ticker.Stop()
+	for {
+		r.RenewDue(ctx, time.Now())
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RenewDue 续期在 slurm_token_renew_before 内到期的 token，
+// 删除超过 slurm_token_idle_timeout 没有活动的用户的 token
+func (r *TokenRenewer) RenewDue(ctx context.Context, now time.Time) {
+	conf := r.cfg.Get()
+	infos, err := r.tokens.List()
+	if err != nil {
+		log.Printf("Failed to list Slurm tokens for renewal: %v", err)
+		return
+	}
+
+	for _, info := range infos {
+		switch {
+		case now.Sub(info.LastUsedAt) > conf.SlurmTokenIdleTimeout:
+			if err := r.tokens.Delete(info.Username); err != nil {
+				log.Printf("Failed to drop Slurm token for inactive user %s: %v", info.Username, err)
+				continue
+			}
+			log.Printf("Dropped Slurm token for user %s, inactive since %s", info.Username, info.LastUsedAt.Format(time.RFC3339))
+		case info.ExpiresAt.Sub(now) < conf.SlurmTokenRenewBefore:
+			username := info.Username
+			_, err := r.issue(ctx, username, func(ctx context.Context) (string, error) {
+				return r.renew(ctx, username)
+			})
+			if err != nil {
+				log.Printf("Failed to renew Slurm token for user %s (expires %s): %v", info.Username, info.ExpiresAt.Format(time.RFC3339), err)
+			}
+		}
+	}
+}
+
+func (r *TokenRenewer) renew(ctx context.Context, username string) (string, error) {
+	conf := r.cfg.Get()
+	token, err := GetSlurmToken(ctx, r.executor, username, conf.SlurmTokenLifespanSec)
+	if err != nil {
+		return "", err
+	}
+	if err := r.tokens.Renew(username, token, time.Now().Add(conf.SlurmTokenLifespan())); errors.Is(err, store.ErrNotFound) {
+		return "", fmt.Errorf("user logged out during the renewal: %w", err)
+	} else if err != nil {
+		return "", err
+	}
+	log.Printf("Renewed Slurm token for user %s", username)
+	return token, nil
+}
@@ -0,0 +1,145 @@
+package services_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/secret"
+	"slurm-dashboard/internal/services"
+	"slurm-dashboard/internal/store"
+	"slurm-dashboard/internal/testutil/fakecli"
+)
+
+func newTokenStore(t *testing.T) *store.TokenStore {
+	t.Helper()
+	db := store.NewMemoryStore()
+	if err := store.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	keyring, err := secret.NewKeyring([]string{"test-token-secret-0123456789abcdef"}, "slurm-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store.NewTokenStore(db, keyring)
+}
+
+func TestTokenRenewerRenewDue(t *testing.T) {
+	cfg := config.Default()
+	cfg.SlurmTokenRenewBefore = time.Hour
+	cfg.SlurmTokenIdleTimeout = 24 * time.Hour
+
+	tokens := newTokenStore(t)
+	cli := fakecli.New()
+	cli.Handle("scontrol", func(_ string, args []string) (string, error) {
+		return "SLURM_JWT=renewed-" + args[1] + "\n", nil
+	})
+	renewer := services.NewTokenRenewer(config.NewStaticProvider(cfg), cli, tokens)
+
+	now := time.Now()
+	if err := tokens.Set("expiring", "old", now.Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := tokens.Set("fresh", "old", now.Add(20*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	renewer.RenewDue(context.Background(), now)
+	if token, _ := tokens.Get("expiring"); token != "renewed-username=expiring" {
+		t.Errorf("expected expiring token to be renewed, got %q", token)
+	}
+	if token, _ := tokens.Get("fresh"); token != "old" {
+		t.Errorf("fresh token should not be renewed, got %q", token)
+	}
+
+	// 两天后再检查，用户已超过不活动时限
+	renewer.RenewDue(context.Background(), now.Add(48*time.Hour))
+	if _, ok := tokens.Get("fresh"); ok {
+		t.Error("token of an inactive user should be dropped")
+	}
+}
+
+func TestTokenRenewerDiscardsRenewalAfterLogout(t *testing.T) {
+	cfg := config.Default()
+	cfg.SlurmTokenRenewBefore = time.Hour
+	tokens := newTokenStore(t)
+	cli := fakecli.New()
+	cli.Handle("scontrol", func(_ string, args []string) (string, error) {
+		// 用户在 scontrol token 执行期间登出
+		if err := tokens.Delete("alice"); err != nil {
+			t.Error(err)
+		}
+		return "SLURM_JWT=renewed\n", nil
+	})
+	renewer := services.NewTokenRenewer(config.NewStaticProvider(cfg), cli, tokens)
+
+	if err := tokens.Set("alice", "old", time.Now().Add(30*time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	renewer.RenewDue(context.Background(), time.Now())
+	if token, ok := tokens.Get("alice"); ok {
+		t.Errorf("a renewal must not restore the token of a user who logged out, got %q", token)
+	}
+}
+
+func TestTokenRenewerReusesConcurrentRenewal(t *testing.T) {
+	tokens := newTokenStore(t)
+	cli := fakecli.New()
+	cli.Output("scontrol", "SLURM_JWT=minted\n")
+	renewer := services.NewTokenRenewer(config.NewStaticProvider(config.Default()), cli, tokens)
+
+	if err := tokens.Set("alice", "already-renewed", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	token, err := renewer.Renew(context.Background(), "alice", "stale")
+	if err != nil || token != "already-renewed" {
+		t.Fatalf("expected the newer stored token, got %q (%v)", token, err)
+	}
+	if calls := cli.Calls(); len(calls) != 0 {
+		t.Fatalf("no new token should be minted, got %+v", calls)
+	}
+}
+
+func TestTokenRenewerIssuesPerUser(t *testing.T) {
+	tokens := newTokenStore(t)
+	cli := fakecli.New()
+	release := make(chan struct{})
+	cli.Handle("scontrol", func(_ string, args []string) (string, error) {
+		if args[1] == "username=alice" {
+			<-release
+		}
+		return "SLURM_JWT=minted-" + strings.TrimPrefix(args[1], "username=") + "\n", nil
+	})
+	renewer := services.NewTokenRenewer(config.NewStaticProvider(config.Default()), cli, tokens)
+
+	// alice 的 scontrol 卡住时，她的并发请求共享同一次签发，其他用户不受影响
+	results := make(chan string, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			token, _ := renewer.Ensure(context.Background(), "alice")
+			results <- token
+		}()
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if token, err := renewer.Ensure(ctx, "bob"); err != nil || token != "minted-bob" {
+		t.Fatalf("expected bob's token to be issued while alice's is pending, got %q (%v)", token, err)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if token := <-results; token != "minted-alice" {
+			t.Errorf("expected alice's token, got %q", token)
+		}
+	}
+	aliceCalls := 0
+	for _, call := range cli.Calls() {
+		if strings.Contains(call.String(), "username=alice") {
+			aliceCalls++
+		}
+	}
+	if aliceCalls != 1 {
+		t.Errorf("expected a single scontrol token for alice, got %d", aliceCalls)
+	}
+}
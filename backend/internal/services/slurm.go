@@ -1,20 +1,22 @@
 package services
 
 import (
+	"context"
 	"fmt"
-	"os/exec"
 	"strings"
 )
 
 // GetSlurmToken 为指定用户生成token
-func GetSlurmToken(username, lifespanSec string) (string, error) {
-	cmd := exec.Command("scontrol", "token", fmt.Sprintf("username=%s", username), fmt.Sprintf("lifespan=%s", lifespanSec))
-	output, err := cmd.CombinedOutput()
+func GetSlurmToken(ctx context.Context, executor Executor, username, lifespanSec string) (string, error) {
+	result, err := executor.Run(ctx, Request{
+		Program: "scontrol",
+		Args:    []string{"token", "username=" + username, "lifespan=" + lifespanSec},
+	})
 	if err != nil {
-		return "", fmt.Errorf("scontrol command failed: %w, output: %s", err, string(output))
+		return "", fmt.Errorf("scontrol command failed: %w, output: %s", err, result.Output())
 	}
 
-	outputStr := strings.TrimSpace(string(output))
+	outputStr := strings.TrimSpace(result.Stdout)
 	if strings.HasPrefix(outputStr, "SLURM_JWT=") {
 		return strings.TrimPrefix(outputStr, "SLURM_JWT="), nil
 	}
@@ -0,0 +1,113 @@
+package slurm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+
+	"slurm-dashboard/internal/models"
+	"slurm-dashboard/internal/services"
+)
+
+// ErrNotSupported 表示当前数据源不支持该操作
+var ErrNotSupported = errors.New("operation is not supported by this slurm data source")
+
+// jobIDPattern 匹配普通作业 ID、数组作业（123_4）和异构作业（123+1）
+var jobIDPattern = regexp.MustCompile(`^[0-9]+([_+][0-9]+)?$`)
+
+// CLISource 通过以用户身份执行 squeue/scontrol/scancel 获取数据，
+// 这些命令的 --json 输出与 slurmrestd 使用同一套 data_parser，可以直接解码为相同的模型
+type CLISource struct {
+	executor services.Executor
+}
+
+func NewCLISource(executor services.Executor) *CLISource {
+	return &CLISource{executor: executor}
+}
+
+func (s *CLISource) ListJobs(ctx context.Context, creds Credentials) (*models.SlurmJobResponse, error) {
+	var resp models.SlurmJobResponse
+	if err := s.runJSON(ctx, creds.Username, &resp, "squeue", "--json"); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (s *CLISource) GetJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmJobResponse, error) {
+	if !jobIDPattern.MatchString(jobID) {
+		return nil, fmt.Errorf("invalid job id %q", jobID)
+	}
+	var resp models.SlurmJobResponse
+	if err := s.runJSON(ctx, creds.Username, &resp, "scontrol", "show", "job", jobID, "--json"); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (s *CLISource) CancelJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmBasicResponse, error) {
+	if !jobIDPattern.MatchString(jobID) {
+		return nil, fmt.Errorf("invalid job id %q", jobID)
+	}
+	result, err := s.executor.Run(ctx, services.Request{Username: creds.Username, Program: "scancel", Args: []string{jobID}})
+	if err != nil {
+		return nil, fmt.Errorf("scancel failed: %w, output: %s", err, result.Output())
+	}
+	return &models.SlurmBasicResponse{}, nil
+}
+
+// SubmitJob 需要 slurmrestd 的 job_submit_req 结构，命令行方式下请使用 sbatch 接口
+func (s *CLISource) SubmitJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *CLISource) AllocateJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error) {
+	return nil, ErrNotSupported
+}
+
+func (s *CLISource) ListNodes(ctx context.Context, creds Credentials) (*models.SlurmNodeResponse, error) {
+	var resp models.SlurmNodeResponse
+	if err := s.runJSON(ctx, creds.Username, &resp, "scontrol", "show", "nodes", "--json"); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (s *CLISource) ListPartitions(ctx context.Context, creds Credentials) (*models.SlurmPartitionResponse, error) {
+	var resp models.SlurmPartitionResponse
+	if err := s.runJSON(ctx, creds.Username, &resp, "scontrol", "show", "partitions", "--json"); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+func (s *CLISource) runJSON(ctx context.Context, username string, out interface{}, program string, args ...string) error {
+	req := services.Request{Username: username, Program: program, Args: args}
+	command := req.String()
+	result, err := s.executor.Run(ctx, req)
+	if err != nil {
+		return fmt.Errorf("%s failed: %w, output: %s", command, err, result.Output())
+	}
+	if result.Truncated {
+		return fmt.Errorf("%s output exceeded the configured size limit", command)
+	}
+	if err := json.Unmarshal([]byte(result.Stdout), out); err != nil {
+		log.Printf("Failed to unmarshal JSON from %q. Raw data: %s. Error: %v", command, result.Stdout, err)
+		return fmt.Errorf("failed to unmarshal json: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package slurm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/models"
+)
+
+// Credentials 是以某个用户身份访问 slurmrestd 所需的认证信息
+type Credentials struct {
+	Username string
+	Token    string
+}
+
+// Client 是 slurmrestd 的类型化客户端，负责认证头、URL 拼接、错误解析和 JSON 解码
+type Client struct {
+	cfg        *config.Provider
+	httpClient *http.Client
+
+	versionMu  sync.Mutex
+	negotiated negotiatedVersion
+}
+
+// NewClient 创建一个客户端，每次请求时从 cfg 读取 slurmrestd 地址，以支持配置热加载
+func NewClient(cfg *config.Provider) *Client {
+	return &Client{
+		cfg:        cfg,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+// ListJobs 获取所有作业
+func (c *Client) ListJobs(ctx context.Context, creds Credentials) (*models.SlurmJobResponse, error) {
+	var resp models.SlurmJobResponse
+	if err := c.do(ctx, creds, http.MethodGet, "/jobs", nil, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// GetJob 获取单个作业的详细信息
+func (c *Client) GetJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmJobResponse, error) {
+	var resp models.SlurmJobResponse
+	if err := c.do(ctx, creds, http.MethodGet, "/job/"+url.PathEscape(jobID), nil, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// CancelJob 取消一个作业
+func (c *Client) CancelJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmBasicResponse, error) {
+	var resp models.SlurmBasicResponse
+	if err := c.do(ctx, creds, http.MethodDelete, "/job/"+url.PathEscape(jobID), nil, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// SubmitJob 提交一个批处理作业，body 为 slurmrestd 的 job_submit_req 结构
+func (c *Client) SubmitJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error) {
+	var resp models.SlurmJobSubmitResponse
+	if err := c.do(ctx, creds, http.MethodPost, "/job/submit", body, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// AllocateJob 申请一个资源分配，body 为 slurmrestd 的 job_alloc_req 结构
+func (c *Client) AllocateJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error) {
+	var resp models.SlurmJobSubmitResponse
+	if err := c.do(ctx, creds, http.MethodPost, "/job/allocate", body, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListNodes 获取所有节点
+func (c *Client) ListNodes(ctx context.Context, creds Credentials) (*models.SlurmNodeResponse, error) {
+	var resp models.SlurmNodeResponse
+	if err := c.do(ctx, creds, http.MethodGet, "/nodes", nil, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// ListPartitions 获取所有分区
+func (c *Client) ListPartitions(ctx context.Context, creds Credentials) (*models.SlurmPartitionResponse, error) {
+	var resp models.SlurmPartitionResponse
+	if err := c.do(ctx, creds, http.MethodGet, "/partitions", nil, &resp); err != nil {
+		return nil, err
+	}
+	if err := checkResponse(resp.Errors, resp.Warnings); err != nil {
+		return nil, err
+	}
+	return &resp, nil
+}
+
+// Ping 检查 slurmrestd 是否可达。任何 HTTP 响应（包括 401）都说明服务在线，
+// 因为健康检查没有用户凭据可用。
+func (c *Client) Ping(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.cfg.Get().SlurmAPIHost+"/openapi/v3", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return requestError(ctx, err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return errors.New("slurmrestd returned " + resp.Status)
+	}
+	return nil
+}
+
+// do 发送请求并将响应体解码到 out。
+// 非 2xx 的响应会尽量解析其中的 errors/warnings 数组并作为 *APIError 返回。
+func (c *Client) do(ctx context.Context, creds Credentials, method, endpoint string, body []byte, out interface{}) error {
+	version, err := c.apiVersion(ctx, creds)
+	if err != nil {
+		return err
+	}
+	targetURL := c.cfg.Get().SlurmAPIHost + "/slurm/" + version + endpoint
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, targetURL, reqBody)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("X-SLURM-USER-NAME", creds.Username)
+	req.Header.Set("X-SLURM-USER-TOKEN", creds.Token)
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		var basic models.SlurmBasicResponse
+		if json.Unmarshal(respBody, &basic) == nil {
+			apiErr.Errors = basic.Errors
+			apiErr.Warnings = basic.Warnings
+		} else {
+			apiErr.Body = string(respBody)
+		}
+		if resp.StatusCode == http.StatusNotFound && len(apiErr.Errors) == 0 {
+			// 路径本身不存在，可能是集群升级后移除了该 API 版本，下次请求时重新协商
+			c.resetVersion(version)
+		}
+		return apiErr
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		log.Printf("Failed to unmarshal JSON for %s %s. Raw data: %s. Error: %v", method, targetURL, string(respBody), err)
+		return fmt.Errorf("failed to unmarshal json: %w", err)
+	}
+	return nil
+}
+
+// checkResponse 将 2xx 响应中的 errors 数组转换为 *APIError，warnings 只记录日志
+func checkResponse(errs []models.SlurmError, warnings []models.SlurmWarning) error {
+	for _, w := range warnings {
+		log.Printf("Slurm API warning from %s: %s", w.Source, w.Description)
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return &APIError{StatusCode: http.StatusOK, Errors: errs, Warnings: warnings}
+}
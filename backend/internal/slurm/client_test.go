@@ -0,0 +1,150 @@
+package slurm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/models"
+	"slurm-dashboard/internal/testutil/fakecli"
+	"slurm-dashboard/internal/testutil/fakeslurm"
+)
+
+func newTestClient(t *testing.T) (*Client, *config.Provider, *fakeslurm.Server) {
+	srv := fakeslurm.New(t)
+	srv.SetToken("alice", "token")
+	cfg := config.Default()
+	cfg.SlurmAPIHost = srv.URL
+	provider := config.NewStaticProvider(cfg)
+	return NewClient(provider), provider, srv
+}
+
+var alice = Credentials{Username: "alice", Token: "token"}
+
+func TestNegotiatePicksNewestSupportedVersion(t *testing.T) {
+	client, _, srv := newTestClient(t)
+	srv.SetVersions("v0.0.39", "v0.0.40", "v0.0.41")
+
+	version, err := client.Negotiate(context.Background(), nil)
+	if err != nil {
+		t.Fatalf("negotiation failed: %v", err)
+	}
+	if version != "v0.0.41" {
+		t.Fatalf("expected v0.0.41, got %s", version)
+	}
+
+	if _, err := client.ListJobs(context.Background(), alice); err != nil {
+		t.Fatalf("ListJobs failed: %v", err)
+	}
+	reqs := srv.Requests()
+	if last := reqs[len(reqs)-1].Path; last != "/slurm/v0.0.41/jobs" {
+		t.Fatalf("expected request against negotiated version, got %s", last)
+	}
+}
+
+func TestNegotiateFailsWithoutSupportedVersion(t *testing.T) {
+	client, _, srv := newTestClient(t)
+	srv.SetVersions("v0.0.39")
+
+	if _, err := client.Negotiate(context.Background(), &alice); !errors.Is(err, ErrNoSupportedVersion) {
+		t.Fatalf("expected ErrNoSupportedVersion, got %v", err)
+	}
+}
+
+func TestClientSurfacesSlurmErrors(t *testing.T) {
+	client, _, _ := newTestClient(t)
+
+	_, err := client.GetJob(context.Background(), alice, "404")
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusNotFound || len(apiErr.Errors) != 1 || apiErr.Errors[0].ErrorNumber != 2017 {
+		t.Fatalf("unexpected api error: %+v", apiErr)
+	}
+
+	_, err = client.ListJobs(context.Background(), Credentials{Username: "mallory", Token: "forged"})
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected 401 api error, got %v", err)
+	}
+}
+
+func TestFailoverSourceUsesCLIWhenRestIsDown(t *testing.T) {
+	client, provider, srv := newTestClient(t)
+	srv.AddJob(models.SlurmJobInfo{JobID: 1, UserName: "alice"})
+	cli := fakecli.New()
+	source := NewFailoverSource(provider, client, NewCLISource(cli))
+
+	resp, err := source.ListJobs(context.Background(), alice)
+	if err != nil || len(resp.Jobs) != 1 {
+		t.Fatalf("expected jobs from slurmrestd, got %v, %v", resp, err)
+	}
+
+	cli.Output("squeue", `{"jobs":[{"job_id":2,"user_name":"alice","job_state":"RUNNING","submit_time":1700000000}]}`)
+	srv.Close()
+
+	resp, err = source.ListJobs(context.Background(), alice)
+	if err != nil {
+		t.Fatalf("expected CLI fallback to succeed, got %v", err)
+	}
+	if !source.Degraded() {
+		t.Error("source should be degraded after slurmrestd became unreachable")
+	}
+	job := resp.Jobs[0]
+	if job.JobID != 2 || job.JobState[0] != "RUNNING" || job.SubmitTime.Number != 1700000000 {
+		t.Fatalf("unexpected job from CLI: %+v", job)
+	}
+	if calls := cli.Calls(); len(calls) != 1 || calls[0].Username != "alice" {
+		t.Fatalf("expected squeue to run as alice, got %+v", calls)
+	}
+}
+
+func TestFailoverSourceIgnoresCanceledRequests(t *testing.T) {
+	client, provider, _ := newTestClient(t)
+	cli := fakecli.New()
+	source := NewFailoverSource(provider, client, NewCLISource(cli))
+
+	// 客户端断开连接不说明 slurmrestd 不可用，不应降级或改用命令行
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := source.ListJobs(ctx, alice); !errors.Is(err, context.Canceled) || IsUnreachable(err) {
+		t.Fatalf("expected the context error to be returned unwrapped, got %v", err)
+	}
+	if source.Degraded() {
+		t.Error("a canceled request must not mark slurmrestd as degraded")
+	}
+	if calls := cli.Calls(); len(calls) != 0 {
+		t.Errorf("a canceled request must not fall back to the CLI, got %+v", calls)
+	}
+}
+
+func TestFailoverSourceDoesNotResubmitJobs(t *testing.T) {
+	client, provider, srv := newTestClient(t)
+	cli := fakecli.New()
+	source := NewFailoverSource(provider, client, NewCLISource(cli))
+	body := json.RawMessage(`{"job":{"script":"#!/bin/bash\ntrue"}}`)
+
+	// 响应丢失时作业可能已经提交，返回错误而不是降级后交给命令行
+	srv.DropNext("/job/submit", 1)
+	if _, err := source.SubmitJob(context.Background(), alice, body); err == nil || errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected the upstream error, got %v", err)
+	}
+	if source.Degraded() {
+		t.Error("a submit that may have reached slurmrestd must not mark it as degraded")
+	}
+	if jobs := srv.Jobs(); len(jobs) != 1 {
+		t.Fatalf("expected slurmrestd to have accepted the job, got %+v", jobs)
+	}
+
+	// 连接被拒绝时请求确定没有发出，可以降级
+	srv.Close()
+	if _, err := source.SubmitJob(context.Background(), alice, body); !errors.Is(err, ErrNotSupported) {
+		t.Fatalf("expected the CLI fallback to reject the submit, got %v", err)
+	}
+	if !source.Degraded() {
+		t.Error("source should be degraded after slurmrestd refused the connection")
+	}
+}
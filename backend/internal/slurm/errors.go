@@ -0,0 +1,92 @@
+package slurm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+
+	"slurm-dashboard/internal/models"
+)
+
+// APIError 表示 slurmrestd 返回了错误，可能是非 2xx 状态码，也可能是 2xx 响应中带有 errors 数组
+type APIError struct {
+	StatusCode int
+	Errors     []models.SlurmError
+	Warnings   []models.SlurmWarning
+	// Body 在响应体无法解析为标准结构时保存原始内容
+	Body string
+}
+
+func (e *APIError) Error() string {
+	if len(e.Errors) == 0 {
+		if e.Body != "" {
+			return fmt.Sprintf("slurm api returned status %d: %s", e.StatusCode, e.Body)
+		}
+		return fmt.Sprintf("slurm api returned status %d", e.StatusCode)
+	}
+	msgs := make([]string, 0, len(e.Errors))
+	for _, se := range e.Errors {
+		msg := se.Description
+		if msg == "" {
+			msg = se.Error
+		}
+		if se.ErrorNumber != 0 {
+			msg = fmt.Sprintf("%s (error %d)", msg, se.ErrorNumber)
+		}
+		msgs = append(msgs, msg)
+	}
+	return fmt.Sprintf("slurm api returned status %d: %s", e.StatusCode, strings.Join(msgs, "; "))
+}
+
+// HTTPStatus 返回应该传递给前端的状态码。
+// 2xx 响应中带有 errors 时 slurmrestd 本身并未失败，此时视为网关错误。
+func (e *APIError) HTTPStatus() int {
+	if e.StatusCode < 400 {
+		return http.StatusBadGateway
+	}
+	return e.StatusCode
+}
+
+// UnreachableError 表示无法连接到 slurmrestd
+type UnreachableError struct {
+	Err error
+}
+
+func (e *UnreachableError) Error() string {
+	return fmt.Sprintf("request to slurm failed: %v", e.Err)
+}
+
+func (e *UnreachableError) Unwrap() error {
+	return e.Err
+}
+
+// IsUnreachable 判断错误是否由于无法连接 slurmrestd 导致
+func IsUnreachable(err error) bool {
+	var ue *UnreachableError
+	return errors.As(err, &ue)
+}
+
+// notSent 判断请求是否确定没有到达 slurmrestd：连接被拒绝或 DNS 解析失败。
+// 超时、连接被重置等其他错误发生时 slurmrestd 可能已经处理了请求
+func notSent(err error) bool {
+	var dnsErr *net.DNSError
+	return IsUnreachable(err) && (errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED))
+}
+
+// isCanceled 判断错误是否由于请求的 context 被取消或超时导致
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded)
+}
+
+// requestError 包装 http.Client.Do 返回的错误。ctx 已经结束时原样返回 ctx 的错误，
+// 避免客户端断开连接被当作 slurmrestd 不可达而触发降级
+func requestError(ctx context.Context, err error) error {
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return &UnreachableError{Err: err}
+}
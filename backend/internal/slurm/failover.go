@@ -0,0 +1,163 @@
+package slurm
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"slurm-dashboard/config"
+	"slurm-dashboard/internal/models"
+)
+
+// FailoverSource 优先使用 slurmrestd，当健康检查失败或请求无法到达 slurmrestd 时
+// 自动切换到命令行数据源，slurmrestd 恢复后再切换回来
+type FailoverSource struct {
+	cfg      *config.Provider
+	primary  *Client
+	fallback *CLISource
+	// degraded 为 true 表示 slurmrestd 当前被判定为不可用
+	degraded atomic.Bool
+}
+
+func NewFailoverSource(cfg *config.Provider, primary *Client, fallback *CLISource) *FailoverSource {
+	return &FailoverSource{cfg: cfg, primary: primary, fallback: fallback}
+}
+
+// Degraded 返回当前是否正在使用命令行数据源
+func (s *FailoverSource) Degraded() bool {
+	return s.degraded.Load()
+}
+
+// RunHealthChecks 按配置的间隔检查 slurmrestd 是否可达，直到 ctx 结束
+func (s *FailoverSource) RunHealthChecks(ctx context.Context) {
+	for {
+		s.checkHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(s.cfg.Get().SlurmHealthCheckInterval):
+		}
+	}
+}
+
+func (s *FailoverSource) checkHealth(ctx context.Context) {
+	checkCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	err := s.primary.Ping(checkCtx)
+	if ctx.Err() != nil {
+		// 服务正在关闭，本次检查没有结论
+		return
+	}
+	s.setDegraded(err != nil)
+}
+
+func (s *FailoverSource) setDegraded(degraded bool) {
+	if s.degraded.Swap(degraded) == degraded {
+		return
+	}
+	if degraded {
+		log.Println("slurmrestd is unreachable, switching to Slurm CLI data source")
+	} else {
+		log.Println("slurmrestd is reachable again, switching back from Slurm CLI data source")
+	}
+}
+
+// useFallback 判断本次请求是否应直接使用命令行数据源
+func (s *FailoverSource) useFallback() bool {
+	return s.cfg.Get().SlurmCLIFallback && s.degraded.Load()
+}
+
+// shouldRetry 判断 primary 返回的错误是否应该触发降级重试。
+// 请求被取消或超时时直接返回，不降级也不再调用命令行
+func (s *FailoverSource) shouldRetry(err error) bool {
+	if err == nil || isCanceled(err) || !IsUnreachable(err) || !s.cfg.Get().SlurmCLIFallback {
+		return false
+	}
+	s.setDegraded(true)
+	return true
+}
+
+// shouldRetryUnsent 与 shouldRetry 相同，但用于提交作业等不能重复执行的请求：只有确定请求没有发出时才降级重试。
+// 否则 slurmrestd 可能已经接受了作业，原样返回错误，避免用户重新提交产生重复的作业
+func (s *FailoverSource) shouldRetryUnsent(err error) bool {
+	return notSent(err) && s.shouldRetry(err)
+}
+
+func (s *FailoverSource) ListJobs(ctx context.Context, creds Credentials) (*models.SlurmJobResponse, error) {
+	if s.useFallback() {
+		return s.fallback.ListJobs(ctx, creds)
+	}
+	resp, err := s.primary.ListJobs(ctx, creds)
+	if s.shouldRetry(err) {
+		return s.fallback.ListJobs(ctx, creds)
+	}
+	return resp, err
+}
+
+func (s *FailoverSource) GetJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmJobResponse, error) {
+	if s.useFallback() {
+		return s.fallback.GetJob(ctx, creds, jobID)
+	}
+	resp, err := s.primary.GetJob(ctx, creds, jobID)
+	if s.shouldRetry(err) {
+		return s.fallback.GetJob(ctx, creds, jobID)
+	}
+	return resp, err
+}
+
+func (s *FailoverSource) CancelJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmBasicResponse, error) {
+	if s.useFallback() {
+		return s.fallback.CancelJob(ctx, creds, jobID)
+	}
+	resp, err := s.primary.CancelJob(ctx, creds, jobID)
+	if s.shouldRetry(err) {
+		return s.fallback.CancelJob(ctx, creds, jobID)
+	}
+	return resp, err
+}
+
+func (s *FailoverSource) SubmitJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error) {
+	if s.useFallback() {
+		return s.fallback.SubmitJob(ctx, creds, body)
+	}
+	resp, err := s.primary.SubmitJob(ctx, creds, body)
+	if s.shouldRetryUnsent(err) {
+		return s.fallback.SubmitJob(ctx, creds, body)
+	}
+	return resp, err
+}
+
+func (s *FailoverSource) AllocateJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error) {
+	if s.useFallback() {
+		return s.fallback.AllocateJob(ctx, creds, body)
+	}
+	resp, err := s.primary.AllocateJob(ctx, creds, body)
+	if s.shouldRetryUnsent(err) {
+		return s.fallback.AllocateJob(ctx, creds, body)
+	}
+	return resp, err
+}
+
+func (s *FailoverSource) ListNodes(ctx context.Context, creds Credentials) (*models.SlurmNodeResponse, error) {
+	if s.useFallback() {
+		return s.fallback.ListNodes(ctx, creds)
+	}
+	resp, err := s.primary.ListNodes(ctx, creds)
+	if s.shouldRetry(err) {
+		return s.fallback.ListNodes(ctx, creds)
+	}
+	return resp, err
+}
+
+func (s *FailoverSource) ListPartitions(ctx context.Context, creds Credentials) (*models.SlurmPartitionResponse, error) {
+	if s.useFallback() {
+		return s.fallback.ListPartitions(ctx, creds)
+	}
+	resp, err := s.primary.ListPartitions(ctx, creds)
+	if s.shouldRetry(err) {
+		return s.fallback.ListPartitions(ctx, creds)
+	}
+	return resp, err
+}
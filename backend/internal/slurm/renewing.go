@@ -0,0 +1,84 @@
+package slurm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+
+	"slurm-dashboard/internal/models"
+)
+
+// RenewFunc 为用户签发新的 Slurm token，staleToken 为被 slurmrestd 拒绝的 token
+type RenewFunc func(ctx context.Context, username, staleToken string) (string, error)
+
+// RenewingSource 在 slurmrestd 以 401 拒绝用户 token 时续期一次并透明地重试，
+// 这样即使后台续期没有赶上 token 到期，用户也不会看到认证错误
+type RenewingSource struct {
+	source Source
+	renew  RenewFunc
+}
+
+func NewRenewingSource(source Source, renew RenewFunc) *RenewingSource {
+	return &RenewingSource{source: source, renew: renew}
+}
+
+func (s *RenewingSource) ListJobs(ctx context.Context, creds Credentials) (*models.SlurmJobResponse, error) {
+	return withRenewal(ctx, s, creds, func(creds Credentials) (*models.SlurmJobResponse, error) {
+		return s.source.ListJobs(ctx, creds)
+	})
+}
+
+func (s *RenewingSource) GetJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmJobResponse, error) {
+	return withRenewal(ctx, s, creds, func(creds Credentials) (*models.SlurmJobResponse, error) {
+		return s.source.GetJob(ctx, creds, jobID)
+	})
+}
+
+func (s *RenewingSource) CancelJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmBasicResponse, error) {
+	return withRenewal(ctx, s, creds, func(creds Credentials) (*models.SlurmBasicResponse, error) {
+		return s.source.CancelJob(ctx, creds, jobID)
+	})
+}
+
+// SubmitJob 被 401 拒绝的请求不会创建作业，因此重试是安全的
+func (s *RenewingSource) SubmitJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error) {
+	return withRenewal(ctx, s, creds, func(creds Credentials) (*models.SlurmJobSubmitResponse, error) {
+		return s.source.SubmitJob(ctx, creds, body)
+	})
+}
+
+func (s *RenewingSource) AllocateJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error) {
+	return withRenewal(ctx, s, creds, func(creds Credentials) (*models.SlurmJobSubmitResponse, error) {
+		return s.source.AllocateJob(ctx, creds, body)
+	})
+}
+
+func (s *RenewingSource) ListNodes(ctx context.Context, creds Credentials) (*models.SlurmNodeResponse, error) {
+	return withRenewal(ctx, s, creds, func(creds Credentials) (*models.SlurmNodeResponse, error) {
+		return s.source.ListNodes(ctx, creds)
+	})
+}
+
+func (s *RenewingSource) ListPartitions(ctx context.Context, creds Credentials) (*models.SlurmPartitionResponse, error) {
+	return withRenewal(ctx, s, creds, func(creds Credentials) (*models.SlurmPartitionResponse, error) {
+		return s.source.ListPartitions(ctx, creds)
+	})
+}
+
+func withRenewal[T any](ctx context.Context, s *RenewingSource, creds Credentials, call func(Credentials) (T, error)) (T, error) {
+	resp, err := call(creds)
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	token, renewErr := s.renew(ctx, creds.Username, creds.Token)
+	if renewErr != nil {
+		log.Printf("Slurm rejected the token of user %s and renewal failed: %v", creds.Username, renewErr)
+		return resp, err
+	}
+	creds.Token = token
+	return call(creds)
+}
@@ -0,0 +1,27 @@
+package slurm
+
+import (
+	"context"
+	"encoding/json"
+
+	"slurm-dashboard/internal/models"
+)
+
+// Source 是作业、节点和分区数据的来源，处理器只依赖该接口。
+// *Client（slurmrestd）、*CLISource（Slurm 命令行）和 *FailoverSource 都实现了它。
+type Source interface {
+	ListJobs(ctx context.Context, creds Credentials) (*models.SlurmJobResponse, error)
+	GetJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmJobResponse, error)
+	CancelJob(ctx context.Context, creds Credentials, jobID string) (*models.SlurmBasicResponse, error)
+	SubmitJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error)
+	AllocateJob(ctx context.Context, creds Credentials, body json.RawMessage) (*models.SlurmJobSubmitResponse, error)
+	ListNodes(ctx context.Context, creds Credentials) (*models.SlurmNodeResponse, error)
+	ListPartitions(ctx context.Context, creds Credentials) (*models.SlurmPartitionResponse, error)
+}
+
+var (
+	_ Source = (*Client)(nil)
+	_ Source = (*CLISource)(nil)
+	_ Source = (*FailoverSource)(nil)
+	_ Source = (*RenewingSource)(nil)
+)
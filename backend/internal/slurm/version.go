@@ -0,0 +1,165 @@
+package slurm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// SupportedVersions 是后端支持的 slurmrestd API 版本，按从新到旧排列。
+//
+// 本项目用到的字段在 v0.0.40 到 v0.0.42 之间名称保持一致，版本间的差异主要在于
+// 数值字段的编码（纯数字或 {set,infinite,number} 对象）以及状态字段是否为数组，
+// 这些差异由 models 中的 SlurmUint64NoVal 和 SlurmStringList 统一处理，
+// 因此无论集群使用哪个版本，返回给前端的结构都保持不变。
+var SupportedVersions = []string{"v0.0.42", "v0.0.41", "v0.0.40"}
+
+// ErrNoSupportedVersion 表示 slurmrestd 不提供任何受支持的 API 版本
+var ErrNoSupportedVersion = errors.New("slurmrestd does not offer a supported API version")
+
+// negotiatedVersion 记录针对某个 slurmrestd 地址协商出的版本，地址变化后需要重新协商
+type negotiatedVersion struct {
+	host    string
+	version string
+}
+
+// Version 返回当前协商出的 API 版本，尚未协商时返回空字符串
+func (c *Client) Version() string {
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+	if c.negotiated.host != c.cfg.Get().SlurmAPIHost {
+		return ""
+	}
+	return c.negotiated.version
+}
+
+// Negotiate 探测 slurmrestd 并选择双方都支持的最新 API 版本。
+// 先尝试读取 /openapi/v3 中声明的路径；若该接口不可用（例如需要认证），
+// 再依次以 creds 身份请求各版本的 /ping，并根据 meta.plugin.data_parser 确认版本。
+// creds 为 nil 时只进行 OpenAPI 探测。
+func (c *Client) Negotiate(ctx context.Context, creds *Credentials) (string, error) {
+	host := c.cfg.Get().SlurmAPIHost
+
+	version, err := c.probeOpenAPI(ctx, host)
+	if err != nil && creds != nil {
+		log.Printf("OpenAPI probe of %s failed (%v), falling back to ping probe", host, err)
+		version, err = c.probePing(ctx, host, *creds)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	c.versionMu.Lock()
+	changed := c.negotiated.host != host || c.negotiated.version != version
+	c.negotiated = negotiatedVersion{host: host, version: version}
+	c.versionMu.Unlock()
+
+	if changed {
+		log.Printf("Using Slurm REST API %s at %s", version, host)
+	}
+	return version, nil
+}
+
+// resetVersion 在 version 仍是当前协商结果时将其清除
+func (c *Client) resetVersion(version string) {
+	c.versionMu.Lock()
+	defer c.versionMu.Unlock()
+	if c.negotiated.version == version {
+		log.Printf("Slurm REST API %s is no longer available, renegotiating on next request", version)
+		c.negotiated = negotiatedVersion{}
+	}
+}
+
+// apiVersion 返回当前地址对应的 API 版本，必要时使用 creds 进行协商
+func (c *Client) apiVersion(ctx context.Context, creds Credentials) (string, error) {
+	if version := c.Version(); version != "" {
+		return version, nil
+	}
+	return c.Negotiate(ctx, &creds)
+}
+
+func (c *Client) probeOpenAPI(ctx context.Context, host string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, host+"/openapi/v3", nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", requestError(ctx, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("openapi endpoint returned status %d", resp.StatusCode)
+	}
+
+	var spec struct {
+		Paths map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&spec); err != nil {
+		return "", fmt.Errorf("failed to decode openapi spec: %w", err)
+	}
+
+	offered := make(map[string]struct{})
+	for path := range spec.Paths {
+		// 路径形如 /slurm/v0.0.42/jobs
+		parts := strings.SplitN(strings.TrimPrefix(path, "/slurm/"), "/", 2)
+		if strings.HasPrefix(path, "/slurm/") && len(parts) == 2 {
+			offered[parts[0]] = struct{}{}
+		}
+	}
+	for _, version := range SupportedVersions {
+		if _, ok := offered[version]; ok {
+			return version, nil
+		}
+	}
+	return "", ErrNoSupportedVersion
+}
+
+func (c *Client) probePing(ctx context.Context, host string, creds Credentials) (string, error) {
+	for _, version := range SupportedVersions {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, host+"/slurm/"+version+"/ping", nil)
+		if err != nil {
+			return "", fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("X-SLURM-USER-NAME", creds.Username)
+		req.Header.Set("X-SLURM-USER-TOKEN", creds.Token)
+		req.Header.Set("Accept", "application/json")
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return "", requestError(ctx, err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return "", &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+		}
+		if resp.StatusCode != http.StatusOK {
+			continue
+		}
+
+		var ping struct {
+			Meta struct {
+				Plugin struct {
+					DataParser string `json:"data_parser"`
+				} `json:"plugin"`
+			} `json:"meta"`
+		}
+		if err := json.Unmarshal(body, &ping); err == nil && ping.Meta.Plugin.DataParser != "" &&
+			!strings.HasSuffix(ping.Meta.Plugin.DataParser, "/"+version) {
+			// slurmrestd 接受了该路径，但实际使用的解析插件版本不同，以插件为准
+			log.Printf("slurmrestd answered %s with data parser %s", version, ping.Meta.Plugin.DataParser)
+			continue
+		}
+		return version, nil
+	}
+	return "", ErrNoSupportedVersion
+}
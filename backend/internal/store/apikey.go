@@ -0,0 +1,202 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// APIKeyPrefix 是所有 API key 的前缀，便于与 JWT 区分，也便于密钥扫描工具识别泄露的 key
+	APIKeyPrefix = "sdk_"
+	// MaxAPIKeysPerUser 为每个用户最多同时持有的 API key 数
+	MaxAPIKeysPerUser = 20
+	// DefaultAPIKeyLifetime 为创建时未指定有效期的 API key 的有效期，MaxAPIKeyLifetime 为允许的最长有效期。
+	// 泄露的 key 最迟在到期后失效
+	DefaultAPIKeyLifetime = 90 * 24 * time.Hour
+	MaxAPIKeyLifetime     = 365 * 24 * time.Hour
+
+	// apiKeyTouchInterval 为更新最后使用时间的最小间隔，避免每个请求都写一次数据库
+	apiKeyTouchInterval = time.Minute
+	// apiKeyDisplayLength 为保存下来用于在列表中辨认 key 的开头部分的长度
+	apiKeyDisplayLength = len(APIKeyPrefix) + 6
+)
+
+var (
+	// ErrAPIKeyInvalid 表示 API key 不存在、已吊销或已过期
+	ErrAPIKeyInvalid = errors.New("API key is invalid or expired")
+	// ErrAPIKeyNotFound 表示用户没有该 ID 的 API key
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	// ErrAPIKeyLimit 表示用户的 API key 数已达到 MaxAPIKeysPerUser
+	ErrAPIKeyLimit = errors.New("too many API keys")
+)
+
+// APIKey 是服务端保存的 API key 记录，key 本身只以 SHA-256 摘要作为存储的键
+type APIKey struct {
+	ID       string   `json:"id"`
+	Username string   `json:"username"`
+	Name     string   `json:"name"`
+	Scopes   []string `json:"scopes"`
+	// Display 为 key 的开头部分，只用于在列表中辨认
+	Display    string     `json:"display"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
+
+// HasScope 判断 key 是否被授予了 scope
+func (k APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyStore 保存用户为脚本和 CI 创建的长期 API key
+type APIKeyStore struct {
+	db Store
+
+	// mu 串行化创建和最后使用时间的更新
+	mu sync.Mutex
+}
+
+func NewAPIKeyStore(db Store) *APIKeyStore {
+	return &APIKeyStore{db: db}
+}
+
+// Create 为用户生成一个新的 API key，返回只在此时可见的完整 key 和保存的记录
+func (s *APIKeyStore) Create(username, name string, scopes []string, expiresAt time.Time) (string, APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	existing, err := s.list(username)
+	if err != nil {
+		return "", APIKey{}, err
+	}
+	if len(existing) >= MaxAPIKeysPerUser {
+		return "", APIKey{}, ErrAPIKeyLimit
+	}
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", APIKey{}, err
+	}
+	key := APIKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", APIKey{}, err
+	}
+
+	record := APIKey{
+		ID:        hex.EncodeToString(id),
+		Username:  username,
+		Name:      name,
+		Scopes:    scopes,
+		Display:   key[:apiKeyDisplayLength],
+		CreatedAt: time.Now(),
+		ExpiresAt: &expiresAt,
+	}
+	if err := s.put(hashAPIKey(key), record); err != nil {
+		return "", APIKey{}, err
+	}
+	return key, record, nil
+}
+
+// Authenticate 返回 key 对应的记录，并按 apiKeyTouchInterval 更新其最后使用时间
+func (s *APIKeyStore) Authenticate(key string) (APIKey, error) {
+	if !strings.HasPrefix(key, APIKeyPrefix) {
+		return APIKey{}, ErrAPIKeyInvalid
+	}
+	hash := hashAPIKey(key)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	value, err := s.db.Get(apiKeysBucket, hash)
+	if errors.Is(err, ErrNotFound) {
+		return APIKey{}, ErrAPIKeyInvalid
+	}
+	if err != nil {
+		return APIKey{}, err
+	}
+	var record APIKey
+	if err := json.Unmarshal(value, &record); err != nil {
+		return APIKey{}, err
+	}
+
+	now := time.Now()
+	// 迁移 7 之后所有 key 都有到期时间，缺失时视为无效
+	if record.ExpiresAt == nil || now.After(*record.ExpiresAt) {
+		return APIKey{}, ErrAPIKeyInvalid
+	}
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= apiKeyTouchInterval {
+		record.LastUsedAt = &now
+		if err := s.put(hash, record); err != nil {
+			return APIKey{}, err
+		}
+	}
+	return record, nil
+}
+
+// List 按创建时间返回用户的所有 API key
+func (s *APIKeyStore) List(username string) ([]APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.list(username)
+}
+
+// Revoke 删除用户 ID 为 id 的 API key，之后使用该 key 的请求立即失败
+func (s *APIKeyStore) Revoke(username, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var hash string
+	err := s.db.ForEach(apiKeysBucket, func(key string, value []byte) error {
+		var record APIKey
+		if err := json.Unmarshal(value, &record); err == nil && record.Username == username && record.ID == id {
+			hash = key
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if hash == "" {
+		return ErrAPIKeyNotFound
+	}
+	return s.db.Delete(apiKeysBucket, hash)
+}
+
+func (s *APIKeyStore) list(username string) ([]APIKey, error) {
+	keys := []APIKey{}
+	err := s.db.ForEach(apiKeysBucket, func(_ string, value []byte) error {
+		var record APIKey
+		if err := json.Unmarshal(value, &record); err == nil && record.Username == username {
+			keys = append(keys, record)
+		}
+		return nil
+	})
+	sort.Slice(keys, func(i, j int) bool { return keys[i].CreatedAt.Before(keys[j].CreatedAt) })
+	return keys, err
+}
+
+func (s *APIKeyStore) put(hash string, record APIKey) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(apiKeysBucket, hash, value)
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
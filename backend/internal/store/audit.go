@@ -0,0 +1,153 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 审计事件的类型
+const (
+	// AuditLoginFailed 为密码错误的登录
+	AuditLoginFailed = "login_failed"
+	// AuditLoginBlocked 为在退避或锁定期间被拒绝、没有校验密码的登录
+	AuditLoginBlocked = "login_blocked"
+	// AuditLockout 为用户名或 IP 因连续失败被锁定
+	AuditLockout = "lockout"
+	// AuditLockoutCleared 为管理员解除锁定
+	AuditLockoutCleared = "lockout_cleared"
+	// AuditMFAFailed 为错误的两步验证码或恢复码
+	AuditMFAFailed = "mfa_failed"
+	// AuditMFAEnabled 和 AuditMFADisabled 为启用和停用两步验证，管理员重置时记录 Actor
+	AuditMFAEnabled  = "mfa_enabled"
+	AuditMFADisabled = "mfa_disabled"
+	// AuditRecoveryCodeUsed 为使用恢复码登录
+	AuditRecoveryCodeUsed = "recovery_code_used"
+)
+
+const (
+	auditPruneInterval = time.Hour
+	// auditKeyLayout 为定长的 UTC 时间，使记录的键按时间排序
+	auditKeyLayout = "20060102T150405.000000000Z"
+)
+
+// AuditEntry 是一条审计记录
+type AuditEntry struct {
+	Time     time.Time `json:"time"`
+	Event    string    `json:"event"`
+	Username string    `json:"username,omitempty"`
+	ClientIP string    `json:"client_ip,omitempty"`
+	// Actor 为执行操作的管理员，由用户自己触发的事件为空
+	Actor  string `json:"actor,omitempty"`
+	Detail string `json:"detail,omitempty"`
+}
+
+// AuditFilter 为查询审计日志的条件，零值字段不参与过滤
+type AuditFilter struct {
+	Username string
+	Event    string
+	// Limit 为最多返回的记录数，不大于 0 时返回全部
+	Limit int
+}
+
+// AuditLog 按时间顺序保存安全相关的事件，超过保留时间的记录在写入时定期清理
+type AuditLog struct {
+	db        Store
+	retention time.Duration
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func NewAuditLog(db Store, retention time.Duration) *AuditLog {
+	return &AuditLog{db: db, retention: retention}
+}
+
+// Record 写入一条审计记录，Time 为零值时使用当前时间
+func (a *AuditLog) Record(entry AuditEntry) error {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	entry.Time = entry.Time.UTC()
+	value, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return err
+	}
+	key := entry.Time.Format(auditKeyLayout) + "-" + hex.EncodeToString(suffix)
+	if err := a.db.Put(auditLogBucket, key, value); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	due := time.Since(a.lastPrune) >= auditPruneInterval
+	if due {
+		a.lastPrune = time.Now()
+	}
+	a.mu.Unlock()
+	if due {
+		if n, err := a.Prune(time.Now().Add(-a.retention)); err != nil {
+			log.Printf("Failed to prune audit log: %v", err)
+		} else if n > 0 {
+			log.Printf("Pruned %d audit log entries older than %s", n, a.retention)
+		}
+	}
+	return nil
+}
+
+// List 按时间倒序返回符合条件的记录
+func (a *AuditLog) List(filter AuditFilter) ([]AuditEntry, error) {
+	entries := []AuditEntry{}
+	err := a.db.ForEach(auditLogBucket, func(_ string, value []byte) error {
+		var entry AuditEntry
+		if err := json.Unmarshal(value, &entry); err != nil {
+			return nil
+		}
+		if filter.Username != "" && !strings.EqualFold(entry.Username, filter.Username) {
+			return nil
+		}
+		if filter.Event != "" && entry.Event != filter.Event {
+			return nil
+		}
+		entries = append(entries, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(entries)-1; i < j; i, j = i+1, j-1 {
+		entries[i], entries[j] = entries[j], entries[i]
+	}
+	if filter.Limit > 0 && len(entries) > filter.Limit {
+		entries = entries[:filter.Limit]
+	}
+	return entries, nil
+}
+
+// Prune 删除早于 before 的记录
+func (a *AuditLog) Prune(before time.Time) (int, error) {
+	cutoff := before.UTC().Format(auditKeyLayout)
+	var expired []string
+	err := a.db.ForEach(auditLogBucket, func(key string, _ []byte) error {
+		if key < cutoff {
+			expired = append(expired, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range expired {
+		if err := a.db.Delete(auditLogBucket, key); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
@@ -0,0 +1,104 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	// MFAChallengeTTL 为密码校验通过后输入两步验证码的时限
+	MFAChallengeTTL = 5 * time.Minute
+	// MaxMFAAttempts 为一次挑战允许输入错误验证码的次数，用完后需要重新输入密码
+	MaxMFAAttempts = 5
+)
+
+// ErrMFAChallengeInvalid 表示挑战不存在、已使用、已过期、错误次数已用完，或与客户端地址不符
+var ErrMFAChallengeInvalid = errors.New("MFA challenge is invalid or expired")
+
+// MFAChallenge 记录一次已通过第一因素、等待两步验证的登录
+type MFAChallenge struct {
+	Username string
+	Role     string
+	// Backend 为第一因素的认证方式
+	Backend  string
+	ClientIP string
+	// Enroll 为 true 时用户的角色要求两步验证但尚未绑定，需要先完成绑定
+	Enroll    bool
+	ExpiresAt time.Time
+
+	attempts int
+}
+
+// MFAChallengeStore 在内存中保存等待两步验证的登录，有效期很短，不需要跨越后端重启
+type MFAChallengeStore struct {
+	mu         sync.Mutex
+	challenges map[string]*MFAChallenge
+}
+
+func NewMFAChallengeStore() *MFAChallengeStore {
+	return &MFAChallengeStore{challenges: make(map[string]*MFAChallenge)}
+}
+
+// Issue 为 record 生成一个新的挑战，ExpiresAt 由 MFAChallengeTTL 决定
+func (s *MFAChallengeStore) Issue(record MFAChallenge) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	record.ExpiresAt = now.Add(MFAChallengeTTL)
+	record.attempts = 0
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, c := range s.challenges {
+		if now.After(c.ExpiresAt) {
+			delete(s.challenges, key)
+		}
+	}
+	s.challenges[token] = &record
+	return token, nil
+}
+
+// Get 返回挑战的记录，不消耗挑战
+func (s *MFAChallengeStore) Get(token, clientIP string) (MFAChallenge, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.challenges[token]
+	if !ok || time.Now().After(c.ExpiresAt) || c.ClientIP != clientIP {
+		return MFAChallenge{}, ErrMFAChallengeInvalid
+	}
+	return *c, nil
+}
+
+// Fail 记录一次错误的验证码，返回剩余的尝试次数，用完时删除挑战
+func (s *MFAChallengeStore) Fail(token string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.challenges[token]
+	if !ok {
+		return 0
+	}
+	c.attempts++
+	if c.attempts >= MaxMFAAttempts {
+		delete(s.challenges, token)
+		return 0
+	}
+	return MaxMFAAttempts - c.attempts
+}
+
+// Redeem 消耗挑战，并发的两次验证只有一次能完成登录
+func (s *MFAChallengeStore) Redeem(token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.challenges[token]; !ok {
+		return ErrMFAChallengeInvalid
+	}
+	delete(s.challenges, token)
+	return nil
+}
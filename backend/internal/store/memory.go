@@ -0,0 +1,85 @@
+package store
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryStore 是 Store 的内存实现，用于测试和未配置 store_path 的部署
+type MemoryStore struct {
+	mu      sync.RWMutex
+	buckets map[string]map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]map[string][]byte)}
+}
+
+func (s *MemoryStore) Get(bucket, key string) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return nil, ErrBucketNotFound
+	}
+	value, ok := b[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return append([]byte(nil), value...), nil
+}
+
+func (s *MemoryStore) Put(bucket, key string, value []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return ErrBucketNotFound
+	}
+	b[key] = append([]byte(nil), value...)
+	return nil
+}
+
+func (s *MemoryStore) Delete(bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return ErrBucketNotFound
+	}
+	delete(b, key)
+	return nil
+}
+
+func (s *MemoryStore) ForEach(bucket string, fn func(key string, value []byte) error) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	b, ok := s.buckets[bucket]
+	if !ok {
+		return ErrBucketNotFound
+	}
+	keys := make([]string, 0, len(b))
+	for key := range b {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := fn(key, append([]byte(nil), b[key]...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (s *MemoryStore) CreateBucket(bucket string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.buckets[bucket]; !ok {
+		s.buckets[bucket] = make(map[string][]byte)
+	}
+	return nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
@@ -0,0 +1,126 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+)
+
+const (
+	metaBucket       = "meta"
+	schemaVersionKey = "schema_version"
+
+	slurmTokensBucket   = "slurm_tokens"
+	sessionsBucket      = "sessions"
+	revokedTokensBucket = "revoked_tokens"
+	refreshTokensBucket = "refresh_tokens"
+	apiKeysBucket       = "api_keys"
+	auditLogBucket      = "audit_log"
+	totpBucket          = "totp"
+)
+
+// migration 将存储从上一个版本升级到 version。迁移只能追加，不能修改已发布的迁移。
+type migration struct {
+	version int
+	name    string
+	apply   func(s Store) error
+}
+
+var migrations = []migration{
+	{1, "create token and session buckets", func(s Store) error {
+		for _, bucket := range []string{slurmTokensBucket, sessionsBucket} {
+			if err := s.CreateBucket(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{2, "create revoked token bucket", func(s Store) error {
+		return s.CreateBucket(revokedTokensBucket)
+	}},
+	{3, "create refresh token bucket", func(s Store) error {
+		return s.CreateBucket(refreshTokensBucket)
+	}},
+	{4, "create API key bucket", func(s Store) error {
+		return s.CreateBucket(apiKeysBucket)
+	}},
+	{5, "create audit log bucket", func(s Store) error {
+		return s.CreateBucket(auditLogBucket)
+	}},
+	{6, "create TOTP bucket", func(s Store) error {
+		return s.CreateBucket(totpBucket)
+	}},
+	{7, "expire API keys created without an expiry", func(s Store) error {
+		expiresAt := time.Now().Add(DefaultAPIKeyLifetime)
+		updated := make(map[string][]byte)
+		err := s.ForEach(apiKeysBucket, func(key string, value []byte) error {
+			var record APIKey
+			if err := json.Unmarshal(value, &record); err != nil {
+				return err
+			}
+			if record.ExpiresAt != nil {
+				return nil
+			}
+			record.ExpiresAt = &expiresAt
+			data, err := json.Marshal(record)
+			if err != nil {
+				return err
+			}
+			updated[key] = data
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		for key, data := range updated {
+			if err := s.Put(apiKeysBucket, key, data); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+}
+
+// SchemaVersion 返回存储当前的 schema 版本，全新的存储为 0
+func SchemaVersion(s Store) (int, error) {
+	value, err := s.Get(metaBucket, schemaVersionKey)
+	if errors.Is(err, ErrBucketNotFound) || errors.Is(err, ErrNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(string(value))
+}
+
+// Migrate 依次执行尚未应用的迁移，每个迁移成功后立即记录版本号
+func Migrate(s Store) error {
+	if err := s.CreateBucket(metaBucket); err != nil {
+		return fmt.Errorf("failed to create meta bucket: %w", err)
+	}
+	current, err := SchemaVersion(s)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	latest := migrations[len(migrations)-1].version
+	if current > latest {
+		return fmt.Errorf("store schema version %d is newer than supported version %d", current, latest)
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+		if err := m.apply(s); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", m.version, m.name, err)
+		}
+		if err := s.Put(metaBucket, schemaVersionKey, []byte(strconv.Itoa(m.version))); err != nil {
+			return fmt.Errorf("failed to record schema version %d: %w", m.version, err)
+		}
+		log.Printf("Applied store migration %d: %s", m.version, m.name)
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package store
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+var (
+	// ErrRefreshTokenInvalid 表示 refresh token 不存在或已过期
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	// ErrRefreshTokenReused 表示一个已经使用过的 refresh token 被再次提交，整个家族已被吊销
+	ErrRefreshTokenReused = errors.New("refresh token has already been used")
+	// ErrRefreshTokenRaced 表示 refresh token 在 RefreshReuseGrace 内刚被轮换过，
+	// 通常是同一浏览器的多个标签页同时刷新，客户端应改用已保存的新 token
+	ErrRefreshTokenRaced = errors.New("refresh token was just rotated by another request")
+)
+
+// RefreshReuseGrace 为 refresh token 被使用后仍不视为重放的时间。多个标签页共享同一个 refresh token，
+// access token 到期时会几乎同时刷新，其中只有一个能成功，其余的不应让整个登录被吊销
+const RefreshReuseGrace = 30 * time.Second
+
+// refreshPruneInterval 为两次清理到期家族之间的最短间隔
+const refreshPruneInterval = 10 * time.Minute
+
+// RefreshToken 是服务端保存的 refresh token 记录。同一次登录轮换出的所有 token 属于同一个家族，
+// 家族的到期时间在登录时确定，轮换不会延长登录的有效期。
+type RefreshToken struct {
+	Username        string    `json:"username"`
+	FamilyID        string    `json:"family_id"`
+	FamilyExpiresAt time.Time `json:"family_expires_at"`
+	// AccessID 和 AccessExpiresAt 记录与该 refresh token 一同签发的 access token，吊销家族时一并吊销
+	AccessID        string    `json:"access_id"`
+	AccessExpiresAt time.Time `json:"access_expires_at"`
+	// MFA 表示该家族所属的登录通过了两步验证
+	MFA       bool       `json:"mfa,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+}
+
+// RefreshTokenStore 保存一次性使用的 refresh token。只保存 token 的 SHA-256 摘要，
+// 已使用的记录保留到家族到期，以便发现重放；签发时每隔 refreshPruneInterval 清理一次到期的家族。
+type RefreshTokenStore struct {
+	db          Store
+	revocations *RevocationStore
+
+	// mu 保证同一个 token 只能被成功轮换一次
+	mu sync.Mutex
+	// now 返回当前时间，测试中可以替换
+	now func() time.Time
+
+	pruneMu   sync.Mutex
+	lastPrune time.Time
+}
+
+func NewRefreshTokenStore(db Store, revocations *RevocationStore) *RefreshTokenStore {
+	return &RefreshTokenStore{db: db, revocations: revocations, now: time.Now}
+}
+
+// Issue 生成一个新的 refresh token 并保存 record，返回交给客户端的 token
+func (s *RefreshTokenStore) Issue(record RefreshToken) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	record.CreatedAt = time.Now()
+	record.UsedAt = nil
+	if err := s.put(hashRefreshToken(token), record); err != nil {
+		return "", err
+	}
+
+	s.pruneMu.Lock()
+	due := time.Since(s.lastPrune) >= refreshPruneInterval
+	if due {
+		s.lastPrune = time.Now()
+	}
+	s.pruneMu.Unlock()
+	if !due {
+		return token, nil
+	}
+	if n, err := s.prune(time.Now()); err != nil {
+		log.Printf("Failed to prune refresh tokens: %v", err)
+	} else if n > 0 {
+		log.Printf("Pruned %d expired refresh tokens", n)
+	}
+	return token, nil
+}
+
+// Peek 返回 token 的记录而不消费它，用于轮换前的检查。token 不存在或家族已到期时返回 ErrRefreshTokenInvalid
+func (s *RefreshTokenStore) Peek(token string) (RefreshToken, error) {
+	record, err := s.get(hashRefreshToken(token))
+	if errors.Is(err, ErrNotFound) || (err == nil && s.now().After(record.FamilyExpiresAt)) {
+		return RefreshToken{}, ErrRefreshTokenInvalid
+	}
+	return record, err
+}
+
+// Rotate 消费 token 并返回其记录，调用方应随后为同一家族签发新的 token。
+// token 在 RefreshReuseGrace 内刚被使用过时返回 ErrRefreshTokenRaced，不签发新 token 也不吊销；
+// 更早使用过的 token 被再次提交说明它可能已经泄露，此时吊销整个家族并返回 ErrRefreshTokenReused。
+func (s *RefreshTokenStore) Rotate(token string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key := hashRefreshToken(token)
+	record, err := s.get(key)
+	if errors.Is(err, ErrNotFound) {
+		return RefreshToken{}, ErrRefreshTokenInvalid
+	}
+	if err != nil {
+		return RefreshToken{}, err
+	}
+
+	now := s.now()
+	if now.After(record.FamilyExpiresAt) {
+		return RefreshToken{}, ErrRefreshTokenInvalid
+	}
+	if record.UsedAt != nil && now.Sub(*record.UsedAt) < RefreshReuseGrace {
+		return RefreshToken{}, ErrRefreshTokenRaced
+	}
+	if record.UsedAt != nil {
+		log.Printf("Refresh token reuse detected for user %s, revoking token family %s", record.Username, record.FamilyID)
+		if err := s.revokeFamilyLocked(record.FamilyID); err != nil {
+			return RefreshToken{}, err
+		}
+		return RefreshToken{}, ErrRefreshTokenReused
+	}
+
+	record.UsedAt = &now
+	if err := s.put(key, record); err != nil {
+		return RefreshToken{}, err
+	}
+	return record, nil
+}
+
+// RevokeFamily 删除家族中的所有 refresh token，并吊销它们对应的、尚未过期的 access token
+func (s *RefreshTokenStore) RevokeFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revokeFamilyLocked(familyID)
+}
+
+func (s *RefreshTokenStore) revokeFamilyLocked(familyID string) error {
+	members := make(map[string]RefreshToken)
+	err := s.db.ForEach(refreshTokensBucket, func(key string, value []byte) error {
+		var record RefreshToken
+		if err := json.Unmarshal(value, &record); err == nil && record.FamilyID == familyID {
+			members[key] = record
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for key, record := range members {
+		if record.AccessID != "" && record.AccessExpiresAt.After(now) {
+			if err := s.revocations.Revoke(record.AccessID, record.AccessExpiresAt); err != nil {
+				return err
+			}
+		}
+		if err := s.db.Delete(refreshTokensBucket, key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prune 删除家族已经到期的记录
+func (s *RefreshTokenStore) prune(now time.Time) (int, error) {
+	var expired []string
+	err := s.db.ForEach(refreshTokensBucket, func(key string, value []byte) error {
+		var record RefreshToken
+		if err := json.Unmarshal(value, &record); err != nil || now.After(record.FamilyExpiresAt) {
+			expired = append(expired, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range expired {
+		if err := s.db.Delete(refreshTokensBucket, key); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
+
+func (s *RefreshTokenStore) get(key string) (RefreshToken, error) {
+	var record RefreshToken
+	value, err := s.db.Get(refreshTokensBucket, key)
+	if err != nil {
+		return record, err
+	}
+	err = json.Unmarshal(value, &record)
+	return record, err
+}
+
+func (s *RefreshTokenStore) put(key string, record RefreshToken) error {
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(refreshTokensBucket, key, value)
+}
+
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,80 @@
+package store
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+)
+
+// revocationPruneInterval 为两次清理过期吊销记录之间的最短间隔
+const revocationPruneInterval = 10 * time.Minute
+
+// RevocationStore 记录在到期之前被吊销的 dashboard JWT，以 jti 为键、原到期时间为值。
+// 记录在原到期时间之后已无意义，吊销时每隔 revocationPruneInterval 清理一次。
+type RevocationStore struct {
+	db Store
+
+	mu        sync.Mutex
+	lastPrune time.Time
+}
+
+func NewRevocationStore(db Store) *RevocationStore {
+	return &RevocationStore{db: db}
+}
+
+// Revoke 吊销 jti 对应的 token，直到 expiresAt 为止
+func (s *RevocationStore) Revoke(jti string, expiresAt time.Time) error {
+	if err := s.db.Put(revokedTokensBucket, jti, []byte(expiresAt.UTC().Format(time.RFC3339))); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	due := time.Since(s.lastPrune) >= revocationPruneInterval
+	if due {
+		s.lastPrune = time.Now()
+	}
+	s.mu.Unlock()
+	if !due {
+		return nil
+	}
+	if n, err := s.Prune(time.Now()); err != nil {
+		log.Printf("Failed to prune revoked tokens: %v", err)
+	} else if n > 0 {
+		log.Printf("Pruned %d expired token revocations", n)
+	}
+	return nil
+}
+
+// IsRevoked 报告 jti 是否已被吊销。读取失败时按已吊销处理，宁可让用户重新登录也不放行。
+func (s *RevocationStore) IsRevoked(jti string) bool {
+	_, err := s.db.Get(revokedTokensBucket, jti)
+	if errors.Is(err, ErrNotFound) {
+		return false
+	}
+	if err != nil {
+		log.Printf("Failed to check revocation of token %s: %v", jti, err)
+	}
+	return true
+}
+
+// Prune 删除原到期时间早于 now 的记录
+func (s *RevocationStore) Prune(now time.Time) (int, error) {
+	var expired []string
+	err := s.db.ForEach(revokedTokensBucket, func(jti string, value []byte) error {
+		expiresAt, err := time.Parse(time.RFC3339, string(value))
+		if err != nil || expiresAt.Before(now) {
+			expired = append(expired, jti)
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	for _, jti := range expired {
+		if err := s.db.Delete(revokedTokensBucket, jti); err != nil {
+			return 0, err
+		}
+	}
+	return len(expired), nil
+}
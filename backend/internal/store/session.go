@@ -1,22 +1,41 @@
 package store
 
 import (
+	"encoding/json"
+	"errors"
+	"log"
 	"os"
 	"os/exec"
 	"sync"
+	"syscall"
+	"time"
 )
 
+// endedSessionRetention 为已结束会话的元数据保留时间，期间连接该会话会得到“已结束”而不是“不存在”
+const endedSessionRetention = 24 * time.Hour
+
 // InteractiveSession 保存了一个正在运行的后台命令所需的所有信息
 type InteractiveSession struct {
-	ID       string
-	Username string
-	Cmd      *exec.Cmd
-	Pty      *os.File // 伪终端的引用
-	mu       sync.RWMutex
+	ID        string
+	Username  string
+	StartedAt time.Time
+	Cmd       *exec.Cmd
+	Pty       *os.File // 伪终端的引用
+	mu        sync.RWMutex
 }
 
-// SessionStore 是一个线程安全的内存会话存储，用于交互式会话信息的保存
+// SessionInfo 是交互式会话中可以持久化的元数据，进程和伪终端本身无法跨越后端重启
+type SessionInfo struct {
+	ID        string     `json:"id"`
+	Username  string     `json:"username"`
+	PID       int        `json:"pid"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at,omitempty"`
+}
+
+// SessionStore 是一个线程安全的会话存储。运行中的会话保存在内存中，元数据同时写入 Store。
 type SessionStore struct {
+	db       Store
 	sessions map[string]*InteractiveSession
 	mu       sync.RWMutex
 }
@@ -28,16 +47,51 @@ func (s *InteractiveSession) GetPty() *os.File {
 	return s.Pty
 }
 
-func NewSessionStore() *SessionStore {
-	return &SessionStore{
+// NewSessionStore 创建会话存储。上一次运行遗留的会话随后端进程一起结束了，
+// 这里将它们标记为已结束，并清理超过保留时间的记录。
+func NewSessionStore(db Store) *SessionStore {
+	s := &SessionStore{
+		db:       db,
 		sessions: make(map[string]*InteractiveSession),
 	}
+
+	now := time.Now()
+	var stale []SessionInfo
+	err := db.ForEach(sessionsBucket, func(key string, value []byte) error {
+		var info SessionInfo
+		if err := json.Unmarshal(value, &info); err != nil {
+			log.Printf("Dropping corrupted session record %s: %v", key, err)
+			info.ID = key
+		}
+		stale = append(stale, info)
+		return nil
+	})
+	if err != nil {
+		log.Printf("Failed to load session metadata: %v", err)
+	}
+	for _, info := range stale {
+		switch {
+		case info.Username == "" || (info.EndedAt != nil && now.Sub(*info.EndedAt) > endedSessionRetention):
+			s.deleteInfo(info.ID)
+		case info.EndedAt == nil:
+			log.Printf("Salloc session %s for user %s did not survive the backend restart", info.ID, info.Username)
+			info.EndedAt = &now
+			s.putInfo(info)
+		}
+	}
+	return s
 }
 
 func (s *SessionStore) Add(session *InteractiveSession) {
 	s.mu.Lock()
-	defer s.mu.Unlock()
 	s.sessions[session.ID] = session
+	s.mu.Unlock()
+
+	info := SessionInfo{ID: session.ID, Username: session.Username, StartedAt: session.StartedAt}
+	if session.Cmd != nil && session.Cmd.Process != nil {
+		info.PID = session.Cmd.Process.Pid
+	}
+	s.putInfo(info)
 }
 
 func (s *SessionStore) Get(id string) (*InteractiveSession, bool) {
@@ -47,6 +101,54 @@ func (s *SessionStore) Get(id string) (*InteractiveSession, bool) {
 	return session, ok
 }
 
+// Info 返回会话的持久化元数据，包括已经结束或来自上一次运行的会话
+func (s *SessionStore) Info(id string) (SessionInfo, bool) {
+	value, err := s.db.Get(sessionsBucket, id)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Printf("Failed to read session metadata %s: %v", id, err)
+		}
+		return SessionInfo{}, false
+	}
+	var info SessionInfo
+	if err := json.Unmarshal(value, &info); err != nil {
+		log.Printf("Corrupted session record %s: %v", id, err)
+		return SessionInfo{}, false
+	}
+	return info, true
+}
+
+// MarkEnded 记录会话进程的结束时间
+func (s *SessionStore) MarkEnded(id string) {
+	info, ok := s.Info(id)
+	if !ok {
+		return
+	}
+	now := time.Now()
+	info.EndedAt = &now
+	s.putInfo(info)
+}
+
+// TerminateUser 向用户所有运行中会话的进程发送 SIGTERM，salloc 收到后会释放资源分配并退出。
+// 返回收到信号的会话数，会话随后由各自的等待协程标记为已结束。
+func (s *SessionStore) TerminateUser(username string) int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	count := 0
+	for id, session := range s.sessions {
+		if session.Username != username || session.Cmd == nil || session.Cmd.Process == nil {
+			continue
+		}
+		if err := session.Cmd.Process.Signal(syscall.SIGTERM); err != nil {
+			log.Printf("Failed to terminate session %s for user %s: %v", id, username, err)
+			continue
+		}
+		count++
+	}
+	return count
+}
+
+// Remove 关闭伪终端并将会话移出内存。元数据保留到 endedSessionRetention 之后的下一次启动。
 func (s *SessionStore) Remove(id string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -57,3 +159,19 @@ func (s *SessionStore) Remove(id string) {
 	}
 	delete(s.sessions, id)
 }
+
+func (s *SessionStore) putInfo(info SessionInfo) {
+	value, err := json.Marshal(info)
+	if err == nil {
+		err = s.db.Put(sessionsBucket, info.ID, value)
+	}
+	if err != nil {
+		log.Printf("Failed to persist session metadata %s: %v", info.ID, err)
+	}
+}
+
+func (s *SessionStore) deleteInfo(id string) {
+	if err := s.db.Delete(sessionsBucket, id); err != nil {
+		log.Printf("Failed to delete session metadata %s: %v", id, err)
+	}
+}
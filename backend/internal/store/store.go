@@ -0,0 +1,51 @@
+package store
+
+import (
+	"errors"
+	"log"
+)
+
+var (
+	// ErrNotFound 表示 bucket 中不存在该 key
+	ErrNotFound = errors.New("store: key not found")
+	// ErrBucketNotFound 表示 bucket 尚未由迁移创建
+	ErrBucketNotFound = errors.New("store: bucket not found")
+)
+
+// Store 是按 bucket 划分的键值存储，TokenStore、SessionStore 等类型化存储建立在其之上。
+// bucket 只能由迁移创建，对不存在的 bucket 读写会返回 ErrBucketNotFound。
+type Store interface {
+	// Get 返回值的副本，key 不存在时返回 ErrNotFound
+	Get(bucket, key string) ([]byte, error)
+	Put(bucket, key string, value []byte) error
+	// Delete 删除 key，key 不存在时不报错
+	Delete(bucket, key string) error
+	// ForEach 按 key 顺序遍历 bucket，fn 返回错误时停止遍历并返回该错误。
+	// fn 中不能再调用同一个 Store 的写方法。
+	ForEach(bucket string, fn func(key string, value []byte) error) error
+	CreateBucket(bucket string) error
+	Close() error
+}
+
+// Open 打开 path 处的 bbolt 数据库并执行迁移，path 为空时返回内存存储
+func Open(path string) (Store, error) {
+	var (
+		s   Store
+		err error
+	)
+	if path == "" {
+		log.Println("No store_path configured, Slurm tokens and sessions are kept in memory only")
+		s = NewMemoryStore()
+	} else {
+		s, err = OpenBolt(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := Migrate(s); err != nil {
+		s.Close()
+		return nil, err
+	}
+	return s, nil
+}
@@ -0,0 +1,602 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"slurm-dashboard/internal/secret"
+)
+
+const (
+	testSecret    = "test-token-secret-0123456789abcdef"
+	rotatedSecret = "rotated-token-secret-0123456789abcd"
+)
+
+func testKeyring(t *testing.T, secrets ...string) *secret.Keyring {
+	t.Helper()
+	keyring, err := secret.NewKeyring(secrets, "slurm-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keyring
+}
+
+// openStores 返回内存实现和 bbolt 实现，两者应当满足相同的行为
+func openStores(t *testing.T) map[string]Store {
+	t.Helper()
+	bolt, err := Open(filepath.Join(t.TempDir(), "dashboard.db"))
+	if err != nil {
+		t.Fatalf("failed to open bolt store: %v", err)
+	}
+	t.Cleanup(func() { bolt.Close() })
+	memory, err := Open("")
+	if err != nil {
+		t.Fatalf("failed to open memory store: %v", err)
+	}
+	return map[string]Store{"memory": memory, "bolt": bolt}
+}
+
+func TestStoreContract(t *testing.T) {
+	for name, s := range openStores(t) {
+		t.Run(name, func(t *testing.T) {
+			if _, err := s.Get(slurmTokensBucket, "alice"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound, got %v", err)
+			}
+			if err := s.Put("missing", "k", []byte("v")); !errors.Is(err, ErrBucketNotFound) {
+				t.Fatalf("expected ErrBucketNotFound, got %v", err)
+			}
+
+			for _, key := range []string{"bob", "alice"} {
+				if err := s.Put(slurmTokensBucket, key, []byte("token-"+key)); err != nil {
+					t.Fatal(err)
+				}
+			}
+			value, err := s.Get(slurmTokensBucket, "alice")
+			if err != nil || string(value) != "token-alice" {
+				t.Fatalf("unexpected value %q (%v)", value, err)
+			}
+
+			var keys []string
+			if err := s.ForEach(slurmTokensBucket, func(key string, _ []byte) error {
+				keys = append(keys, key)
+				return nil
+			}); err != nil {
+				t.Fatal(err)
+			}
+			if len(keys) != 2 || keys[0] != "alice" || keys[1] != "bob" {
+				t.Fatalf("expected sorted keys, got %v", keys)
+			}
+
+			if err := s.Delete(slurmTokensBucket, "alice"); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := s.Get(slurmTokensBucket, "alice"); !errors.Is(err, ErrNotFound) {
+				t.Fatalf("expected ErrNotFound after delete, got %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrate(t *testing.T) {
+	s := NewMemoryStore()
+	if err := Migrate(s); err != nil {
+		t.Fatal(err)
+	}
+	version, err := SchemaVersion(s)
+	if err != nil || version != migrations[len(migrations)-1].version {
+		t.Fatalf("unexpected schema version %d (%v)", version, err)
+	}
+	// 重复执行不应报错
+	if err := Migrate(s); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.Put(metaBucket, schemaVersionKey, []byte("999")); err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(s); err == nil {
+		t.Fatal("expected error for a store written by a newer version")
+	}
+}
+
+func TestMigrateExpiresAPIKeys(t *testing.T) {
+	s := NewMemoryStore()
+	if err := Migrate(s); err != nil {
+		t.Fatal(err)
+	}
+	// 版本 6 的存储中创建的 key 可以没有到期时间
+	keys := NewAPIKeyStore(s)
+	key, _, err := keys.Create("alice", "ci", []string{"read"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Put(apiKeysBucket, hashAPIKey(key), []byte(`{"id":"k1","username":"alice","name":"ci","scopes":["read"]}`)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(key); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Fatalf("expected a key without an expiry to be rejected, got %v", err)
+	}
+	if err := s.Put(metaBucket, schemaVersionKey, []byte("6")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Migrate(s); err != nil {
+		t.Fatal(err)
+	}
+	record, err := keys.Authenticate(key)
+	if err != nil || record.ExpiresAt == nil || record.ExpiresAt.After(time.Now().Add(DefaultAPIKeyLifetime)) {
+		t.Fatalf("expected the key to get the default lifetime, got %+v, %v", record, err)
+	}
+}
+
+func TestTokensSurviveRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dashboard.db")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTokenStore(db, testKeyring(t, testSecret)).Set("alice", "slurm-jwt", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	NewSessionStore(db).Add(&InteractiveSession{ID: "s1", Username: "alice", StartedAt: time.Now()})
+	db.Close()
+
+	db, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	if token, ok := NewTokenStore(db, testKeyring(t, testSecret)).Get("alice"); !ok || token != "slurm-jwt" {
+		t.Fatalf("expected token to survive restart, got %q", token)
+	}
+
+	sessions := NewSessionStore(db)
+	if _, ok := sessions.Get("s1"); ok {
+		t.Fatal("running sessions cannot survive a restart")
+	}
+	info, ok := sessions.Info("s1")
+	if !ok || info.Username != "alice" || info.EndedAt == nil {
+		t.Fatalf("expected session metadata to be marked as ended, got %+v", info)
+	}
+}
+
+func TestTokensEncryptedAtRest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dashboard.db")
+	db, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tokens := NewTokenStore(db, testKeyring(t, testSecret))
+	if err := tokens.Set("alice", "plaintext-slurm-jwt", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 明文 token 不能出现在 Store 的值中
+	value, err := db.Get(slurmTokensBucket, "alice")
+	if err != nil || bytes.Contains(value, []byte("plaintext-slurm-jwt")) {
+		t.Fatalf("token is stored in plaintext: %s (%v)", value, err)
+	}
+	// 密文不能被挪用到其他用户名下
+	if err := db.Put(slurmTokensBucket, "mallory", value); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := tokens.Get("mallory"); ok {
+		t.Fatal("a token copied to another user must not decrypt")
+	}
+	db.Close()
+
+	raw, err := os.ReadFile(path)
+	if err != nil || bytes.Contains(raw, []byte("plaintext-slurm-jwt")) {
+		t.Fatalf("database file contains the plaintext token (%v)", err)
+	}
+}
+
+func TestTokenStoreReencrypt(t *testing.T) {
+	db := NewMemoryStore()
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	if err := NewTokenStore(db, testKeyring(t, testSecret)).Set("alice", "alice-jwt", time.Now().Add(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	// 加密功能上线之前写入的明文记录
+	if err := db.Put(slurmTokensBucket, "bob", []byte(`{"token":"bob-jwt"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated := NewTokenStore(db, testKeyring(t, rotatedSecret, testSecret))
+	n, err := rotated.Reencrypt()
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 re-encrypted tokens, got %d (%v)", n, err)
+	}
+	if n, _ := rotated.Reencrypt(); n != 0 {
+		t.Fatalf("second pass should be a no-op, re-encrypted %d", n)
+	}
+
+	// 移除旧密钥后仍然可以读取
+	current := NewTokenStore(db, testKeyring(t, rotatedSecret))
+	for user, want := range map[string]string{"alice": "alice-jwt", "bob": "bob-jwt"} {
+		if token, ok := current.Get(user); !ok || token != want {
+			t.Errorf("expected %s token %q after rotation, got %q", user, want, token)
+		}
+	}
+}
+
+func TestRevocationStorePrunesPeriodically(t *testing.T) {
+	db := NewMemoryStore()
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	revocations := NewRevocationStore(db)
+
+	// 第一次吊销时清理，之后的吊销在间隔内不再扫描整个 bucket
+	if err := revocations.Revoke("expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := revocations.Revoke("also-expired", time.Now().Add(-time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if err := revocations.Revoke("current", time.Now().Add(time.Minute)); err != nil {
+		t.Fatal(err)
+	}
+	if !revocations.IsRevoked("also-expired") || !revocations.IsRevoked("current") {
+		t.Fatal("records written within the prune interval should be kept until the next prune")
+	}
+
+	if n, err := revocations.Prune(time.Now()); err != nil || n != 1 {
+		t.Fatalf("expected one expired revocation to be pruned, got %d, %v", n, err)
+	}
+	if revocations.IsRevoked("also-expired") || !revocations.IsRevoked("current") {
+		t.Error("expected only the expired revocation to be pruned")
+	}
+}
+
+func TestRefreshTokenRotation(t *testing.T) {
+	db, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	revocations := NewRevocationStore(db)
+	refreshTokens := NewRefreshTokenStore(db, revocations)
+
+	now := time.Now()
+	token, err := refreshTokens.Issue(RefreshToken{
+		Username: "alice", FamilyID: "f1", FamilyExpiresAt: now.Add(time.Hour),
+		AccessID: "a1", AccessExpiresAt: now.Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只保存 token 的摘要
+	if err := db.ForEach(refreshTokensBucket, func(key string, value []byte) error {
+		if key == token || bytes.Contains(value, []byte(token)) {
+			t.Error("refresh tokens must only be stored hashed")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Peek 不消费 token
+	if record, err := refreshTokens.Peek(token); err != nil || record.Username != "alice" || record.UsedAt != nil {
+		t.Fatalf("expected peek to return the unused record, got %+v, %v", record, err)
+	}
+	record, err := refreshTokens.Rotate(token)
+	if err != nil || record.Username != "alice" || record.FamilyID != "f1" {
+		t.Fatalf("expected rotation to succeed, got %+v, %v", record, err)
+	}
+	// 刚轮换过的 token 再次提交时只是告知客户端改用新 token
+	if _, err := refreshTokens.Rotate(token); !errors.Is(err, ErrRefreshTokenRaced) {
+		t.Fatalf("expected reuse within the grace window to be reported as a race, got %v", err)
+	}
+	if revocations.IsRevoked("a1") {
+		t.Fatal("a race within the grace window must not revoke the family")
+	}
+	refreshTokens.now = func() time.Time { return time.Now().Add(RefreshReuseGrace) }
+	if _, err := refreshTokens.Rotate(token); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("expected reuse to be detected, got %v", err)
+	}
+	if !revocations.IsRevoked("a1") {
+		t.Error("access tokens of a reused family should be revoked")
+	}
+	if _, err := refreshTokens.Rotate(token); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expected revoked family to be gone, got %v", err)
+	}
+
+	expired, err := refreshTokens.Issue(RefreshToken{Username: "bob", FamilyID: "f2", FamilyExpiresAt: now.Add(-time.Second)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := refreshTokens.Rotate(expired); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expected expired family to be rejected, got %v", err)
+	}
+	if _, err := refreshTokens.Peek(expired); !errors.Is(err, ErrRefreshTokenInvalid) {
+		t.Errorf("expected peek of an expired family to fail, got %v", err)
+	}
+	// 签发时不会每次都扫描整个 bucket，到期的家族留到下一次清理
+	if n, err := refreshTokens.prune(time.Now()); err != nil || n != 1 {
+		t.Errorf("expected the expired family to be pruned, got %d, %v", n, err)
+	}
+}
+
+func TestRefreshTokenConcurrentRotation(t *testing.T) {
+	db, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	revocations := NewRevocationStore(db)
+	refreshTokens := NewRefreshTokenStore(db, revocations)
+	token, err := refreshTokens.Issue(RefreshToken{
+		Username: "alice", FamilyID: "f1", FamilyExpiresAt: time.Now().Add(time.Hour),
+		AccessID: "a1", AccessExpiresAt: time.Now().Add(time.Minute),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 两个标签页同时提交同一个 refresh token：一个成功，另一个被告知改用新 token，登录不被吊销
+	errs := make(chan error, 2)
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := refreshTokens.Rotate(token)
+			errs <- err
+		}()
+	}
+	wg.Wait()
+	close(errs)
+	var succeeded, raced int
+	for err := range errs {
+		switch {
+		case err == nil:
+			succeeded++
+		case errors.Is(err, ErrRefreshTokenRaced):
+			raced++
+		default:
+			t.Errorf("unexpected rotation error %v", err)
+		}
+	}
+	if succeeded != 1 || raced != 1 {
+		t.Fatalf("expected one rotation and one race, got %d and %d", succeeded, raced)
+	}
+	if revocations.IsRevoked("a1") {
+		t.Error("concurrent rotations must not revoke the family")
+	}
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	db, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys := NewAPIKeyStore(db)
+
+	key, record, err := keys.Create("alice", "ci", []string{"read"}, time.Now().Add(DefaultAPIKeyLifetime))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(key, APIKeyPrefix) || !strings.HasPrefix(key, record.Display) {
+		t.Fatalf("unexpected key %q (display %q)", key, record.Display)
+	}
+	// 只保存 key 的摘要
+	if err := db.ForEach(apiKeysBucket, func(k string, value []byte) error {
+		if k == key || bytes.Contains(value, []byte(key)) {
+			t.Error("API keys must only be stored hashed")
+		}
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := keys.Authenticate(key)
+	if err != nil || got.Username != "alice" || !got.HasScope("read") || got.HasScope("submit") || got.LastUsedAt == nil {
+		t.Fatalf("expected the key to authenticate with a last-used time, got %+v, %v", got, err)
+	}
+	if _, err := keys.Authenticate(key + "x"); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("expected an unknown key to be rejected, got %v", err)
+	}
+
+	expiredAt := time.Now().Add(-time.Second)
+	expired, _, err := keys.Create("alice", "old", []string{"read"}, expiredAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(expired); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("expected an expired key to be rejected, got %v", err)
+	}
+
+	if list, err := keys.List("alice"); err != nil || len(list) != 2 || list[0].Name != "ci" {
+		t.Fatalf("unexpected key list %+v, %v", list, err)
+	}
+	if err := keys.Revoke("bob", record.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("users must not revoke keys of other users, got %v", err)
+	}
+	if err := keys.Revoke("alice", record.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keys.Authenticate(key); !errors.Is(err, ErrAPIKeyInvalid) {
+		t.Errorf("expected a revoked key to be rejected, got %v", err)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	db, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	audit := NewAuditLog(db, 24*time.Hour)
+
+	old := time.Now().Add(-2 * time.Hour)
+	entries := []AuditEntry{
+		{Time: old, Event: AuditLoginFailed, Username: "alice", ClientIP: "192.0.2.1"},
+		{Event: AuditLoginFailed, Username: "alice", ClientIP: "192.0.2.1"},
+		{Event: AuditLoginFailed, Username: "bob", ClientIP: "192.0.2.2"},
+		{Event: AuditLockout, Username: "alice", Detail: "5 failed attempts"},
+	}
+	for _, entry := range entries {
+		if err := audit.Record(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	all, err := audit.List(AuditFilter{})
+	if err != nil || len(all) != 4 {
+		t.Fatalf("expected 4 entries, got %+v, %v", all, err)
+	}
+	if all[0].Event != AuditLockout || !all[3].Time.Equal(old.UTC()) {
+		t.Errorf("expected entries newest first, got %+v", all)
+	}
+	if list, _ := audit.List(AuditFilter{Username: "alice", Event: AuditLoginFailed, Limit: 1}); len(list) != 1 || list[0].Time.Equal(old.UTC()) {
+		t.Errorf("unexpected filtered entries %+v", list)
+	}
+
+	if n, err := audit.Prune(time.Now().Add(-time.Hour)); err != nil || n != 1 {
+		t.Fatalf("expected one entry to be pruned, got %d, %v", n, err)
+	}
+	if list, _ := audit.List(AuditFilter{}); len(list) != 3 {
+		t.Errorf("expected 3 entries after pruning, got %d", len(list))
+	}
+}
+
+func TestTOTPStore(t *testing.T) {
+	db, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	totp := NewTOTPStore(db, testKeyring(t, testSecret))
+
+	if status, err := totp.Status("alice"); err != nil || status.Enabled {
+		t.Fatalf("expected TOTP to be disabled for a new user, got %+v, %v", status, err)
+	}
+	if err := totp.Begin("alice", "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	// 未确认的密钥不算启用
+	if status, _ := totp.Status("alice"); status.Enabled {
+		t.Fatal("a pending enrollment must not enable TOTP")
+	}
+	if value, _ := db.Get(totpBucket, "alice"); bytes.Contains(value, []byte("JBSWY3DPEHPK3PXP")) {
+		t.Fatal("TOTP secrets must be stored encrypted")
+	}
+
+	if err := totp.Activate("alice", 100, []string{"abcde-fghij", "klmno-pqrst"}); err != nil {
+		t.Fatal(err)
+	}
+	if secret, enabled, err := totp.Secret("alice"); err != nil || !enabled || secret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("unexpected secret %q (enabled %v, %v)", secret, enabled, err)
+	}
+	if err := totp.Begin("alice", "OTHERSECRET"); !errors.Is(err, ErrTOTPAlreadyEnabled) {
+		t.Errorf("re-enrolling an enabled user must fail, got %v", err)
+	}
+
+	// 同一个时间步只能使用一次
+	if err := totp.UseStep("alice", 100); !errors.Is(err, ErrTOTPReplay) {
+		t.Errorf("expected the activation step to be rejected, got %v", err)
+	}
+	if err := totp.UseStep("alice", 101); err != nil {
+		t.Fatal(err)
+	}
+
+	// 恢复码忽略大小写和分隔符，只能使用一次
+	if remaining, err := totp.UseRecoveryCode("alice", "ABCDEFGHIJ"); err != nil || remaining != 1 {
+		t.Fatalf("expected the recovery code to be accepted, got %d, %v", remaining, err)
+	}
+	if _, err := totp.UseRecoveryCode("alice", "abcde-fghij"); !errors.Is(err, ErrRecoveryCodeInvalid) {
+		t.Errorf("a used recovery code must be rejected, got %v", err)
+	}
+	if status, _ := totp.Status("alice"); !status.Enabled || status.RecoveryCodesRemaining != 1 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	if err := totp.Delete("alice"); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := totp.Secret("alice"); !errors.Is(err, ErrTOTPNotEnrolled) {
+		t.Errorf("expected TOTP to be removed, got %v", err)
+	}
+}
+
+func TestTOTPStoreKeepsUnreadableSecrets(t *testing.T) {
+	db, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := NewTOTPStore(db, testKeyring(t, testSecret))
+	if err := original.Begin("alice", "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := original.Activate("alice", 100, []string{"abcde-fghij"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := original.Begin("bob", "KRSXG5CTMVRXEZLU"); err != nil {
+		t.Fatal(err)
+	}
+
+	// 换成不包含原密钥的密钥后重新打开：已启用的记录必须保留，不能让两步验证失效
+	reopened := NewTOTPStore(db, testKeyring(t, rotatedSecret))
+	if n, err := reopened.Reencrypt(); err != nil || n != 0 {
+		t.Fatalf("expected nothing to be re-encrypted, got %d, %v", n, err)
+	}
+	if status, err := reopened.Status("alice"); err != nil || !status.Enabled {
+		t.Fatalf("an unreadable TOTP record must stay enabled, got %+v, %v", status, err)
+	}
+	if _, _, err := reopened.Secret("alice"); !errors.Is(err, ErrTOTPUnreadable) {
+		t.Errorf("expected ErrTOTPUnreadable, got %v", err)
+	}
+	// 未完成的绑定没有保护任何登录，可以直接删除
+	if _, _, err := reopened.Secret("bob"); !errors.Is(err, ErrTOTPNotEnrolled) {
+		t.Errorf("expected the pending enrollment to be dropped, got %v", err)
+	}
+}
+
+func TestTOTPStoreRekey(t *testing.T) {
+	db, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	totp := NewTOTPStore(db, testKeyring(t, testSecret))
+	// 旧版本以登录时输入的大小写保存记录
+	if err := totp.Begin("Alice", "JBSWY3DPEHPK3PXP"); err != nil {
+		t.Fatal(err)
+	}
+	if err := totp.Activate("Alice", 100, []string{"abcde-fghij"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := totp.Begin("BOB", "KRSXG5CTMVRXEZLU"); err != nil {
+		t.Fatal(err)
+	}
+	if err := totp.Begin("bob", "GEZDGNBVGY3TQOJQ"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := totp.Rekey(strings.ToLower); err != nil || n != 1 {
+		t.Fatalf("expected one record to be moved, got %d, %v", n, err)
+	}
+	secret, enabled, err := totp.Secret("alice")
+	if err != nil || !enabled || secret != "JBSWY3DPEHPK3PXP" {
+		t.Fatalf("expected alice's secret under the normalized name, got %q, %v, %v", secret, enabled, err)
+	}
+	if _, _, err := totp.Secret("Alice"); !errors.Is(err, ErrTOTPNotEnrolled) {
+		t.Errorf("expected the old record to be removed, got %v", err)
+	}
+	// 规范化用户名下已有记录时保留它
+	if secret, _, err := totp.Secret("bob"); err != nil || secret != "GEZDGNBVGY3TQOJQ" {
+		t.Errorf("expected bob's existing record to be kept, got %q, %v", secret, err)
+	}
+}
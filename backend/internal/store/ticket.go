@@ -0,0 +1,78 @@
+package store
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"sync"
+	"time"
+)
+
+// TicketTTL 为 WebSocket 票据的有效期，票据只用于紧接着发起的一次连接
+const TicketTTL = 30 * time.Second
+
+// TicketTargetShell 为登录 shell 的票据目标，salloc 会话的票据目标为会话 ID
+const TicketTargetShell = "shell"
+
+// ErrTicketInvalid 表示票据不存在、已使用、已过期，或与连接的目标、客户端地址不符
+var ErrTicketInvalid = errors.New("websocket ticket is invalid or expired")
+
+// Ticket 是一次 WebSocket 连接的授权，签发时绑定用户、连接目标和客户端地址
+type Ticket struct {
+	Username string
+	Target   string
+	ClientIP string
+	// AccessID 为签发票据时使用的 access token 的 jti，兑换时再次检查其是否已被吊销
+	AccessID  string
+	ExpiresAt time.Time
+}
+
+// TicketStore 在内存中保存一次性的 WebSocket 票据。票据有效期很短，不需要跨越后端重启。
+type TicketStore struct {
+	revocations *RevocationStore
+
+	mu      sync.Mutex
+	tickets map[string]Ticket
+}
+
+func NewTicketStore(revocations *RevocationStore) *TicketStore {
+	return &TicketStore{revocations: revocations, tickets: make(map[string]Ticket)}
+}
+
+// Issue 为 record 生成一个新的票据，ExpiresAt 由 TicketTTL 决定
+func (s *TicketStore) Issue(record Ticket) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	record.ExpiresAt = now.Add(TicketTTL)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, t := range s.tickets {
+		if now.After(t.ExpiresAt) {
+			delete(s.tickets, key)
+		}
+	}
+	s.tickets[ticket] = record
+	return ticket, nil
+}
+
+// Redeem 消费票据并返回其记录。无论校验是否通过，票据都只能被提交一次。
+func (s *TicketStore) Redeem(ticket, target, clientIP string) (Ticket, error) {
+	s.mu.Lock()
+	record, ok := s.tickets[ticket]
+	delete(s.tickets, ticket)
+	s.mu.Unlock()
+
+	if !ok || time.Now().After(record.ExpiresAt) || record.Target != target || record.ClientIP != clientIP {
+		return Ticket{}, ErrTicketInvalid
+	}
+	if record.AccessID != "" && s.revocations.IsRevoked(record.AccessID) {
+		return Ticket{}, ErrTicketInvalid
+	}
+	return record, nil
+}
@@ -1,29 +1,204 @@
 package store
 
-import "sync"
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"time"
 
-// TokenStore 是一个线程安全的内存存储，用于保存 user -> slurm_token 的映射
+	"slurm-dashboard/internal/secret"
+)
+
+// slurmTokenRecord 是 slurm_tokens bucket 中保存的值。token 以用户名为附加数据加密保存，
+// Token 字段只出现在加密功能上线之前写入的记录中，启动时由 Reencrypt 转换。
+type slurmTokenRecord struct {
+	SealedToken string    `json:"sealed_token,omitempty"`
+	Token       string    `json:"token,omitempty"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+	LastUsedAt  time.Time `json:"last_used_at"`
+}
+
+// TokenInfo 描述一个已保存的 Slurm token，不包含 token 本身
+type TokenInfo struct {
+	Username   string
+	ExpiresAt  time.Time
+	UpdatedAt  time.Time
+	LastUsedAt time.Time
+}
+
+// TokenStore 保存 user -> slurm_token 的映射，数据加密后持久化在 Store 中，后端重启后仍然有效。
+// 每次 Get 都视为用户的一次活动，活动时间先记录在内存中，续期时再写回 Store。
 type TokenStore struct {
-	data map[string]string
-	mu   *sync.RWMutex
+	db      Store
+	keyring *secret.Keyring
+
+	// mu 保护 lastUsed，并串行化 Set、Renew 和 Delete 对记录的写入
+	mu       sync.Mutex
+	lastUsed map[string]time.Time
 }
 
-func NewTokenStore() *TokenStore {
-	return &TokenStore{
-		data: make(map[string]string),
-		mu:   &sync.RWMutex{},
-	}
+func NewTokenStore(db Store, keyring *secret.Keyring) *TokenStore {
+	return &TokenStore{db: db, keyring: keyring, lastUsed: make(map[string]time.Time)}
+}
+
+// Set 在用户登录时保存其 Slurm token 及到期时间，并将用户视为刚刚活动过
+func (s *TokenStore) Set(username, slurmToken string, expiresAt time.Time) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.lastUsed[username] = now
+	return s.put(username, slurmToken, slurmTokenRecord{ExpiresAt: expiresAt, UpdatedAt: now, LastUsedAt: now})
 }
 
-func (s *TokenStore) Set(username, slurmToken string) {
+// Renew 替换续期后的 token，保留用户的活动时间，使长期不活动的用户最终被清理。
+// 记录已被删除（用户在续期期间登出）时返回 ErrNotFound，不写回 token
+func (s *TokenStore) Renew(username, slurmToken string, expiresAt time.Time) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.data[username] = slurmToken
+	previous, err := s.get(username)
+	if err != nil {
+		return err
+	}
+	return s.put(username, slurmToken, slurmTokenRecord{
+		ExpiresAt:  expiresAt,
+		UpdatedAt:  time.Now(),
+		LastUsedAt: s.lastActiveLocked(username, previous.LastUsedAt),
+	})
 }
 
+// Get 返回用户的 Slurm token，读取或解密失败时记录日志并视为不存在
 func (s *TokenStore) Get(username string) (string, bool) {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
-	token, ok := s.data[username]
-	return token, ok
+	record, err := s.get(username)
+	if err != nil {
+		if !errors.Is(err, ErrNotFound) {
+			log.Printf("Failed to read Slurm token for user %s: %v", username, err)
+		}
+		return "", false
+	}
+	token, err := s.open(username, record)
+	if err != nil {
+		log.Printf("Failed to decrypt Slurm token for user %s: %v", username, err)
+		return "", false
+	}
+	s.mu.Lock()
+	s.lastUsed[username] = time.Now()
+	s.mu.Unlock()
+	return token, true
+}
+
+func (s *TokenStore) Delete(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.lastUsed, username)
+	return s.db.Delete(slurmTokensBucket, username)
+}
+
+// List 返回所有已保存 token 的到期和活动时间
+func (s *TokenStore) List() ([]TokenInfo, error) {
+	var infos []TokenInfo
+	err := s.db.ForEach(slurmTokensBucket, func(username string, value []byte) error {
+		var record slurmTokenRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			log.Printf("Corrupted Slurm token record for user %s: %v", username, err)
+			return nil
+		}
+		infos = append(infos, TokenInfo{
+			Username:   username,
+			ExpiresAt:  record.ExpiresAt,
+			UpdatedAt:  record.UpdatedAt,
+			LastUsedAt: s.lastActive(username, record.LastUsedAt),
+		})
+		return nil
+	})
+	return infos, err
+}
+
+// Reencrypt 使用当前密钥重新加密所有以旧密钥加密或未加密的 token，返回处理的条数。
+// 已无法解密的 token（例如对应的旧密钥已被移除）会被删除，用户需要重新登录。
+func (s *TokenStore) Reencrypt() (int, error) {
+	records := make(map[string]slurmTokenRecord)
+	err := s.db.ForEach(slurmTokensBucket, func(username string, value []byte) error {
+		var record slurmTokenRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			log.Printf("Corrupted Slurm token record for user %s: %v", username, err)
+			return nil
+		}
+		if record.Token != "" || !s.keyring.IsCurrent(record.SealedToken) {
+			records[username] = record
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for username, record := range records {
+		token, err := s.open(username, record)
+		if err != nil {
+			log.Printf("Dropping Slurm token for user %s that can no longer be decrypted: %v", username, err)
+			if err := s.db.Delete(slurmTokensBucket, username); err != nil {
+				return count, err
+			}
+			continue
+		}
+		if err := s.put(username, token, record); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+func (s *TokenStore) open(username string, record slurmTokenRecord) (string, error) {
+	if record.SealedToken == "" {
+		return record.Token, nil
+	}
+	token, err := s.keyring.Open(record.SealedToken, []byte(username))
+	if err != nil {
+		return "", err
+	}
+	return string(token), nil
+}
+
+// put 加密 slurmToken 后连同 record 中的其他字段一起保存
+func (s *TokenStore) put(username, slurmToken string, record slurmTokenRecord) error {
+	sealed, err := s.keyring.Seal([]byte(slurmToken), []byte(username))
+	if err != nil {
+		return err
+	}
+	record.SealedToken, record.Token = sealed, ""
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(slurmTokensBucket, username, value)
+}
+
+func (s *TokenStore) get(username string) (slurmTokenRecord, error) {
+	var record slurmTokenRecord
+	value, err := s.db.Get(slurmTokensBucket, username)
+	if err != nil {
+		return record, err
+	}
+	if err := json.Unmarshal(value, &record); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// lastActive 返回内存中记录的活动时间与 fallback 中较晚的一个
+func (s *TokenStore) lastActive(username string, fallback time.Time) time.Time {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.lastActiveLocked(username, fallback)
+}
+
+func (s *TokenStore) lastActiveLocked(username string, fallback time.Time) time.Time {
+	if t, ok := s.lastUsed[username]; ok && t.After(fallback) {
+		return t
+	}
+	return fallback
 }
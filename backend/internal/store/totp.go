@@ -0,0 +1,371 @@
+package store
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"slurm-dashboard/internal/secret"
+)
+
+var (
+	// ErrTOTPNotEnrolled 表示用户没有绑定或正在绑定的 TOTP 密钥
+	ErrTOTPNotEnrolled = errors.New("TOTP is not enrolled")
+	// ErrTOTPAlreadyEnabled 表示用户已经启用了 TOTP，需要先停用才能重新绑定
+	ErrTOTPAlreadyEnabled = errors.New("TOTP is already enabled")
+	// ErrTOTPReplay 表示验证码所在的时间步已经使用过
+	ErrTOTPReplay = errors.New("TOTP code has already been used")
+	// ErrRecoveryCodeInvalid 表示恢复码不存在或已使用
+	ErrRecoveryCodeInvalid = errors.New("recovery code is invalid or already used")
+	// ErrTOTPUnreadable 表示保存的密钥无法用当前的密钥解密，通常是加密密钥被更换了。
+	// 这些用户不能再用验证码登录，需要管理员重置后重新绑定
+	ErrTOTPUnreadable = errors.New("TOTP secret can no longer be decrypted")
+	// ErrTOTPUnavailable 表示没有配置 token_encryption_secrets，不能保存新的 TOTP 密钥
+	ErrTOTPUnavailable = errors.New("TOTP requires token_encryption_secrets")
+)
+
+// totpRecord 是 totp bucket 中保存的值，密钥以用户名为附加数据加密保存，恢复码只保存 SHA-256 摘要
+type totpRecord struct {
+	SealedSecret  string     `json:"sealed_secret"`
+	Enabled       bool       `json:"enabled"`
+	RecoveryCodes []string   `json:"recovery_codes,omitempty"`
+	LastStep      int64      `json:"last_step"`
+	CreatedAt     time.Time  `json:"created_at"`
+	EnabledAt     *time.Time `json:"enabled_at,omitempty"`
+}
+
+// TOTPStatus 描述用户的两步验证状态，不包含密钥和恢复码
+type TOTPStatus struct {
+	Enabled                bool       `json:"enabled"`
+	EnabledAt              *time.Time `json:"enabled_at,omitempty"`
+	RecoveryCodesRemaining int        `json:"recovery_codes_remaining"`
+}
+
+// TOTPStore 保存用户的 TOTP 密钥和恢复码。绑定分两步：Begin 保存未启用的密钥，
+// 用户用验证器应用生成的验证码确认后由 Activate 启用。
+// keyring 为 nil 时（没有配置 token_encryption_secrets）不能绑定新的密钥
+type TOTPStore struct {
+	db      Store
+	keyring *secret.Keyring
+
+	// mu 串行化读-改-写，保证同一个时间步和恢复码只能使用一次
+	mu sync.Mutex
+}
+
+func NewTOTPStore(db Store, keyring *secret.Keyring) *TOTPStore {
+	return &TOTPStore{db: db, keyring: keyring}
+}
+
+// Available 判断是否可以绑定新的 TOTP 密钥
+func (s *TOTPStore) Available() bool {
+	return s.keyring != nil
+}
+
+// Count 返回保存的 TOTP 记录数，包括尚未启用的绑定
+func (s *TOTPStore) Count() (int, error) {
+	count := 0
+	err := s.db.ForEach(totpBucket, func(string, []byte) error {
+		count++
+		return nil
+	})
+	return count, err
+}
+
+// Status 返回用户的两步验证状态，没有记录时为未启用
+func (s *TOTPStore) Status(username string) (TOTPStatus, error) {
+	record, err := s.get(username)
+	if errors.Is(err, ErrNotFound) {
+		return TOTPStatus{}, nil
+	}
+	if err != nil {
+		return TOTPStatus{}, err
+	}
+	if !record.Enabled {
+		return TOTPStatus{}, nil
+	}
+	return TOTPStatus{Enabled: true, EnabledAt: record.EnabledAt, RecoveryCodesRemaining: len(record.RecoveryCodes)}, nil
+}
+
+// Begin 保存一个尚未启用的密钥，替换之前未完成的绑定
+func (s *TOTPStore) Begin(username, totpSecret string) error {
+	if !s.Available() {
+		return ErrTOTPUnavailable
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.get(username)
+	if err == nil && record.Enabled {
+		return ErrTOTPAlreadyEnabled
+	}
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+	return s.put(username, totpSecret, totpRecord{CreatedAt: time.Now()})
+}
+
+// Secret 返回用户的密钥及其是否已启用
+func (s *TOTPStore) Secret(username string) (string, bool, error) {
+	record, err := s.get(username)
+	if errors.Is(err, ErrNotFound) {
+		return "", false, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return "", false, err
+	}
+	if !s.Available() {
+		return "", false, ErrTOTPUnreadable
+	}
+	totpSecret, err := s.keyring.Open(record.SealedSecret, []byte(username))
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %v", ErrTOTPUnreadable, err)
+	}
+	return string(totpSecret), record.Enabled, nil
+}
+
+// Activate 启用用户正在绑定的密钥，step 为确认时使用的时间步，recoveryCodes 为新生成的恢复码
+func (s *TOTPStore) Activate(username string, step int64, recoveryCodes []string) error {
+	return s.update(username, func(record *totpRecord) error {
+		if record.Enabled {
+			return ErrTOTPAlreadyEnabled
+		}
+		now := time.Now()
+		record.Enabled = true
+		record.EnabledAt = &now
+		record.LastStep = step
+		record.RecoveryCodes = hashRecoveryCodes(recoveryCodes)
+		return nil
+	})
+}
+
+// UseStep 记录一次验证成功的时间步，不大于上次使用的时间步时返回 ErrTOTPReplay
+func (s *TOTPStore) UseStep(username string, step int64) error {
+	return s.update(username, func(record *totpRecord) error {
+		if !record.Enabled {
+			return ErrTOTPNotEnrolled
+		}
+		if step <= record.LastStep {
+			return ErrTOTPReplay
+		}
+		record.LastStep = step
+		return nil
+	})
+}
+
+// UseRecoveryCode 消耗一个恢复码，返回剩余的恢复码个数
+func (s *TOTPStore) UseRecoveryCode(username, code string) (int, error) {
+	hash := hashRecoveryCode(code)
+	remaining := 0
+	err := s.update(username, func(record *totpRecord) error {
+		if !record.Enabled {
+			return ErrTOTPNotEnrolled
+		}
+		for i, stored := range record.RecoveryCodes {
+			if subtle.ConstantTimeCompare([]byte(stored), []byte(hash)) == 1 {
+				record.RecoveryCodes = append(record.RecoveryCodes[:i], record.RecoveryCodes[i+1:]...)
+				remaining = len(record.RecoveryCodes)
+				return nil
+			}
+		}
+		return ErrRecoveryCodeInvalid
+	})
+	return remaining, err
+}
+
+// ReplaceRecoveryCodes 用新生成的恢复码替换所有未使用的恢复码
+func (s *TOTPStore) ReplaceRecoveryCodes(username string, recoveryCodes []string) error {
+	return s.update(username, func(record *totpRecord) error {
+		if !record.Enabled {
+			return ErrTOTPNotEnrolled
+		}
+		record.RecoveryCodes = hashRecoveryCodes(recoveryCodes)
+		return nil
+	})
+}
+
+// Delete 停用并删除用户的 TOTP 密钥和恢复码
+func (s *TOTPStore) Delete(username string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.db.Delete(totpBucket, username)
+}
+
+// Reencrypt 使用当前密钥重新加密以旧密钥加密的 TOTP 密钥，返回处理的条数。
+// 已启用但无法解密的记录会保留，这些用户的两步验证登录会被拒绝，直到管理员重置；
+// 静默删除会让要求两步验证的用户在没有第二因素的情况下重新绑定。未完成的绑定直接删除。
+func (s *TOTPStore) Reencrypt() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make(map[string]totpRecord)
+	err := s.db.ForEach(totpBucket, func(username string, value []byte) error {
+		var record totpRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			log.Printf("Corrupted TOTP record for user %s: %v", username, err)
+			return nil
+		}
+		if !s.keyring.IsCurrent(record.SealedSecret) {
+			records[username] = record
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for username, record := range records {
+		totpSecret, err := s.keyring.Open(record.SealedSecret, []byte(username))
+		if err != nil && record.Enabled {
+			log.Printf("TOTP secret of user %s can no longer be decrypted, two-factor logins are rejected until an admin resets it: %v", username, err)
+			continue
+		}
+		if err != nil {
+			log.Printf("Dropping pending TOTP enrollment of user %s that can no longer be decrypted: %v", username, err)
+			if err := s.db.Delete(totpBucket, username); err != nil {
+				return count, err
+			}
+			continue
+		}
+		if err := s.put(username, string(totpSecret), record); err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
+
+// Rekey 将以 normalize 之前的用户名保存的记录移到规范化的用户名下，并以新的用户名重新加密密钥，
+// 返回移动的记录数。同一用户有多条记录时保留已启用的一条，都已启用时保留原本就在规范化用户名下的记录。
+// 无法解密的已启用记录原样移动，登录时按 ErrTOTPUnreadable 拒绝，而不是让该用户跳过两步验证
+func (s *TOTPStore) Rekey(normalize func(username string) string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	records := make(map[string]totpRecord)
+	err := s.db.ForEach(totpBucket, func(username string, value []byte) error {
+		if normalize(username) == username {
+			return nil
+		}
+		var record totpRecord
+		if err := json.Unmarshal(value, &record); err != nil {
+			log.Printf("Corrupted TOTP record for user %s: %v", username, err)
+			return nil
+		}
+		records[username] = record
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for username, record := range records {
+		target := normalize(username)
+		existing, err := s.get(target)
+		switch {
+		case err == nil && (existing.Enabled || !record.Enabled):
+			log.Printf("Dropping TOTP record of user %s, %s already has one", username, target)
+		case err != nil && !errors.Is(err, ErrNotFound):
+			return count, err
+		default:
+			moved, err := s.move(username, target, record)
+			if err != nil {
+				return count, err
+			}
+			if moved {
+				count++
+			}
+		}
+		if err := s.db.Delete(totpBucket, username); err != nil {
+			return count, err
+		}
+	}
+	return count, nil
+}
+
+// move 以 target 为附加数据重新加密 username 的记录并保存到 target 下，无法解密的未启用记录被丢弃
+func (s *TOTPStore) move(username, target string, record totpRecord) (bool, error) {
+	totpSecret, err := s.keyring.Open(record.SealedSecret, []byte(username))
+	if err == nil {
+		return true, s.put(target, string(totpSecret), record)
+	}
+	if !record.Enabled {
+		log.Printf("Dropping pending TOTP enrollment of user %s that can no longer be decrypted: %v", username, err)
+		return false, nil
+	}
+	log.Printf("TOTP secret of user %s can no longer be decrypted, moving it to %s unchanged: %v", username, target, err)
+	value, err := json.Marshal(record)
+	if err != nil {
+		return false, err
+	}
+	return true, s.db.Put(totpBucket, target, value)
+}
+
+// update 在持有 mu 的情况下读取、修改并写回用户的记录，fn 返回错误时不写回
+func (s *TOTPStore) update(username string, fn func(record *totpRecord) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	record, err := s.get(username)
+	if errors.Is(err, ErrNotFound) {
+		return ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+	if err := fn(&record); err != nil {
+		return err
+	}
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(totpBucket, username, value)
+}
+
+// put 加密 totpSecret 后连同 record 中的其他字段一起保存
+func (s *TOTPStore) put(username, totpSecret string, record totpRecord) error {
+	sealed, err := s.keyring.Seal([]byte(totpSecret), []byte(username))
+	if err != nil {
+		return err
+	}
+	record.SealedSecret = sealed
+	value, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+	return s.db.Put(totpBucket, username, value)
+}
+
+func (s *TOTPStore) get(username string) (totpRecord, error) {
+	var record totpRecord
+	value, err := s.db.Get(totpBucket, username)
+	if err != nil {
+		return record, err
+	}
+	if err := json.Unmarshal(value, &record); err != nil {
+		return record, err
+	}
+	return record, nil
+}
+
+// hashRecoveryCode 忽略恢复码的大小写和 "-" 后计算摘要
+func hashRecoveryCode(code string) string {
+	code = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(code)), "-", "")
+	sum := sha256.Sum256([]byte(code))
+	return hex.EncodeToString(sum[:])
+}
+
+func hashRecoveryCodes(codes []string) []string {
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = hashRecoveryCode(code)
+	}
+	return hashes
+}
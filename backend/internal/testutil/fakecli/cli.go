@@ -0,0 +1,122 @@
+// Package fakecli 提供 services.Executor 的假实现，模拟 scontrol/sacctmgr/squeue 等 Slurm 命令。
+package fakecli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"slurm-dashboard/internal/services"
+)
+
+// ErrPTYNotSupported 表示假执行器不会真正启动伪终端进程
+var ErrPTYNotSupported = errors.New("fakecli: pty processes are not supported")
+
+// Handler 处理一次命令调用，args 为命令参数（不含程序名）。
+// 返回的错误会被视为命令以状态码 1 退出。
+type Handler func(username string, args []string) (string, error)
+
+// Call 记录了一次命令调用
+type Call struct {
+	Username string
+	Program  string
+	Args     []string
+	Env      []string
+	// Stdin 为请求中标准输入的全部内容
+	Stdin string
+}
+
+// String 返回命令行的可读形式
+func (c Call) String() string {
+	return strings.Join(append([]string{c.Program}, c.Args...), " ")
+}
+
+// CLI 按程序名分发命令，未注册的程序会以状态码 127 退出
+type CLI struct {
+	mu       sync.Mutex
+	handlers map[string]Handler
+	calls    []Call
+	sessions []string
+}
+
+var _ services.Executor = (*CLI)(nil)
+
+func New() *CLI {
+	return &CLI{handlers: make(map[string]Handler)}
+}
+
+// Handle 注册程序 program 的处理函数
+func (c *CLI) Handle(program string, h Handler) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.handlers[program] = h
+}
+
+// Output 注册一个总是返回固定输出的程序
+func (c *CLI) Output(program, output string) {
+	c.Handle(program, func(string, []string) (string, error) {
+		return output, nil
+	})
+}
+
+// Calls 返回已记录调用的副本
+func (c *CLI) Calls() []Call {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]Call(nil), c.calls...)
+}
+
+func (c *CLI) Run(ctx context.Context, req services.Request) (*services.Result, error) {
+	call := Call{Username: req.Username, Program: req.Program, Args: req.Args, Env: req.Env}
+	if req.Stdin != nil {
+		stdin, err := io.ReadAll(req.Stdin)
+		if err != nil {
+			return nil, err
+		}
+		call.Stdin = string(stdin)
+	}
+
+	c.mu.Lock()
+	c.calls = append(c.calls, call)
+	h := c.handlers[req.Program]
+	c.mu.Unlock()
+
+	if h == nil {
+		result := &services.Result{Stderr: req.Program + ": command not found", ExitCode: 127}
+		return result, fmt.Errorf("fakecli: no handler for %q", req.String())
+	}
+	if err := ctx.Err(); err != nil {
+		return &services.Result{ExitCode: -1}, fmt.Errorf("%w: %v", services.ErrTimeout, err)
+	}
+
+	output, err := h(req.Username, req.Args)
+	if err != nil {
+		return &services.Result{Stderr: output, ExitCode: 1}, err
+	}
+	return &services.Result{Stdout: output}, nil
+}
+
+func (c *CLI) StartPTY(req services.PTYRequest) (*exec.Cmd, *os.File, error) {
+	c.mu.Lock()
+	c.calls = append(c.calls, Call{Username: req.Username, Program: req.Program, Args: req.Args, Env: req.Env})
+	c.mu.Unlock()
+	return nil, nil, ErrPTYNotSupported
+}
+
+func (c *CLI) BeginSession(username string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sessions = append(c.sessions, username)
+}
+
+// Sessions 返回调用过 BeginSession 的用户名
+func (c *CLI) Sessions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.sessions...)
+}
@@ -0,0 +1,248 @@
+// Package fakeldap 提供一个进程内的最小 LDAP 服务器，只支持简单绑定和用户、组的搜索，用于测试 LDAP 客户端。
+package fakeldap
+
+import (
+	"net"
+	"regexp"
+	"strconv"
+	"sync"
+	"testing"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	// BaseDN 为假目录的根，用户位于 ou=people，组位于 ou=groups
+	BaseDN = "dc=example,dc=com"
+	// AdminDN 和 AdminPassword 为服务账号
+	AdminDN       = "cn=admin," + BaseDN
+	AdminPassword = "admin-secret"
+)
+
+var (
+	uidFilter       = regexp.MustCompile(`\(uid=([^)]*)\)`)
+	memberUIDFilter = regexp.MustCompile(`\(memberUid=([^)]*)\)`)
+)
+
+type user struct {
+	password string
+	groups   []string
+}
+
+// Server 是一个可编排用户和组的假 LDAP 服务器
+type Server struct {
+	listener net.Listener
+
+	mu          sync.Mutex
+	users       map[string]user
+	conns       map[net.Conn]struct{}
+	connections int
+	binds       int
+}
+
+// New 启动一个假服务器，测试结束时自动关闭
+func New(t *testing.T) *Server {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &Server{listener: l, users: make(map[string]user), conns: make(map[net.Conn]struct{})}
+	go s.serve()
+	t.Cleanup(s.Close)
+	return s
+}
+
+// Addr 返回服务器的 host:port
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Host 和 Port 分别返回服务器地址的两个部分
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr())
+	return host
+}
+
+func (s *Server) Port() int {
+	_, port, _ := net.SplitHostPort(s.Addr())
+	n, _ := strconv.Atoi(port)
+	return n
+}
+
+// AddUser 登记一个用户及其所属的组
+func (s *Server) AddUser(uid, password string, groups ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.users[uid] = user{password: password, groups: groups}
+}
+
+// Connections 返回服务器累计接受的连接数
+func (s *Server) Connections() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.connections
+}
+
+// Binds 返回服务器累计收到的绑定请求数
+func (s *Server) Binds() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.binds
+}
+
+// Close 停止监听并断开所有连接，模拟服务器宕机
+func (s *Server) Close() {
+	s.listener.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		s.mu.Lock()
+		s.conns[conn] = struct{}{}
+		s.connections++
+		s.mu.Unlock()
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer func() {
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+		conn.Close()
+	}()
+
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		messageID, _ := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			code := s.bind(op)
+			writeResult(conn, messageID, ldap.ApplicationBindResponse, code)
+		case ldap.ApplicationSearchRequest:
+			for _, entry := range s.search(op) {
+				writeEntry(conn, messageID, entry)
+			}
+			writeResult(conn, messageID, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)
+		case ldap.ApplicationUnbindRequest:
+			return
+		default:
+			writeResult(conn, messageID, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError)
+		}
+	}
+}
+
+func (s *Server) bind(op *ber.Packet) uint16 {
+	if len(op.Children) < 3 {
+		return ldap.LDAPResultProtocolError
+	}
+	dn, _ := op.Children[1].Value.(string)
+	password := op.Children[2].Data.String()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.binds++
+	if dn == AdminDN && password == AdminPassword {
+		return ldap.LDAPResultSuccess
+	}
+	for uid, u := range s.users {
+		if dn == userDN(uid) && password == u.password {
+			return ldap.LDAPResultSuccess
+		}
+	}
+	return ldap.LDAPResultInvalidCredentials
+}
+
+// search 只识别 (uid=...) 的用户搜索和 (memberUid=...) 的组搜索
+func (s *Server) search(op *ber.Packet) []*ldap.Entry {
+	if len(op.Children) < 7 {
+		return nil
+	}
+	filter, err := ldap.DecompileFilter(op.Children[6])
+	if err != nil {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if m := memberUIDFilter.FindStringSubmatch(filter); m != nil {
+		var entries []*ldap.Entry
+		for _, group := range s.users[m[1]].groups {
+			entries = append(entries, ldap.NewEntry(groupDN(group), map[string][]string{"cn": {group}}))
+		}
+		return entries
+	}
+	if m := uidFilter.FindStringSubmatch(filter); m != nil {
+		u, ok := s.users[m[1]]
+		if !ok {
+			return nil
+		}
+		memberOf := make([]string, 0, len(u.groups))
+		for _, group := range u.groups {
+			memberOf = append(memberOf, groupDN(group))
+		}
+		return []*ldap.Entry{ldap.NewEntry(userDN(m[1]), map[string][]string{"uid": {m[1]}, "memberOf": memberOf})}
+	}
+	return nil
+}
+
+func userDN(uid string) string {
+	return "uid=" + uid + ",ou=people," + BaseDN
+}
+
+func groupDN(group string) string {
+	return "cn=" + group + ",ou=groups," + BaseDN
+}
+
+func envelope(messageID int64) *ber.Packet {
+	packet := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, messageID, "MessageID"))
+	return packet
+}
+
+func writeResult(conn net.Conn, messageID int64, tag ber.Tag, code uint16) {
+	packet := envelope(messageID)
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "resultCode"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "matchedDN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "diagnosticMessage"))
+	packet.AppendChild(result)
+	conn.Write(packet.Bytes())
+}
+
+func writeEntry(conn net.Conn, messageID int64, entry *ldap.Entry) {
+	packet := envelope(messageID)
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.DN, "objectName"))
+	attributes := ber.NewSequence("attributes")
+	for _, attr := range entry.Attributes {
+		seq := ber.NewSequence("attribute")
+		seq.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, attr.Name, "type"))
+		values := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "values")
+		for _, value := range attr.Values {
+			values.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "value"))
+		}
+		seq.AppendChild(values)
+		attributes.AppendChild(seq)
+	}
+	result.AppendChild(attributes)
+	packet.AppendChild(result)
+	conn.Write(packet.Bytes())
+}
@@ -0,0 +1,242 @@
+// Package fakeslurm 提供一个进程内的假 slurmrestd，用于在没有 Slurm 的机器上测试处理器和客户端。
+package fakeslurm
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"slurm-dashboard/internal/models"
+)
+
+// Request 记录了假服务器收到的一次请求
+type Request struct {
+	Method   string
+	Path     string
+	Username string
+	Body     string
+}
+
+// Server 是一个可编排状态的假 slurmrestd。
+// 测试通过 AddJob/AddNode/AddPartition/SetToken 等方法准备数据，再通过 Requests 检查调用情况。
+type Server struct {
+	*httptest.Server
+
+	mu         sync.Mutex
+	versions   []string
+	tokens     map[string]string
+	jobs       []models.SlurmJobInfo
+	nodes      []models.SlurmNodeInfo
+	partitions []models.SlurmPartitionInfo
+	nextJobID  uint32
+	failures   map[string]int
+	drops      map[string]int
+	requests   []Request
+}
+
+// New 启动一个假 slurmrestd，默认只提供 v0.0.42，测试结束时自动关闭
+func New(t testing.TB) *Server {
+	s := &Server{
+		versions:  []string{"v0.0.42"},
+		tokens:    make(map[string]string),
+		failures:  make(map[string]int),
+		drops:     make(map[string]int),
+		nextJobID: 1000,
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	t.Cleanup(s.Close)
+	return s
+}
+
+// SetVersions 设置 /openapi/v3 中声明的 API 版本
+func (s *Server) SetVersions(versions ...string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions = versions
+}
+
+// SetToken 登记某个用户的有效 Slurm token，未登记的用户请求会得到 401
+func (s *Server) SetToken(username, token string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[username] = token
+}
+
+func (s *Server) AddJob(job models.SlurmJobInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs = append(s.jobs, job)
+}
+
+func (s *Server) AddNode(node models.SlurmNodeInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.nodes = append(s.nodes, node)
+}
+
+func (s *Server) AddPartition(partition models.SlurmPartitionInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.partitions = append(s.partitions, partition)
+}
+
+// Jobs 返回当前的作业列表副本
+func (s *Server) Jobs() []models.SlurmJobInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]models.SlurmJobInfo(nil), s.jobs...)
+}
+
+// FailNext 让接下来 n 次路径以 endpoint 结尾的请求返回 status
+func (s *Server) FailNext(endpoint string, status, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.failures[endpoint+"#"+strconv.Itoa(status)] = n
+}
+
+// DropNext 让接下来 n 次路径以 endpoint 结尾的请求照常处理，但不返回响应而直接断开连接，模拟响应丢失
+func (s *Server) DropNext(endpoint string, n int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.drops[endpoint] = n
+}
+
+// Requests 返回已收到请求的副本
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	body, _ := io.ReadAll(r.Body)
+	username := r.Header.Get("X-SLURM-USER-NAME")
+	s.requests = append(s.requests, Request{Method: r.Method, Path: r.URL.Path, Username: username, Body: string(body)})
+
+	if r.URL.Path == "/openapi/v3" {
+		paths := make(map[string]interface{})
+		for _, v := range s.versions {
+			for _, p := range []string{"/ping", "/jobs", "/job/{job_id}", "/job/submit", "/nodes", "/partitions"} {
+				paths["/slurm/"+v+p] = map[string]interface{}{}
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"paths": paths})
+		return
+	}
+
+	version, endpoint, ok := s.splitPath(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	for key, n := range s.failures {
+		parts := strings.SplitN(key, "#", 2)
+		if n > 0 && strings.HasSuffix(endpoint, parts[0]) {
+			s.failures[key] = n - 1
+			status, _ := strconv.Atoi(parts[1])
+			writeError(w, status, "injected failure")
+			return
+		}
+	}
+
+	for suffix, n := range s.drops {
+		if n > 0 && strings.HasSuffix(endpoint, suffix) {
+			s.drops[suffix] = n - 1
+			defer closeConnection(w)
+			w = httptest.NewRecorder()
+			break
+		}
+	}
+
+	if token, ok := s.tokens[username]; !ok || token != r.Header.Get("X-SLURM-USER-TOKEN") {
+		writeError(w, http.StatusUnauthorized, "Authentication failure")
+		return
+	}
+
+	switch {
+	case endpoint == "/ping" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"meta": map[string]interface{}{"plugin": map[string]string{"data_parser": "data_parser/" + version}},
+		})
+	case endpoint == "/jobs" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, models.SlurmJobResponse{Jobs: s.jobs})
+	case endpoint == "/nodes" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, models.SlurmNodeResponse{Nodes: s.nodes})
+	case endpoint == "/partitions" && r.Method == http.MethodGet:
+		writeJSON(w, http.StatusOK, models.SlurmPartitionResponse{Partitions: s.partitions})
+	case (endpoint == "/job/submit" || endpoint == "/job/allocate") && r.Method == http.MethodPost:
+		s.nextJobID++
+		s.jobs = append(s.jobs, models.SlurmJobInfo{JobID: s.nextJobID, UserName: username, JobState: models.SlurmStringList{"PENDING"}})
+		writeJSON(w, http.StatusOK, models.SlurmJobSubmitResponse{JobID: int32(s.nextJobID)})
+	case strings.HasPrefix(endpoint, "/job/"):
+		s.serveJob(w, r, strings.TrimPrefix(endpoint, "/job/"))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveJob(w http.ResponseWriter, r *http.Request, jobID string) {
+	for i, job := range s.jobs {
+		if strconv.FormatUint(uint64(job.JobID), 10) != jobID {
+			continue
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, models.SlurmJobResponse{Jobs: []models.SlurmJobInfo{job}})
+		case http.MethodDelete:
+			s.jobs[i].JobState = models.SlurmStringList{"CANCELLED"}
+			writeJSON(w, http.StatusOK, models.SlurmBasicResponse{})
+		default:
+			http.NotFound(w, r)
+		}
+		return
+	}
+	writeJSON(w, http.StatusNotFound, models.SlurmBasicResponse{Errors: []models.SlurmError{{
+		Description: "Job " + jobID + " not found", ErrorNumber: 2017, Error: "Invalid job id specified",
+	}}})
+}
+
+// splitPath 将 /slurm/v0.0.42/jobs 拆分为版本和接口路径，版本不在 SetVersions 中时返回 false
+func (s *Server) splitPath(path string) (string, string, bool) {
+	if !strings.HasPrefix(path, "/slurm/") {
+		return "", "", false
+	}
+	parts := strings.SplitN(strings.TrimPrefix(path, "/slurm/"), "/", 2)
+	if len(parts) != 2 {
+		return "", "", false
+	}
+	for _, v := range s.versions {
+		if v == parts[0] {
+			return parts[0], "/" + parts[1], true
+		}
+	}
+	return "", "", false
+}
+
+// closeConnection 不写响应直接关闭连接
+func closeConnection(w http.ResponseWriter) {
+	if hj, ok := w.(http.Hijacker); ok {
+		if conn, _, err := hj.Hijack(); err == nil {
+			conn.Close()
+		}
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, description string) {
+	writeJSON(w, status, models.SlurmBasicResponse{Errors: []models.SlurmError{{Description: description}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -4,6 +4,7 @@ import { AttachAddon } from "@xterm/addon-attach";
 import { FitAddon } from "@xterm/addon-fit";
 import "@xterm/xterm/css/xterm.css";
 import "./Terminal.css";
+import apiService from "../services/api";
 
 // Dracula 德古拉主题颜色
 const draculaTheme = {
@@ -29,14 +30,14 @@ const draculaTheme = {
     brightWhite: "#ffffff",
 };
 
-const InteractiveTerminal = ({ sessionId, token, isActive }) => {
+const InteractiveTerminal = ({ sessionId, isActive }) => {
     const terminalRef = useRef(null);
     const termInstance = useRef(null);
     const fitAddonInstance = useRef(null);
 
     // 初始化和连接逻辑
     useEffect(() => {
-        if (!sessionId || !token || !terminalRef.current || termInstance.current) {
+        if (!sessionId || !terminalRef.current || termInstance.current) {
             return;
         }
 
@@ -55,14 +56,20 @@ const InteractiveTerminal = ({ sessionId, token, isActive }) => {
         termInstance.current = term;
         term.loadAddon(fitAddonInstance.current);
 
-        // 2. 创建 WebSocket 连接
-        // 根据后端路由，这里的 URL 需要包含 session_id 和 token
-        const wsUrl = `${import.meta.env.VITE_WS_BASE_URL}/v1/salloc/interactive/${sessionId}/attach?token=${token}`;
-        const socket = new WebSocket(wsUrl);
-
-        // 3. 附加 WebSocket 到终端
-        const attachAddon = new AttachAddon(socket);
-        term.loadAddon(attachAddon);
+        // 2. 获取该会话的一次性票据并创建 WebSocket 连接，然后附加到终端
+        let socket = null;
+        let cancelled = false;
+        apiService
+            .getWebSocketTicket("salloc", sessionId)
+            .then((ticket) => {
+                if (cancelled) return;
+                const wsUrl = `${import.meta.env.VITE_WS_BASE_URL}/v1/salloc/interactive/${sessionId}/attach?ticket=${ticket}`;
+                socket = new WebSocket(wsUrl);
+                term.loadAddon(new AttachAddon(socket));
+            })
+            .catch(() => {
+                term.write("Error: failed to attach to the interactive session.\r\n");
+            });
 
         // 4. 打开终端并适应容器大小
         term.open(terminalRef.current);
@@ -75,12 +82,13 @@ const InteractiveTerminal = ({ sessionId, token, isActive }) => {
 
         // 5. 清理函数
         return () => {
+            cancelled = true;
             window.removeEventListener("resize", handleResize);
-            socket.close();
+            socket?.close();
             term.dispose();
             termInstance.current = null;
         };
-    }, [sessionId, token]);
+    }, [sessionId]);
 
     // 当标签页激活时，调整终端大小
     useEffect(() => {
@@ -0,0 +1,158 @@
+import { useState, useEffect, useRef } from "react";
+import { Box, Typography, TextField, Button, Alert, CircularProgress, Paper } from "@mui/material";
+import { useAuth } from "../contexts/AuthContext";
+import apiService from "../services/api";
+
+/**
+ * @description 恢复码列表，只在生成后显示一次，提示用户妥善保存。
+ */
+export function RecoveryCodeList({ codes }) {
+    return (
+        <Box sx={{ width: "100%" }}>
+            <Alert severity="warning" sx={{ mb: 2 }}>
+                请将以下恢复码保存在安全的地方。丢失验证器时每个恢复码可以代替验证码登录一次，关闭后无法再次查看。
+            </Alert>
+            <Paper variant="outlined" sx={{ p: 2, display: "grid", gridTemplateColumns: "1fr 1fr", gap: 1 }}>
+                {codes.map((code) => (
+                    <Typography key={code} sx={{ fontFamily: "monospace" }}>
+                        {code}
+                    </Typography>
+                ))}
+            </Paper>
+            <Button sx={{ mt: 1 }} onClick={() => navigator.clipboard?.writeText(codes.join("\n"))}>
+                复制恢复码
+            </Button>
+        </Box>
+    );
+}
+
+/**
+ * @description 绑定验证器时显示的二维码和密钥。
+ */
+export function TOTPEnrollmentInfo({ enrollment }) {
+    return (
+        <Box sx={{ display: "flex", flexDirection: "column", alignItems: "center", width: "100%" }}>
+            <Typography variant="body2" sx={{ mb: 1 }}>
+                使用验证器应用（如 Google Authenticator、Microsoft Authenticator）扫描二维码，或手动输入密钥：
+            </Typography>
+            <img src={enrollment.qr_code} alt="TOTP QR code" width={200} height={200} />
+            <Typography sx={{ fontFamily: "monospace", wordBreak: "break-all", mt: 1 }}>{enrollment.secret}</Typography>
+        </Box>
+    );
+}
+
+/**
+ * @description 登录的第二步：输入验证器应用中的验证码或恢复码。
+ * 角色要求两步验证但尚未绑定时，先生成密钥供用户绑定，完成后展示恢复码。
+ */
+function MFAVerification({ mfaToken, enrollmentRequired, onSuccess, onCancel }) {
+    const { verifyMFA } = useAuth();
+    const [code, setCode] = useState("");
+    const [error, setError] = useState("");
+    const [expired, setExpired] = useState(false);
+    const [loading, setLoading] = useState(false);
+    const [enrollment, setEnrollment] = useState(null);
+    const [recovery, setRecovery] = useState(null);
+    // 开发模式下 effect 会执行两次，避免生成两次密钥
+    const enrollStarted = useRef(false);
+
+    useEffect(() => {
+        if (!enrollmentRequired || enrollStarted.current) {
+            return;
+        }
+        enrollStarted.current = true;
+        apiService
+            .startMFALoginEnrollment(mfaToken)
+            .then(setEnrollment)
+            .catch((error) => {
+                setError(error.response?.data?.error || "生成两步验证密钥失败，请重新登录");
+                setExpired(true);
+            });
+    }, [mfaToken, enrollmentRequired]);
+
+    const handleSubmit = async (e) => {
+        e.preventDefault();
+        if (!code.trim()) {
+            setError("请输入验证码");
+            return;
+        }
+        setLoading(true);
+        const result = await verifyMFA(mfaToken, code.trim());
+        setLoading(false);
+        if (!result.success) {
+            setError(result.message);
+            setExpired(!!result.expired);
+            setCode("");
+            return;
+        }
+        if (result.recoveryCodes) {
+            setRecovery(result);
+            return;
+        }
+        onSuccess();
+    };
+
+    if (recovery) {
+        return (
+            <Box sx={{ mt: 2, width: "100%" }}>
+                <RecoveryCodeList codes={recovery.recoveryCodes} />
+                <Button
+                    fullWidth
+                    variant="contained"
+                    sx={{ mt: 2 }}
+                    onClick={() => {
+                        recovery.finish();
+                        onSuccess();
+                    }}
+                >
+                    我已保存恢复码，继续
+                </Button>
+            </Box>
+        );
+    }
+
+    return (
+        <Box component="form" onSubmit={handleSubmit} noValidate sx={{ mt: 2, width: "100%" }}>
+            {enrollmentRequired && (
+                <>
+                    <Alert severity="info" sx={{ mb: 2 }}>
+                        您的账户要求启用两步验证，请先绑定验证器应用。
+                    </Alert>
+                    {enrollment ? <TOTPEnrollmentInfo enrollment={enrollment} /> : !expired && <CircularProgress />}
+                </>
+            )}
+            {error && (
+                <Alert severity="error" sx={{ mt: 2 }}>
+                    {error}
+                </Alert>
+            )}
+            {expired ? (
+                <Button fullWidth variant="contained" sx={{ mt: 3 }} onClick={onCancel}>
+                    重新登录
+                </Button>
+            ) : (
+                <>
+                    <TextField
+                        margin="normal"
+                        required
+                        fullWidth
+                        autoFocus
+                        label={enrollmentRequired ? "验证码" : "验证码或恢复码"}
+                        autoComplete="one-time-code"
+                        value={code}
+                        onChange={(e) => setCode(e.target.value)}
+                        disabled={enrollmentRequired && !enrollment}
+                    />
+                    <Button type="submit" fullWidth variant="contained" sx={{ mt: 2, mb: 1 }} disabled={loading}>
+                        {loading ? "验证中..." : "验证"}
+                    </Button>
+                    <Button fullWidth onClick={onCancel}>
+                        返回
+                    </Button>
+                </>
+            )}
+        </Box>
+    );
+}
+
+export default MFAVerification;
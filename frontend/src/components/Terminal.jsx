@@ -4,6 +4,7 @@ import { AttachAddon } from "@xterm/addon-attach";
 import { FitAddon } from "@xterm/addon-fit";
 import "@xterm/xterm/css/xterm.css";
 import "./Terminal.css";
+import apiService from "../services/api";
 
 // Dracula 德古拉主题颜色
 const draculaTheme = {
@@ -64,12 +65,19 @@ const Terminal = ({ isOpen }) => {
         termInstance.current = term;
         term.loadAddon(fitAddonInstance.current);
 
-        const token = localStorage.getItem("token");
-        const wsUrl = import.meta.env.VITE_WS_BASE_URL + `/v1/shell?token=${token}`;
-        const socket = new WebSocket(wsUrl);
-
-        const attachAddon = new AttachAddon(socket);
-        term.loadAddon(attachAddon);
+        // 先获取一次性票据再建立连接，避免把登录 token 放进 URL
+        let socket = null;
+        let cancelled = false;
+        apiService
+            .getWebSocketTicket("shell")
+            .then((ticket) => {
+                if (cancelled) return;
+                socket = new WebSocket(import.meta.env.VITE_WS_BASE_URL + `/v1/shell?ticket=${ticket}`);
+                term.loadAddon(new AttachAddon(socket));
+            })
+            .catch(() => {
+                term.write("Error: failed to open shell connection.\r\n");
+            });
 
         term.open(terminalRef.current);
         fitAddonInstance.current.fit();
@@ -79,6 +87,8 @@ const Terminal = ({ isOpen }) => {
         window.addEventListener("resize", handleResize);
 
         return () => {
+            cancelled = true;
+            socket?.close();
             window.removeEventListener("resize", handleResize);
         };
     }, []);
@@ -35,30 +35,60 @@ export const AuthProvider = ({ children }) => {
         setLoading(false);
     }, []);
 
+    // 保存登录接口返回的 token 和用户信息，密码登录和单点登录共用
+    const establishSession = (response) => {
+        const {
+            token,
+            refresh_token: refreshToken,
+            expires_in: expiresIn,
+            csrf_token: csrfToken,
+            user: userInfoResponse,
+        } = response;
+        // 服务端开启 cookie 会话时 token 在 HttpOnly cookie 中，响应只包含 CSRF token
+        if (!token && !csrfToken) {
+            return { success: false, message: "登录失败：未获取到有效token" };
+        }
+        saveToken(token, refreshToken, expiresIn, csrfToken);
+
+        // 创建用户信息对象
+        const userInfo = {
+            username: userInfoResponse.username,
+            role: userInfoResponse.role || "user",
+        };
+        setUser(userInfo);
+        localStorage.setItem("user", JSON.stringify(userInfo));
+
+        return { success: true, user: userInfo };
+    };
+
+    // 第一步认证通过后需要两步验证时，返回本次登录的挑战而不是登录结果
+    const mfaChallenge = (response) => ({
+        success: false,
+        mfaRequired: true,
+        mfaToken: response.mfa_token,
+        enrollmentRequired: !!response.enrollment_required,
+    });
+
+    // 连续失败后服务端会暂时拒绝该用户名或 IP 的登录
+    const throttledMessage = (error) => {
+        const retryAfter = error.response.data?.retry_after;
+        return `登录失败次数过多，请在 ${retryAfter > 60 ? `${Math.ceil(retryAfter / 60)} 分钟` : `${retryAfter} 秒`}后重试`;
+    };
+
     // 登录函数
     const login = async (username, password) => {
         try {
             // 调用API登录服务
             const response = await apiService.login(username, password);
-            const { token, user: userInfoResponse } = response;
-
-            if (token) {
-                saveToken(token);
-
-                // 创建用户信息对象
-                const userInfo = {
-                    username: userInfoResponse.username,
-                    role: userInfoResponse.role || "user",
-                };
-                setUser(userInfo);
-                localStorage.setItem("user", JSON.stringify(userInfo));
-
-                return { success: true, user: userInfo };
-            } else {
-                return { success: false, message: "登录失败：未获取到有效token" };
+            if (response.mfa_required) {
+                return mfaChallenge(response);
             }
+            return establishSession(response);
         } catch (error) {
             console.error("登录错误:", error);
+            if (error.response?.status === 429) {
+                return { success: false, message: throttledMessage(error) };
+            }
             return {
                 success: false,
                 message: error.response?.data?.message || "登录失败，用户名或密码错误",
@@ -66,8 +96,60 @@ export const AuthProvider = ({ children }) => {
         }
     };
 
-    // 登出函数
-    const logout = () => {
+    // 提交两步验证码完成登录。绑定验证器后的首次登录会返回只显示一次的恢复码，
+    // 此时由调用者展示恢复码后再调用 finish 保存登录状态
+    const verifyMFA = async (mfaToken, code) => {
+        try {
+            const response = await apiService.verifyMFALogin(mfaToken, code);
+            if (response.recovery_codes) {
+                return { success: true, recoveryCodes: response.recovery_codes, finish: () => establishSession(response) };
+            }
+            return establishSession(response);
+        } catch (error) {
+            console.error("两步验证错误:", error);
+            const status = error.response?.status;
+            const data = error.response?.data || {};
+            if (status === 429) {
+                return { success: false, message: throttledMessage(error) };
+            }
+            if (status === 401 && data.attempts_remaining > 0) {
+                return { success: false, message: `验证码错误，还可以尝试 ${data.attempts_remaining} 次` };
+            }
+            if (status === 401 || status === 400) {
+                return { success: false, expired: true, message: "验证码错误次数过多或登录已过期，请重新登录" };
+            }
+            // 两步验证设置在其他地方被修改了，需要重新登录
+            if (status === 409) {
+                return { success: false, expired: true, message: "两步验证设置已变更，请重新登录" };
+            }
+            return { success: false, message: data.error || "两步验证失败，请重试" };
+        }
+    };
+
+    // 用身份提供者回调中的授权码完成单点登录
+    const loginWithOIDC = async (code, state) => {
+        try {
+            const response = await apiService.completeOIDCLogin(code, state);
+            if (response.mfa_required) {
+                return mfaChallenge(response);
+            }
+            return establishSession(response);
+        } catch (error) {
+            console.error("单点登录错误:", error);
+            return {
+                success: false,
+                message: error.response?.data?.error || "单点登录失败，请重试",
+            };
+        }
+    };
+
+    // 登出函数，服务端吊销失败时仍然清除本地状态
+    const logout = async () => {
+        try {
+            await apiService.logout();
+        } catch (error) {
+            console.error("服务端登出失败:", error);
+        }
         setUser(null);
         clearToken();
         navigate("/login");
@@ -83,6 +165,8 @@ export const AuthProvider = ({ children }) => {
         user,
         loading,
         login,
+        verifyMFA,
+        loginWithOIDC,
         logout,
         isAuthenticated,
     };
@@ -30,6 +30,8 @@ import {
     Code as CodeIcon,
     School as SchoolIcon,
     Terminal as TerminalIcon,
+    Lock as LockIcon,
+    Security as SecurityIcon,
 } from "@mui/icons-material";
 import { styled } from "@mui/material/styles";
 import { Link } from "react-router-dom";
@@ -87,6 +89,8 @@ const menuItems = [
     { text: "作业管理", icon: <AssessmentIcon />, path: "/jobs" },
     { text: "脚本生成", icon: <CodeIcon />, path: "/script-generator" },
     { text: "使用教程", icon: <SchoolIcon />, path: "/tutorial" },
+    { text: "登录锁定", icon: <LockIcon />, path: "/admin/lockouts" },
+    { text: "两步验证", icon: <SecurityIcon />, path: "/admin/security" },
 ];
 
 function AdminLayout() {
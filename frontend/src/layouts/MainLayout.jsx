@@ -44,6 +44,8 @@ import {
     PlayCircleOutline as PlayCircleOutlineIcon,
     BatchPrediction as BatchPredictionIcon,
     Info as InfoIcon,
+    VpnKey as VpnKeyIcon,
+    Security as SecurityIcon,
 } from "@mui/icons-material";
 import { deepOrange, deepPurple } from '@mui/material/colors';
 import { styled } from "@mui/material/styles";
@@ -109,6 +111,8 @@ const menuItems = [
         ],
     },
     { text: "使用教程", icon: <SchoolIcon />, path: "/tutorial" },
+    { text: "API 密钥", icon: <VpnKeyIcon />, path: "/api-keys" },
+    { text: "两步验证", icon: <SecurityIcon />, path: "/security" },
 ];
 
 function MainLayout() {
@@ -0,0 +1,252 @@
+import { useState, useEffect, useCallback } from "react";
+import {
+    Typography,
+    Paper,
+    Table,
+    TableBody,
+    TableCell,
+    TableContainer,
+    TableHead,
+    TableRow,
+    Chip,
+    Box,
+    TextField,
+    Button,
+    CircularProgress,
+    Alert,
+    IconButton,
+    Tooltip,
+    Dialog,
+    DialogActions,
+    DialogContent,
+    DialogContentText,
+    DialogTitle,
+    FormGroup,
+    FormControlLabel,
+    Checkbox,
+} from "@mui/material";
+import DeleteIcon from "@mui/icons-material/Delete";
+import AddIcon from "@mui/icons-material/Add";
+import apiService from "../services/api";
+
+// 可授予 API key 的范围，与后端 auth.Scopes 一致
+const scopeOptions = [
+    { value: "read", label: "只读", description: "查询集群、分区、作业和日志" },
+    { value: "submit", label: "提交", description: "提交批处理作业和申请资源，不包括交互式分配" },
+    { value: "cancel", label: "取消", description: "取消作业" },
+];
+
+const formatTime = (value) => (value ? new Date(value).toLocaleString() : "-");
+
+/**
+ * @description 管理用于脚本和 CI 的个人 API key。
+ * 完整的 key 只在创建后显示一次，之后只能看到开头部分。
+ */
+function ApiKeys() {
+    const [keys, setKeys] = useState([]);
+    const [loading, setLoading] = useState(true);
+    const [error, setError] = useState("");
+    const [createOpen, setCreateOpen] = useState(false);
+    const [form, setForm] = useState({ name: "", scopes: ["read"], expiresInDays: 90 });
+    const [createdKey, setCreatedKey] = useState("");
+    const [revokeTarget, setRevokeTarget] = useState(null);
+
+    const loadKeys = useCallback(async () => {
+        try {
+            setLoading(true);
+            const response = await apiService.listApiKeys();
+            setKeys(response.keys || []);
+            setError("");
+        } catch (error) {
+            setError(error.response?.data?.error || "获取 API key 列表失败");
+        } finally {
+            setLoading(false);
+        }
+    }, []);
+
+    useEffect(() => {
+        loadKeys();
+    }, [loadKeys]);
+
+    const handleToggleScope = (scope) => {
+        setForm((prev) => ({
+            ...prev,
+            scopes: prev.scopes.includes(scope) ? prev.scopes.filter((s) => s !== scope) : [...prev.scopes, scope],
+        }));
+    };
+
+    const handleCreate = async () => {
+        try {
+            const response = await apiService.createApiKey(form.name, form.scopes, Number(form.expiresInDays) || 0);
+            setCreatedKey(response.key);
+            setCreateOpen(false);
+            setForm({ name: "", scopes: ["read"], expiresInDays: 90 });
+            loadKeys();
+        } catch (error) {
+            setError(error.response?.data?.error || "创建 API key 失败");
+            setCreateOpen(false);
+        }
+    };
+
+    const handleRevoke = async () => {
+        try {
+            await apiService.revokeApiKey(revokeTarget.id);
+            loadKeys();
+        } catch (error) {
+            setError(error.response?.data?.error || "吊销 API key 失败");
+        } finally {
+            setRevokeTarget(null);
+        }
+    };
+
+    return (
+        <Box>
+            <Box sx={{ display: "flex", justifyContent: "space-between", alignItems: "center", mb: 2 }}>
+                <Typography variant="h5">API 密钥</Typography>
+                <Button variant="contained" startIcon={<AddIcon />} onClick={() => setCreateOpen(true)}>
+                    创建 API key
+                </Button>
+            </Box>
+            <Typography variant="body2" color="text.secondary" sx={{ mb: 2 }}>
+                在脚本或 CI 中使用请求头 Authorization: Bearer &lt;API key&gt; 调用 /api/v1 接口。API key
+                以普通用户权限执行，只能访问其范围内的接口。
+            </Typography>
+
+            {error && (
+                <Alert severity="error" sx={{ mb: 2 }} onClose={() => setError("")}>
+                    {error}
+                </Alert>
+            )}
+            {createdKey && (
+                <Alert severity="success" sx={{ mb: 2, wordBreak: "break-all" }} onClose={() => setCreatedKey("")}>
+                    请立即复制新的 API key，关闭后将无法再次查看：
+                    <Box component="code" sx={{ display: "block", mt: 1 }}>
+                        {createdKey}
+                    </Box>
+                </Alert>
+            )}
+
+            <Paper>
+                <TableContainer>
+                    {loading ? (
+                        <Box sx={{ display: "flex", justifyContent: "center", p: 3 }}>
+                            <CircularProgress />
+                        </Box>
+                    ) : (
+                        <Table>
+                            <TableHead>
+                                <TableRow>
+                                    <TableCell>名称</TableCell>
+                                    <TableCell>Key</TableCell>
+                                    <TableCell>范围</TableCell>
+                                    <TableCell>创建时间</TableCell>
+                                    <TableCell>过期时间</TableCell>
+                                    <TableCell>最后使用</TableCell>
+                                    <TableCell align="center">操作</TableCell>
+                                </TableRow>
+                            </TableHead>
+                            <TableBody>
+                                {keys.length === 0 ? (
+                                    <TableRow>
+                                        <TableCell colSpan={7} align="center">
+                                            还没有 API key
+                                        </TableCell>
+                                    </TableRow>
+                                ) : (
+                                    keys.map((key) => (
+                                        <TableRow key={key.id}>
+                                            <TableCell>{key.name}</TableCell>
+                                            <TableCell>
+                                                <code>{key.display}…</code>
+                                            </TableCell>
+                                            <TableCell>
+                                                {key.scopes.map((scope) => (
+                                                    <Chip key={scope} label={scope} size="small" sx={{ mr: 0.5 }} />
+                                                ))}
+                                            </TableCell>
+                                            <TableCell>{formatTime(key.created_at)}</TableCell>
+                                            <TableCell>{formatTime(key.expires_at)}</TableCell>
+                                            <TableCell>{formatTime(key.last_used_at)}</TableCell>
+                                            <TableCell align="center">
+                                                <Tooltip title="吊销">
+                                                    <IconButton color="error" onClick={() => setRevokeTarget(key)}>
+                                                        <DeleteIcon />
+                                                    </IconButton>
+                                                </Tooltip>
+                                            </TableCell>
+                                        </TableRow>
+                                    ))
+                                )}
+                            </TableBody>
+                        </Table>
+                    )}
+                </TableContainer>
+            </Paper>
+
+            <Dialog open={createOpen} onClose={() => setCreateOpen(false)} fullWidth maxWidth="sm">
+                <DialogTitle>创建 API key</DialogTitle>
+                <DialogContent>
+                    <TextField
+                        autoFocus
+                        margin="normal"
+                        fullWidth
+                        label="名称"
+                        value={form.name}
+                        onChange={(e) => setForm({ ...form, name: e.target.value })}
+                        inputProps={{ maxLength: 64 }}
+                    />
+                    <FormGroup sx={{ mt: 1 }}>
+                        {scopeOptions.map((option) => (
+                            <FormControlLabel
+                                key={option.value}
+                                control={
+                                    <Checkbox
+                                        checked={form.scopes.includes(option.value)}
+                                        onChange={() => handleToggleScope(option.value)}
+                                    />
+                                }
+                                label={`${option.label}（${option.description}）`}
+                            />
+                        ))}
+                    </FormGroup>
+                    <TextField
+                        margin="normal"
+                        fullWidth
+                        type="number"
+                        label="有效天数（最长 365 天）"
+                        value={form.expiresInDays}
+                        onChange={(e) => setForm({ ...form, expiresInDays: e.target.value })}
+                        inputProps={{ min: 1, max: 365 }}
+                    />
+                </DialogContent>
+                <DialogActions>
+                    <Button onClick={() => setCreateOpen(false)}>取消</Button>
+                    <Button
+                        variant="contained"
+                        onClick={handleCreate}
+                        disabled={!form.name.trim() || form.scopes.length === 0}
+                    >
+                        创建
+                    </Button>
+                </DialogActions>
+            </Dialog>
+
+            <Dialog open={!!revokeTarget} onClose={() => setRevokeTarget(null)}>
+                <DialogTitle>吊销 API key</DialogTitle>
+                <DialogContent>
+                    <DialogContentText>
+                        确定要吊销 “{revokeTarget?.name}” 吗？使用该 key 的脚本将立即无法访问。
+                    </DialogContentText>
+                </DialogContent>
+                <DialogActions>
+                    <Button onClick={() => setRevokeTarget(null)}>取消</Button>
+                    <Button color="error" onClick={handleRevoke}>
+                        吊销
+                    </Button>
+                </DialogActions>
+            </Dialog>
+        </Box>
+    );
+}
+
+export default ApiKeys;
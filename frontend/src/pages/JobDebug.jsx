@@ -32,11 +32,10 @@ function DebugJob() {
     const [activeTab, setActiveTab] = useState(0);
     const [loading, setLoading] = useState(false);
     const [error, setError] = useState("");
-    const [token, setToken] = useState("");
     const [partitions, setPartitions] = useState([]);
 
     useEffect(() => {
-        // 组件加载时，从 localStorage 恢复会话列表并获取 token
+        // 组件加载时，从 localStorage 恢复会话列表
         const savedSessions = localStorage.getItem("interactiveSessions");
         if (savedSessions) {
             const parsedSessions = JSON.parse(savedSessions);
@@ -44,10 +43,6 @@ function DebugJob() {
                 setSessions(parsedSessions);
             }
         }
-        const storedToken = localStorage.getItem("token");
-        if (storedToken) {
-            setToken(storedToken);
-        }
 
         // 获取所有可用分区
         const fetchPartitions = async () => {
@@ -306,7 +301,7 @@ function DebugJob() {
                                 boxSizing: "border-box",
                             }}
                         >
-                            <InteractiveTerminal sessionId={session.id} token={token} isActive={activeTab === index} />
+                            <InteractiveTerminal sessionId={session.id} isActive={activeTab === index} />
                         </Box>
                     ))}
                     {sessions.length === 0 && (
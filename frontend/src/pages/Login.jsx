@@ -1,6 +1,9 @@
-import { useState } from "react";
+import { useState, useEffect } from "react";
 import { useNavigate, Navigate } from "react-router-dom";
 import { useAuth } from "../contexts/AuthContext";
+import apiService from "../services/api";
+import { OIDC_STATE_KEY } from "./OIDCCallback";
+import MFAVerification from "../components/MFAVerification";
 import {
     Container,
     Box,
@@ -10,6 +13,7 @@ import {
     Paper,
     Avatar,
     Alert,
+    Divider,
     InputAdornment,
     IconButton,
 } from "@mui/material";
@@ -30,6 +34,17 @@ function Login() {
 
     const [error, setError] = useState("");
     const [showPassword, setShowPassword] = useState(false);
+    const [oidcEnabled, setOidcEnabled] = useState(false);
+    // 密码正确但需要两步验证时保存本次登录的挑战
+    const [mfa, setMfa] = useState(null);
+
+    // 查询是否提供单点登录，查询失败时只显示密码登录
+    useEffect(() => {
+        apiService
+            .getOIDCConfig()
+            .then((config) => setOidcEnabled(!!config.enabled))
+            .catch(() => setOidcEnabled(false));
+    }, []);
 
     // 如果用户已登录，重定向到首页
     if (isAuthenticated()) {
@@ -68,6 +83,8 @@ function Login() {
             if (result.success) {
                 // 登录成功，导航到首页
                 navigate("/");
+            } else if (result.mfaRequired) {
+                setMfa(result);
             } else {
                 // 登录失败，显示错误信息
                 setError(result.message);
@@ -80,6 +97,21 @@ function Login() {
         }
     };
 
+    // 跳转到身份提供者登录，state 保存在当前标签页中，回调时用于确认是本页面发起的登录
+    const handleOIDCLogin = async () => {
+        setError("");
+        try {
+            setLoading(true);
+            const { authorization_url: authorizationURL, state } = await apiService.startOIDCLogin();
+            sessionStorage.setItem(OIDC_STATE_KEY, state);
+            window.location.href = authorizationURL;
+        } catch (error) {
+            console.error("单点登录失败:", error);
+            setError(error.response?.data?.error || "无法连接统一身份认证，请稍后重试");
+            setLoading(false);
+        }
+    };
+
     return (
         <Container component="main" maxWidth="xs">
             <Box
@@ -113,48 +145,71 @@ function Login() {
                         </Alert>
                     )}
 
-                    <Box component="form" onSubmit={handleSubmit} noValidate sx={{ mt: 1, width: "100%" }}>
-                        <TextField
-                            margin="normal"
-                            required
-                            fullWidth
-                            id="username"
-                            label="用户名"
-                            name="username"
-                            autoComplete="username"
-                            autoFocus
-                            value={formData.username}
-                            onChange={handleChange}
-                        />
-                        <TextField
-                            margin="normal"
-                            required
-                            fullWidth
-                            name="password"
-                            label="密码"
-                            type={showPassword ? "text" : "password"}
-                            id="password"
-                            autoComplete="current-password"
-                            value={formData.password}
-                            onChange={handleChange}
-                            InputProps={{
-                                endAdornment: (
-                                    <InputAdornment position="end">
-                                        <IconButton
-                                            aria-label="toggle password visibility"
-                                            onClick={handleTogglePasswordVisibility}
-                                            edge="end"
-                                        >
-                                            {showPassword ? <VisibilityOffIcon /> : <VisibilityIcon />}
-                                        </IconButton>
-                                    </InputAdornment>
-                                ),
+                    {mfa ? (
+                        <MFAVerification
+                            mfaToken={mfa.mfaToken}
+                            enrollmentRequired={mfa.enrollmentRequired}
+                            onSuccess={() => navigate("/")}
+                            onCancel={() => {
+                                setMfa(null);
+                                setFormData({ ...formData, password: "" });
                             }}
                         />
-                        <Button type="submit" fullWidth variant="contained" sx={{ mt: 3, mb: 2 }} disabled={loading}>
-                            {loading ? "登录中..." : "登录"}
-                        </Button>
-                    </Box>
+                    ) : (
+                        <>
+                            <Box component="form" onSubmit={handleSubmit} noValidate sx={{ mt: 1, width: "100%" }}>
+                                <TextField
+                                    margin="normal"
+                                    required
+                                    fullWidth
+                                    id="username"
+                                    label="用户名"
+                                    name="username"
+                                    autoComplete="username"
+                                    autoFocus
+                                    value={formData.username}
+                                    onChange={handleChange}
+                                />
+                                <TextField
+                                    margin="normal"
+                                    required
+                                    fullWidth
+                                    name="password"
+                                    label="密码"
+                                    type={showPassword ? "text" : "password"}
+                                    id="password"
+                                    autoComplete="current-password"
+                                    value={formData.password}
+                                    onChange={handleChange}
+                                    InputProps={{
+                                        endAdornment: (
+                                            <InputAdornment position="end">
+                                                <IconButton
+                                                    aria-label="toggle password visibility"
+                                                    onClick={handleTogglePasswordVisibility}
+                                                    edge="end"
+                                                >
+                                                    {showPassword ? <VisibilityOffIcon /> : <VisibilityIcon />}
+                                                </IconButton>
+                                            </InputAdornment>
+                                        ),
+                                    }}
+                                />
+                                <Button type="submit" fullWidth variant="contained" sx={{ mt: 3, mb: 2 }} disabled={loading}>
+                                    {loading ? "登录中..." : "登录"}
+                                </Button>
+                            </Box>
+
+                            {oidcEnabled && (
+                                <>
+                                    <Divider sx={{ width: "100%", my: 1 }}>或</Divider>
+                                    <Button fullWidth variant="outlined" onClick={handleOIDCLogin} disabled={loading} sx={{ mt: 1 }}>
+                                        使用统一身份认证登录
+                                    </Button>
+                                </>
+                            )}
+                        </>
+                    )}
                 </Paper>
             </Box>
         </Container>
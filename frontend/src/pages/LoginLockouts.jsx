@@ -0,0 +1,206 @@
+import { useState, useEffect, useCallback } from "react";
+import {
+    Typography,
+    Paper,
+    Table,
+    TableBody,
+    TableCell,
+    TableContainer,
+    TableHead,
+    TableRow,
+    Chip,
+    Box,
+    Button,
+    CircularProgress,
+    Alert,
+    TextField,
+} from "@mui/material";
+import RefreshIcon from "@mui/icons-material/Refresh";
+import apiService from "../services/api";
+
+const formatTime = (value) => (value ? new Date(value).toLocaleString() : "-");
+
+const eventLabels = {
+    login_failed: "登录失败",
+    login_blocked: "登录被拒绝",
+    lockout: "锁定",
+    lockout_cleared: "解除锁定",
+    mfa_failed: "两步验证失败",
+    mfa_enabled: "启用两步验证",
+    mfa_disabled: "停用两步验证",
+    recovery_code_used: "使用恢复码",
+};
+
+/**
+ * @description 管理员查看登录失败和锁定的用户名、IP，解除锁定，并查看最近的审计日志。
+ * 用户丢失验证器和恢复码时，管理员可以在这里重置其两步验证。
+ */
+function LoginLockouts() {
+    const [lockouts, setLockouts] = useState([]);
+    const [entries, setEntries] = useState([]);
+    const [loading, setLoading] = useState(true);
+    const [error, setError] = useState("");
+    const [message, setMessage] = useState("");
+    const [mfaUsername, setMfaUsername] = useState("");
+
+    const loadData = useCallback(async () => {
+        try {
+            setLoading(true);
+            const [lockoutResponse, auditResponse] = await Promise.all([
+                apiService.getLoginLockouts(),
+                apiService.getAuditLog({ limit: 50 }),
+            ]);
+            setLockouts(lockoutResponse.lockouts || []);
+            setEntries(auditResponse.entries || []);
+            setError("");
+        } catch (error) {
+            setError(error.response?.data?.error || "获取登录锁定记录失败");
+        } finally {
+            setLoading(false);
+        }
+    }, []);
+
+    useEffect(() => {
+        loadData();
+    }, [loadData]);
+
+    const handleClear = async (lockout) => {
+        try {
+            await apiService.clearLoginLockout(lockout.kind, lockout.key);
+            loadData();
+        } catch (error) {
+            setError(error.response?.data?.error || "解除锁定失败");
+        }
+    };
+
+    const handleResetMFA = async () => {
+        try {
+            await apiService.resetUserMFA(mfaUsername.trim());
+            setMessage(`已重置用户 ${mfaUsername.trim()} 的两步验证`);
+            setMfaUsername("");
+            loadData();
+        } catch (error) {
+            setError(error.response?.data?.error || "重置两步验证失败");
+        }
+    };
+
+    if (loading) {
+        return (
+            <Box sx={{ display: "flex", justifyContent: "center", p: 3 }}>
+                <CircularProgress />
+            </Box>
+        );
+    }
+
+    return (
+        <Box>
+            <Box sx={{ display: "flex", justifyContent: "space-between", alignItems: "center", mb: 2 }}>
+                <Typography variant="h5">登录锁定</Typography>
+                <Button startIcon={<RefreshIcon />} onClick={loadData}>
+                    刷新
+                </Button>
+            </Box>
+
+            {error && (
+                <Alert severity="error" sx={{ mb: 2 }} onClose={() => setError("")}>
+                    {error}
+                </Alert>
+            )}
+
+            <Paper sx={{ mb: 3 }}>
+                <TableContainer>
+                    <Table>
+                        <TableHead>
+                            <TableRow>
+                                <TableCell>类型</TableCell>
+                                <TableCell>用户名 / IP</TableCell>
+                                <TableCell>失败次数</TableCell>
+                                <TableCell>最后失败</TableCell>
+                                <TableCell>可重试时间</TableCell>
+                                <TableCell align="center">操作</TableCell>
+                            </TableRow>
+                        </TableHead>
+                        <TableBody>
+                            {lockouts.length === 0 ? (
+                                <TableRow>
+                                    <TableCell colSpan={6} align="center">
+                                        没有登录失败记录
+                                    </TableCell>
+                                </TableRow>
+                            ) : (
+                                lockouts.map((lockout) => (
+                                    <TableRow key={`${lockout.kind}:${lockout.key}`}>
+                                        <TableCell>{lockout.kind === "user" ? "用户名" : "IP"}</TableCell>
+                                        <TableCell>
+                                            {lockout.key}{" "}
+                                            {lockout.locked && <Chip label="已锁定" color="error" size="small" />}
+                                        </TableCell>
+                                        <TableCell>{lockout.failures}</TableCell>
+                                        <TableCell>{formatTime(lockout.last_failure_at)}</TableCell>
+                                        <TableCell>{formatTime(lockout.retry_at)}</TableCell>
+                                        <TableCell align="center">
+                                            <Button size="small" color="error" onClick={() => handleClear(lockout)}>
+                                                解除
+                                            </Button>
+                                        </TableCell>
+                                    </TableRow>
+                                ))
+                            )}
+                        </TableBody>
+                    </Table>
+                </TableContainer>
+            </Paper>
+
+            {message && (
+                <Alert severity="success" sx={{ mb: 2 }} onClose={() => setMessage("")}>
+                    {message}
+                </Alert>
+            )}
+            <Paper sx={{ p: 2, mb: 3, display: "flex", alignItems: "center", gap: 2 }}>
+                <TextField
+                    size="small"
+                    label="用户名"
+                    value={mfaUsername}
+                    onChange={(e) => setMfaUsername(e.target.value)}
+                />
+                <Button variant="outlined" color="error" onClick={handleResetMFA} disabled={!mfaUsername.trim()}>
+                    重置两步验证
+                </Button>
+            </Paper>
+
+            <Typography variant="h6" sx={{ mb: 1 }}>
+                最近的审计日志
+            </Typography>
+            <Paper>
+                <TableContainer>
+                    <Table size="small">
+                        <TableHead>
+                            <TableRow>
+                                <TableCell>时间</TableCell>
+                                <TableCell>事件</TableCell>
+                                <TableCell>用户名</TableCell>
+                                <TableCell>IP</TableCell>
+                                <TableCell>操作者</TableCell>
+                                <TableCell>详情</TableCell>
+                            </TableRow>
+                        </TableHead>
+                        <TableBody>
+                            {entries.map((entry, index) => (
+                                <TableRow key={`${entry.time}-${index}`}>
+                                    <TableCell>{formatTime(entry.time)}</TableCell>
+                                    <TableCell>{eventLabels[entry.event] || entry.event}</TableCell>
+                                    <TableCell>{entry.username || "-"}</TableCell>
+                                    <TableCell>{entry.client_ip || "-"}</TableCell>
+                                    <TableCell>{entry.actor || "-"}</TableCell>
+                                    <TableCell>{entry.detail || "-"}</TableCell>
+                                </TableRow>
+                            ))}
+                        </TableBody>
+                    </Table>
+                </TableContainer>
+            </Paper>
+        </Box>
+    );
+}
+
+export default LoginLockouts;
@@ -0,0 +1,89 @@
+import { useEffect, useRef, useState } from "react";
+import { useNavigate, useSearchParams, Link as RouterLink } from "react-router-dom";
+import { useAuth } from "../contexts/AuthContext";
+import { Container, Box, Paper, Typography, CircularProgress, Alert, Button } from "@mui/material";
+import MFAVerification from "../components/MFAVerification";
+
+// 发起单点登录时保存 state 的 sessionStorage 键
+export const OIDC_STATE_KEY = "oidcState";
+
+/**
+ * @description 身份提供者登录完成后跳转回的页面，
+ * 校验 state 与本标签页发起的登录一致后，将授权码交给后端换取 dashboard token。
+ */
+function OIDCCallback() {
+    const { loginWithOIDC } = useAuth();
+    const navigate = useNavigate();
+    const [searchParams] = useSearchParams();
+    const [error, setError] = useState("");
+    const [mfa, setMfa] = useState(null);
+    // 开发模式下 effect 会执行两次，授权码只能兑换一次
+    const started = useRef(false);
+
+    useEffect(() => {
+        if (started.current) {
+            return;
+        }
+        started.current = true;
+
+        const code = searchParams.get("code");
+        const state = searchParams.get("state");
+        const expectedState = sessionStorage.getItem(OIDC_STATE_KEY);
+        sessionStorage.removeItem(OIDC_STATE_KEY);
+
+        if (searchParams.get("error")) {
+            setError(searchParams.get("error_description") || "统一身份认证登录被取消或失败");
+            return;
+        }
+        if (!code || !state || state !== expectedState) {
+            setError("登录请求无效或已过期，请重新登录");
+            return;
+        }
+
+        loginWithOIDC(code, state).then((result) => {
+            if (result.success) {
+                navigate("/", { replace: true });
+            } else if (result.mfaRequired) {
+                setMfa(result);
+            } else {
+                setError(result.message);
+            }
+        });
+    }, [searchParams, loginWithOIDC, navigate]);
+
+    return (
+        <Container component="main" maxWidth="xs">
+            <Box sx={{ marginTop: 8 }}>
+                <Paper elevation={3} sx={{ padding: 4, display: "flex", flexDirection: "column", alignItems: "center" }}>
+                    {mfa ? (
+                        <>
+                            <Typography variant="h6">两步验证</Typography>
+                            <MFAVerification
+                                mfaToken={mfa.mfaToken}
+                                enrollmentRequired={mfa.enrollmentRequired}
+                                onSuccess={() => navigate("/", { replace: true })}
+                                onCancel={() => navigate("/login", { replace: true })}
+                            />
+                        </>
+                    ) : error ? (
+                        <>
+                            <Alert severity="error" sx={{ width: "100%" }}>
+                                {error}
+                            </Alert>
+                            <Button component={RouterLink} to="/login" sx={{ mt: 2 }}>
+                                返回登录
+                            </Button>
+                        </>
+                    ) : (
+                        <>
+                            <CircularProgress />
+                            <Typography sx={{ mt: 2 }}>正在完成统一身份认证登录...</Typography>
+                        </>
+                    )}
+                </Paper>
+            </Box>
+        </Container>
+    );
+}
+
+export default OIDCCallback;
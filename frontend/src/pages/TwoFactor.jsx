@@ -0,0 +1,212 @@
+import { useState, useEffect, useCallback } from "react";
+import {
+    Typography,
+    Paper,
+    Box,
+    TextField,
+    Button,
+    CircularProgress,
+    Alert,
+    Chip,
+    Dialog,
+    DialogActions,
+    DialogContent,
+    DialogContentText,
+    DialogTitle,
+} from "@mui/material";
+import apiService from "../services/api";
+import { RecoveryCodeList, TOTPEnrollmentInfo } from "../components/MFAVerification";
+
+const formatTime = (value) => (value ? new Date(value).toLocaleString() : "-");
+
+/**
+ * @description 管理当前用户的两步验证：绑定验证器应用、重新生成恢复码和停用。
+ * 修改已启用的两步验证需要输入验证码或恢复码。
+ */
+function TwoFactor() {
+    const [status, setStatus] = useState(null);
+    const [loading, setLoading] = useState(true);
+    const [error, setError] = useState("");
+    const [enrollment, setEnrollment] = useState(null);
+    const [code, setCode] = useState("");
+    const [recoveryCodes, setRecoveryCodes] = useState(null);
+    // action 为 "regenerate" 或 "disable"，需要先输入验证码确认
+    const [action, setAction] = useState(null);
+
+    const loadStatus = useCallback(async () => {
+        try {
+            setLoading(true);
+            setStatus(await apiService.getMFAStatus());
+            setError("");
+        } catch (error) {
+            setError(error.response?.data?.error || "获取两步验证状态失败");
+        } finally {
+            setLoading(false);
+        }
+    }, []);
+
+    useEffect(() => {
+        loadStatus();
+    }, [loadStatus]);
+
+    const handleEnroll = async () => {
+        try {
+            setEnrollment(await apiService.enrollTOTP());
+            setCode("");
+            setError("");
+        } catch (error) {
+            setError(error.response?.data?.error || "生成两步验证密钥失败");
+        }
+    };
+
+    const handleActivate = async () => {
+        try {
+            const response = await apiService.activateTOTP(code.trim());
+            setRecoveryCodes(response.recovery_codes);
+            setEnrollment(null);
+            setCode("");
+            loadStatus();
+        } catch (error) {
+            setError(error.response?.status === 400 ? "验证码错误，请确认设备时间准确后重试" : error.response?.data?.error || "启用两步验证失败");
+        }
+    };
+
+    const handleConfirmAction = async () => {
+        try {
+            if (action === "regenerate") {
+                const response = await apiService.regenerateRecoveryCodes(code.trim());
+                setRecoveryCodes(response.recovery_codes);
+            } else {
+                await apiService.disableTOTP(code.trim());
+            }
+            loadStatus();
+        } catch (error) {
+            setError(error.response?.status === 400 ? "验证码错误" : error.response?.data?.error || "操作失败");
+        } finally {
+            setAction(null);
+            setCode("");
+        }
+    };
+
+    if (loading && !status) {
+        return (
+            <Box sx={{ display: "flex", justifyContent: "center", p: 3 }}>
+                <CircularProgress />
+            </Box>
+        );
+    }
+
+    return (
+        <Box>
+            <Typography variant="h5" sx={{ mb: 2 }}>
+                两步验证
+            </Typography>
+            <Typography variant="body2" color="text.secondary" sx={{ mb: 2 }}>
+                启用后，使用密码或统一身份认证登录时还需要输入验证器应用中的 6 位验证码。
+            </Typography>
+
+            {error && (
+                <Alert severity="error" sx={{ mb: 2 }} onClose={() => setError("")}>
+                    {error}
+                </Alert>
+            )}
+            {recoveryCodes && (
+                <Paper sx={{ p: 2, mb: 2 }}>
+                    <RecoveryCodeList codes={recoveryCodes} />
+                    <Button variant="contained" sx={{ mt: 1 }} onClick={() => setRecoveryCodes(null)}>
+                        我已保存恢复码
+                    </Button>
+                </Paper>
+            )}
+
+            {status && (
+                <Paper sx={{ p: 2 }}>
+                    <Box sx={{ display: "flex", alignItems: "center", gap: 1, mb: 2 }}>
+                        <Typography>状态：</Typography>
+                        {status.enabled ? <Chip label="已启用" color="success" size="small" /> : <Chip label="未启用" size="small" />}
+                        {status.required && <Chip label="您的角色要求两步验证" color="warning" size="small" />}
+                    </Box>
+
+                    {status.enabled ? (
+                        <>
+                            <Typography variant="body2" sx={{ mb: 1 }}>
+                                启用时间：{formatTime(status.enabled_at)}
+                            </Typography>
+                            <Typography variant="body2" sx={{ mb: 2 }}>
+                                剩余恢复码：{status.recovery_codes_remaining} 个
+                            </Typography>
+                            <Box sx={{ display: "flex", gap: 1 }}>
+                                <Button variant="outlined" onClick={() => setAction("regenerate")}>
+                                    重新生成恢复码
+                                </Button>
+                                {!status.required && (
+                                    <Button variant="outlined" color="error" onClick={() => setAction("disable")}>
+                                        停用两步验证
+                                    </Button>
+                                )}
+                            </Box>
+                        </>
+                    ) : enrollment ? (
+                        <Box sx={{ maxWidth: 400 }}>
+                            <TOTPEnrollmentInfo enrollment={enrollment} />
+                            <TextField
+                                margin="normal"
+                                fullWidth
+                                label="验证码"
+                                autoComplete="one-time-code"
+                                value={code}
+                                onChange={(e) => setCode(e.target.value)}
+                            />
+                            <Box sx={{ display: "flex", gap: 1 }}>
+                                <Button variant="contained" onClick={handleActivate} disabled={!code.trim()}>
+                                    启用
+                                </Button>
+                                <Button onClick={() => setEnrollment(null)}>取消</Button>
+                            </Box>
+                        </Box>
+                    ) : status.available ? (
+                        <Button variant="contained" onClick={handleEnroll}>
+                            绑定验证器应用
+                        </Button>
+                    ) : (
+                        <Typography variant="body2" color="text.secondary">
+                            管理员尚未配置 token_encryption_secrets，暂时不能绑定验证器应用。
+                        </Typography>
+                    )}
+                </Paper>
+            )}
+
+            <Dialog open={!!action} onClose={() => setAction(null)}>
+                <DialogTitle>{action === "regenerate" ? "重新生成恢复码" : "停用两步验证"}</DialogTitle>
+                <DialogContent>
+                    <DialogContentText>
+                        {action === "regenerate"
+                            ? "原有的恢复码将全部作废。请输入验证器应用中的验证码或一个恢复码确认。"
+                            : "停用后登录只需要密码。请输入验证器应用中的验证码或一个恢复码确认。"}
+                    </DialogContentText>
+                    <TextField
+                        autoFocus
+                        margin="normal"
+                        fullWidth
+                        label="验证码或恢复码"
+                        autoComplete="one-time-code"
+                        value={code}
+                        onChange={(e) => setCode(e.target.value)}
+                    />
+                </DialogContent>
+                <DialogActions>
+                    <Button onClick={() => setAction(null)}>取消</Button>
+                    <Button
+                        onClick={handleConfirmAction}
+                        color={action === "disable" ? "error" : "primary"}
+                        disabled={!code.trim()}
+                    >
+                        确认
+                    </Button>
+                </DialogActions>
+            </Dialog>
+        </Box>
+    );
+}
+
+export default TwoFactor;
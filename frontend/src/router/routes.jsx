@@ -8,12 +8,16 @@ import AdminLayout from "../layouts/AdminLayout";
 import Dashboard from "../pages/Dashboard";
 import Jobs from "../pages/Jobs";
 import Login from "../pages/Login";
+import OIDCCallback from "../pages/OIDCCallback";
 import ProtectedRoute from "./ProtectedRoute";
 import AdminProtectedRoute from "./AdminProtectedRoute";
 import Tutorial from "../pages/Tutorial";
 import BatchJob from "../pages/JobBatch";
 import DebugJob from "../pages/JobDebug";
 import AdminDashboard from "../pages/AdminDashboard";
+import ApiKeys from "../pages/ApiKeys";
+import LoginLockouts from "../pages/LoginLockouts";
+import TwoFactor from "../pages/TwoFactor";
 
 /**
  * @description 根据用户角色重定向到相应的主页。
@@ -45,11 +49,14 @@ export default function AppRoutes() {
         <Routes>
             {/* 公开路由：登录页面 */}
             <Route path="/login" element={<Login />} />
+            <Route path="/oidc/callback" element={<OIDCCallback />} />
 
             {/* 管理员专属路由 */}
             <Route element={<AdminProtectedRoute />}>
                 <Route path="/admin" element={<AdminLayout />}>
                     <Route index element={<AdminDashboard />} />
+                    <Route path="lockouts" element={<LoginLockouts />} />
+                    <Route path="security" element={<TwoFactor />} />
                     {/* 在这里可以添加更多管理员页面, 例如 /admin/users */}
                 </Route>
             </Route>
@@ -66,6 +73,8 @@ export default function AppRoutes() {
                     <Route path="job-debug" element={<DebugJob />} />
                     <Route path="job-batch" element={<BatchJob />} />
                     <Route path="tutorial" element={<Tutorial />} />
+                    <Route path="api-keys" element={<ApiKeys />} />
+                    <Route path="security" element={<TwoFactor />} />
                 </Route>
             </Route>
 
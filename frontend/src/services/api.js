@@ -1,8 +1,14 @@
 import axios from "axios";
+import { saveToken, getRefreshToken, getCSRFToken, clearToken } from "../utils/tokenUtils";
 
-// 创建axios实例
+// cookie 会话中修改类请求需要带上的 CSRF 请求头
+const CSRF_HEADER = "X-CSRF-Token";
+const SAFE_METHODS = ["get", "head", "options"];
+
+// 创建axios实例，withCredentials 使跨域部署时也会带上 cookie 会话
 const api = axios.create({
     baseURL: import.meta.env.VITE_API_BASE_URL,
+    withCredentials: true,
     timeout: 20000,
     headers: {
         "Content-Type": "application/json",
@@ -16,6 +22,10 @@ api.interceptors.request.use(
         if (token) {
             config.headers.Authorization = `Bearer ${token}`;
         }
+        const csrfToken = getCSRFToken();
+        if (csrfToken && !SAFE_METHODS.includes((config.method || "get").toLowerCase())) {
+            config.headers[CSRF_HEADER] = csrfToken;
+        }
         return config;
     },
     (error) => {
@@ -23,21 +33,102 @@ api.interceptors.request.use(
     }
 );
 
+// 正在进行的刷新请求，多个请求同时遇到401时共用同一次刷新，避免同一个refresh token被重复使用
+let refreshPromise = null;
+
+// 多个标签页共享同一个 refresh token，同时刷新时只有一个成功，其余的收到 409。
+// 此时等待成功的标签页保存新的 token 后直接使用；cookie 会话的新 cookie 由浏览器保存，稍等后重试即可
+const waitForRotatedToken = (previousRefreshToken) =>
+    new Promise((resolve, reject) => {
+        if (!previousRefreshToken) {
+            setTimeout(() => resolve(null), 1000);
+            return;
+        }
+        const deadline = Date.now() + 5000;
+        const check = () => {
+            if (getRefreshToken() !== previousRefreshToken) {
+                resolve(localStorage.getItem("token"));
+            } else if (Date.now() > deadline) {
+                reject(new Error("refresh token was rotated by another tab"));
+            } else {
+                setTimeout(check, 100);
+            }
+        };
+        check();
+    });
+
+// 使用refresh token换取新的token，refresh token是一次性的，成功后立即保存新的一对。
+// cookie 会话的 refresh token 在 HttpOnly cookie 中，由浏览器自动带上
+const refreshTokens = () => {
+    if (!refreshPromise) {
+        const url = `${import.meta.env.VITE_API_BASE_URL}/refresh`;
+        const refreshToken = getRefreshToken();
+        const csrfToken = getCSRFToken();
+        let request;
+        if (refreshToken) {
+            request = axios.post(url, { refresh_token: refreshToken });
+        } else if (csrfToken) {
+            request = axios.post(url, {}, { withCredentials: true, headers: { [CSRF_HEADER]: csrfToken } });
+        } else {
+            request = Promise.reject(new Error("no refresh token"));
+        }
+        refreshPromise = request
+            .then(({ data }) => {
+                saveToken(data.token, data.refresh_token, data.expires_in, data.csrf_token);
+                // 角色在每次刷新时由服务端重新计算
+                if (data.user) {
+                    localStorage.setItem("user", JSON.stringify(data.user));
+                }
+                return data.token;
+            })
+            .catch((error) => {
+                if (error.response?.status === 409) {
+                    return waitForRotatedToken(refreshToken);
+                }
+                throw error;
+            })
+            .finally(() => {
+                refreshPromise = null;
+            });
+    }
+    return refreshPromise;
+};
+
 // 响应拦截器 - 处理常见错误
 api.interceptors.response.use(
     (response) => {
         return response.data;
     },
-    (error) => {
+    async (error) => {
         // 处理401错误 - 未授权/token过期
         if (error.response && error.response.status === 401) {
-            if (error.config.url.endsWith("/login")) {
+            const { config } = error;
+            if (
+                config.url.endsWith("/login") ||
+                config.url.startsWith("/login/") ||
+                config.url.endsWith("/logout") ||
+                config.url.endsWith("/refresh") ||
+                config.url.startsWith("/oidc/")
+            ) {
                 return Promise.reject(error);
             }
 
+            // access token过期时先尝试刷新一次，成功后重试原请求
+            if (!config._retried) {
+                config._retried = true;
+                try {
+                    const token = await refreshTokens();
+                    if (token) {
+                        config.headers.Authorization = `Bearer ${token}`;
+                    }
+                    return api(config);
+                } catch (refreshError) {
+                    console.error("刷新登录状态失败:", refreshError);
+                }
+            }
+
             // 清除本地存储的token和用户信息
-            localStorage.removeItem("token");
-            localStorage.removeItem("user");
+            clearToken();
             // 重定向到登录页
             window.location.href = "/login";
         }
@@ -58,10 +149,164 @@ const apiService = {
         }
     },
 
+    // 登录的第二步，提交验证器应用中的验证码或恢复码，返回与 login 相同的结果
+    verifyMFALogin: async (mfaToken, code) => {
+        try {
+            const response = await api.post("/login/mfa", { mfa_token: mfaToken, code });
+            return response;
+        } catch (error) {
+            console.error("两步验证失败:", error);
+            throw error;
+        }
+    },
+
+    // 角色要求两步验证但尚未绑定时，在登录过程中生成 TOTP 密钥
+    startMFALoginEnrollment: async (mfaToken) => {
+        try {
+            const response = await api.post("/login/mfa/enroll", { mfa_token: mfaToken });
+            return response;
+        } catch (error) {
+            console.error("生成两步验证密钥失败:", error);
+            throw error;
+        }
+    },
+
+    // 查询是否启用了单点登录
+    getOIDCConfig: async () => {
+        try {
+            const response = await api.get("/oidc/config");
+            return response;
+        } catch (error) {
+            console.error("获取单点登录配置失败:", error);
+            throw error;
+        }
+    },
+
+    // 开始单点登录，返回身份提供者的登录地址和本次登录的 state
+    startOIDCLogin: async () => {
+        try {
+            const response = await api.get("/oidc/login");
+            return response;
+        } catch (error) {
+            console.error("开始单点登录失败:", error);
+            throw error;
+        }
+    },
+
+    // 用身份提供者回调中的授权码完成单点登录，返回与 login 相同的结果
+    completeOIDCLogin: async (code, state) => {
+        try {
+            const response = await api.post("/oidc/callback", { code, state });
+            return response;
+        } catch (error) {
+            console.error("完成单点登录失败:", error);
+            throw error;
+        }
+    },
+
+    // 列出当前用户的 API key
+    listApiKeys: async () => {
+        try {
+            const response = await api.get("/v1/keys");
+            return response;
+        } catch (error) {
+            console.error("获取 API key 列表失败:", error);
+            throw error;
+        }
+    },
+
+    // 创建 API key，expiresInDays 为 0 时使用服务端的默认有效期（90 天），返回的 key 只出现这一次
+    createApiKey: async (name, scopes, expiresInDays) => {
+        try {
+            const response = await api.post("/v1/keys", { name, scopes, expires_in_days: expiresInDays });
+            return response;
+        } catch (error) {
+            console.error("创建 API key 失败:", error);
+            throw error;
+        }
+    },
+
+    // 吊销 API key
+    revokeApiKey: async (keyId) => {
+        try {
+            const response = await api.delete(`/v1/keys/${keyId}`);
+            return response;
+        } catch (error) {
+            console.error("吊销 API key 失败:", error);
+            throw error;
+        }
+    },
+
+    // 查询当前用户的两步验证状态
+    getMFAStatus: async () => {
+        try {
+            const response = await api.get("/v1/mfa");
+            return response;
+        } catch (error) {
+            console.error("获取两步验证状态失败:", error);
+            throw error;
+        }
+    },
+
+    // 生成新的 TOTP 密钥，返回密钥和二维码，用验证码确认后才会启用
+    enrollTOTP: async () => {
+        try {
+            const response = await api.post("/v1/mfa/enroll");
+            return response;
+        } catch (error) {
+            console.error("生成两步验证密钥失败:", error);
+            throw error;
+        }
+    },
+
+    // 用验证码确认并启用两步验证，返回的恢复码只出现这一次
+    activateTOTP: async (code) => {
+        try {
+            const response = await api.post("/v1/mfa/activate", { code });
+            return response;
+        } catch (error) {
+            console.error("启用两步验证失败:", error);
+            throw error;
+        }
+    },
+
+    // 重新生成恢复码，原有的恢复码全部作废
+    regenerateRecoveryCodes: async (code) => {
+        try {
+            const response = await api.post("/v1/mfa/recovery-codes", { code });
+            return response;
+        } catch (error) {
+            console.error("重新生成恢复码失败:", error);
+            throw error;
+        }
+    },
+
+    // 停用两步验证
+    disableTOTP: async (code) => {
+        try {
+            const response = await api.post("/v1/mfa/disable", { code });
+            return response;
+        } catch (error) {
+            console.error("停用两步验证失败:", error);
+            throw error;
+        }
+    },
+
+    // 登出，吊销服务端的 token
+    logout: async (terminateSessions = false) => {
+        try {
+            const response = await api.post("/logout", { terminate_sessions: terminateSessions });
+            return response;
+        } catch (error) {
+            console.error("登出失败:", error);
+            throw error;
+        }
+    },
+
     // 获取整个集群状态
     getClusterStatus: async () => {
         try {
-            const response = await api.get("/v1/cluster/status");
+            const response = await api.get("/v1/admin/cluster/status");
             return response;
         } catch (error) {
             console.error("获取集群状态失败:", error);
@@ -69,6 +314,50 @@ const apiService = {
         }
     },
 
+    // 获取登录失败和锁定记录
+    getLoginLockouts: async () => {
+        try {
+            const response = await api.get("/v1/admin/lockouts");
+            return response;
+        } catch (error) {
+            console.error("获取登录锁定记录失败:", error);
+            throw error;
+        }
+    },
+
+    // 解除用户名或 IP 的登录锁定，kind 为 "user" 或 "ip"
+    clearLoginLockout: async (kind, key) => {
+        try {
+            const response = await api.delete(`/v1/admin/lockouts/${kind}/${encodeURIComponent(key)}`);
+            return response;
+        } catch (error) {
+            console.error("解除登录锁定失败:", error);
+            throw error;
+        }
+    },
+
+    // 重置用户的两步验证，用于用户丢失了验证器和恢复码的情况
+    resetUserMFA: async (username) => {
+        try {
+            const response = await api.delete(`/v1/admin/mfa/${encodeURIComponent(username)}`);
+            return response;
+        } catch (error) {
+            console.error("重置两步验证失败:", error);
+            throw error;
+        }
+    },
+
+    // 查询审计日志
+    getAuditLog: async (params = {}) => {
+        try {
+            const response = await api.get("/v1/admin/audit", { params });
+            return response;
+        } catch (error) {
+            console.error("获取审计日志失败:", error);
+            throw error;
+        }
+    },
+
     // 获取用户可见的集群状态
     getClusterStatusLimit: async () => {
         try {
@@ -157,6 +446,17 @@ const apiService = {
         }
     },
 
+    // 获取 WebSocket 连接使用的一次性票据，target 为 "shell" 或 "salloc"
+    getWebSocketTicket: async (target, sessionId) => {
+        try {
+            const response = await api.post("/v1/ws/ticket", { target, session_id: sessionId });
+            return response.ticket;
+        } catch (error) {
+            console.error("获取 WebSocket 票据失败:", error);
+            throw error;
+        }
+    },
+
     // 提交 sbatch 作业
     submitSbatchJob: async (scriptContent) => {
         try {
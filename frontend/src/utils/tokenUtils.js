@@ -3,27 +3,55 @@
  */
 
 /**
- * 保存token到本地存储并设置过期时间
- * @param {string} token - 从API获取的token
+ * 保存token到本地存储并设置过期时间。服务端开启 cookie 会话时 token 保存在 HttpOnly cookie 中，
+ * 只返回 csrfToken，此时只保存 csrfToken
+ * @param {string} token - 从API获取的access token
+ * @param {string} refreshToken - 一次性的refresh token，每次刷新后都会更换
+ * @param {number} expiresIn - access token的有效期（秒）
+ * @param {string} csrfToken - cookie 会话中修改类请求需要带上的 CSRF token
  */
-export const saveToken = (token) => {
+export const saveToken = (token, refreshToken, expiresIn, csrfToken) => {
     try {
         // 保存token
-        localStorage.setItem("token", token);
+        if (token) {
+            localStorage.setItem("token", token);
+        }
+        if (refreshToken) {
+            localStorage.setItem("refreshToken", refreshToken);
+        }
+        if (csrfToken) {
+            localStorage.setItem("csrfToken", csrfToken);
+            // 服务端切换到 cookie 会话后，不再使用之前保存的 token
+            if (!token) {
+                localStorage.removeItem("token");
+                localStorage.removeItem("refreshToken");
+            }
+        }
 
-        // 计算24小时后的过期时间
-        const expiryTime = new Date();
-        expiryTime.setHours(expiryTime.getHours() + 24);
+        // 按服务端返回的有效期计算过期时间
+        const expiryTime = new Date().getTime() + (expiresIn || 0) * 1000;
 
         // 保存过期时间
-        localStorage.setItem("tokenExpiry", expiryTime.getTime().toString());
+        localStorage.setItem("tokenExpiry", expiryTime.toString());
     } catch (error) {
         console.error("保存token失败:", error);
     }
 };
 
 /**
- * 检查token是否过期
+ * 获取refresh token
+ * @returns {string|null}
+ */
+export const getRefreshToken = () => localStorage.getItem("refreshToken");
+
+/**
+ * 获取 cookie 会话的 CSRF token，使用 Authorization 头认证时为 null
+ * @returns {string|null}
+ */
+export const getCSRFToken = () => localStorage.getItem("csrfToken");
+
+/**
+ * 检查登录是否过期。access token过期但仍持有refresh token时，可以通过刷新继续使用，不视为过期
  * @returns {boolean} - 如果token已过期或不存在则返回true，否则返回false
  */
 export const isTokenExpired = () => {
@@ -32,9 +60,14 @@ export const isTokenExpired = () => {
         const token = localStorage.getItem("token");
         const expiryTime = localStorage.getItem("tokenExpiry");
 
+        // cookie 会话的 token 由浏览器保存，是否过期由服务端判断
+        if (!token && getCSRFToken()) return false;
+
         // 如果token或过期时间不存在，则视为已过期
         if (!token || !expiryTime) return true;
 
+        if (getRefreshToken()) return false;
+
         // 检查是否已过期
         const now = new Date().getTime();
         return now > parseInt(expiryTime, 10);
@@ -50,5 +83,7 @@ export const isTokenExpired = () => {
 export const clearToken = () => {
     localStorage.removeItem("token");
     localStorage.removeItem("tokenExpiry");
+    localStorage.removeItem("refreshToken");
+    localStorage.removeItem("csrfToken");
     localStorage.removeItem("user");
 };